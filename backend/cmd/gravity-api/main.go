@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/MarcoPoloResearchLab/gravity/backend/internal/auth"
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/buildinfo"
 	"github.com/MarcoPoloResearchLab/gravity/backend/internal/config"
 	"github.com/MarcoPoloResearchLab/gravity/backend/internal/database"
 	"github.com/MarcoPoloResearchLab/gravity/backend/internal/logging"
@@ -18,6 +20,7 @@ import (
 	"github.com/MarcoPoloResearchLab/gravity/backend/internal/users"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
 )
 
@@ -38,6 +41,8 @@ func main() {
 	}
 
 	setupFlags(rootCmd)
+	rootCmd.AddCommand(newMigrateCommand())
+	rootCmd.AddCommand(newVersionCommand())
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -49,16 +54,80 @@ func setupFlags(cmd *cobra.Command) {
 	defaults := config.NewViper()
 	cmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Path to configuration file")
 	cmd.PersistentFlags().String("http-address", defaults.GetString("http.address"), "HTTP listen address")
+	cmd.PersistentFlags().Int64("http-max-body-bytes", defaults.GetInt64("http.max_body_bytes"), "Maximum request body size in bytes (0 uses the default of 16 MiB)")
+	cmd.PersistentFlags().Duration("http-stream-idle-timeout", defaults.GetDuration("http.stream_idle_timeout"), "Close a realtime stream when one event cannot be flushed within this duration (0 uses the default of 1m)")
+	cmd.PersistentFlags().Bool("http-unversioned-routes", defaults.GetBool("http.unversioned_routes"), "Also serve the API without the /v1 prefix during the deprecation window")
+	cmd.PersistentFlags().String("database-driver", defaults.GetString("database.driver"), "Database driver (sqlite, postgres)")
 	cmd.PersistentFlags().String("database-path", defaults.GetString("database.path"), "SQLite database path")
+	cmd.PersistentFlags().String("database-dsn", defaults.GetString("database.dsn"), "PostgreSQL connection string")
+	cmd.PersistentFlags().Int("database-max-open-conns", defaults.GetInt("database.max_open_conns"), "Maximum open database connections (0 keeps the driver default; SQLite defaults to 1)")
+	cmd.PersistentFlags().Int("database-max-idle-conns", defaults.GetInt("database.max_idle_conns"), "Maximum idle database connections (0 keeps the driver default)")
+	cmd.PersistentFlags().Duration("database-conn-max-lifetime", defaults.GetDuration("database.conn_max_lifetime"), "Maximum lifetime of a pooled database connection (0 disables recycling)")
+	cmd.PersistentFlags().Duration("database-busy-timeout", defaults.GetDuration("database.busy_timeout"), "SQLite busy timeout applied to every connection")
+	cmd.PersistentFlags().String("database-journal-mode", defaults.GetString("database.journal_mode"), "SQLite journal mode (WAL, DELETE)")
 	cmd.PersistentFlags().String("log-level", defaults.GetString("log.level"), "Log level (debug, info, warn, error)")
+	cmd.PersistentFlags().String("log-format", defaults.GetString("log.format"), "Log encoding (json, console)")
+	cmd.PersistentFlags().Int("log-sampling-initial", defaults.GetInt("log.sampling.initial"), "Identical log entries kept per second before sampling starts (0 keeps the default)")
+	cmd.PersistentFlags().Int("log-sampling-thereafter", defaults.GetInt("log.sampling.thereafter"), "Keep every Nth identical log entry once sampling starts (0 keeps the default)")
+	cmd.PersistentFlags().Bool("log-sampling-disabled", defaults.GetBool("log.sampling.disabled"), "Log every entry without sampling")
 	cmd.PersistentFlags().String("tauth-signing-secret", defaults.GetString("tauth.signing_secret"), "Shared HS256 signing secret from TAuth")
+	cmd.PersistentFlags().String("tauth-signing-secret-file", defaults.GetString("tauth.signing_secret_file"), "File containing the TAuth signing secret (overrides --tauth-signing-secret)")
 	cmd.PersistentFlags().String("tauth-cookie-name", defaults.GetString("tauth.cookie_name"), "Cookie name carrying the TAuth session token")
+	cmd.PersistentFlags().Duration("tauth-leeway", defaults.GetDuration("tauth.leeway"), "Clock skew tolerated when validating TAuth session tokens")
+	cmd.PersistentFlags().Float64("ratelimit-rps", defaults.GetFloat64("ratelimit.requests_per_second"), "Sustained requests per second allowed per user (0 disables rate limiting)")
+	cmd.PersistentFlags().Int("ratelimit-burst", defaults.GetInt("ratelimit.burst"), "Requests a user may burst above the sustained rate")
+	cmd.PersistentFlags().Int("notes-max-per-user", defaults.GetInt("notes.max_per_user"), "Maximum notes a user may create (0 disables the quota)")
+	cmd.PersistentFlags().Int("notes-max-updates-per-sync", defaults.GetInt("notes.max_updates_per_sync"), "Maximum CRDT updates accepted in one sync request (0 uses the default of 1000)")
+	cmd.PersistentFlags().Duration("notes-sync-timeout", defaults.GetDuration("notes.sync_timeout"), "Maximum duration of a sync write transaction (0 leaves it unbounded)")
+	cmd.PersistentFlags().String("cors-allowed-methods", defaults.GetString("cors.allowed_methods"), "Comma-separated methods allowed in CORS preflights (empty uses GET,POST,PUT,DELETE,OPTIONS)")
+	cmd.PersistentFlags().Duration("cors-max-age", defaults.GetDuration("cors.max_age"), "How long browsers may cache a CORS preflight (0 omits Access-Control-Max-Age)")
+	cmd.PersistentFlags().Int("notes-sync-busy-retries", defaults.GetInt("notes.sync_busy_retries"), "Retries for a sync transaction that fails with SQLite busy or locked (0 disables retries)")
+	cmd.PersistentFlags().Int("notes-compaction-threshold", defaults.GetInt("notes.compaction_threshold"), "Updates a note may retain beyond its snapshot before sync recommends compaction (0 uses the default of 500)")
+	cmd.PersistentFlags().Bool("metrics-enabled", defaults.GetBool("metrics.enabled"), "Expose Prometheus metrics on /metrics")
 
 	bindFlag(cmd, "http.address", "http-address")
+	bindFlag(cmd, "http.max_body_bytes", "http-max-body-bytes")
+	bindFlag(cmd, "http.stream_idle_timeout", "http-stream-idle-timeout")
+	bindFlag(cmd, "http.unversioned_routes", "http-unversioned-routes")
+	bindFlag(cmd, "database.driver", "database-driver")
 	bindFlag(cmd, "database.path", "database-path")
+	bindFlag(cmd, "database.dsn", "database-dsn")
+	bindFlag(cmd, "database.max_open_conns", "database-max-open-conns")
+	bindFlag(cmd, "database.max_idle_conns", "database-max-idle-conns")
+	bindFlag(cmd, "database.conn_max_lifetime", "database-conn-max-lifetime")
+	bindFlag(cmd, "database.busy_timeout", "database-busy-timeout")
+	bindFlag(cmd, "database.journal_mode", "database-journal-mode")
 	bindFlag(cmd, "log.level", "log-level")
+	bindFlag(cmd, "log.format", "log-format")
+	bindFlag(cmd, "log.sampling.initial", "log-sampling-initial")
+	bindFlag(cmd, "log.sampling.thereafter", "log-sampling-thereafter")
+	bindFlag(cmd, "log.sampling.disabled", "log-sampling-disabled")
 	bindFlag(cmd, "tauth.signing_secret", "tauth-signing-secret")
+	bindFlag(cmd, "tauth.signing_secret_file", "tauth-signing-secret-file")
 	bindFlag(cmd, "tauth.cookie_name", "tauth-cookie-name")
+	bindFlag(cmd, "tauth.leeway", "tauth-leeway")
+	bindFlag(cmd, "metrics.enabled", "metrics-enabled")
+	bindFlag(cmd, "ratelimit.requests_per_second", "ratelimit-rps")
+	bindFlag(cmd, "ratelimit.burst", "ratelimit-burst")
+	bindFlag(cmd, "notes.max_per_user", "notes-max-per-user")
+	bindFlag(cmd, "notes.max_updates_per_sync", "notes-max-updates-per-sync")
+	bindFlag(cmd, "notes.sync_timeout", "notes-sync-timeout")
+	bindFlag(cmd, "notes.sync_busy_retries", "notes-sync-busy-retries")
+	bindFlag(cmd, "notes.compaction_threshold", "notes-compaction-threshold")
+	bindFlag(cmd, "cors.allowed_methods", "cors-allowed-methods")
+	bindFlag(cmd, "cors.max_age", "cors-max-age")
+}
+
+func newVersionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print build version information",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			build := buildinfo.Current()
+			_, err := fmt.Fprintf(cmd.OutOrStdout(), "version: %s\ncommit: %s\nbuilt: %s\n", build.Version, build.Commit, build.Date)
+			return err
+		},
+	}
 }
 
 func bindFlag(cmd *cobra.Command, key, flag string) {
@@ -82,19 +151,35 @@ func initConfig() error {
 	return nil
 }
 
+func loggerConfig(appConfig config.AppConfig) logging.Config {
+	return logging.Config{
+		Level:              appConfig.LogLevel,
+		Format:             appConfig.LogFormat,
+		SamplingInitial:    appConfig.LogSampling.Initial,
+		SamplingThereafter: appConfig.LogSampling.Thereafter,
+		DisableSampling:    appConfig.LogSampling.Disabled,
+	}
+}
+
 func runServer(ctx context.Context) error {
 	appConfig, err := config.Load(viper.GetViper())
 	if err != nil {
 		return err
 	}
 
-	logger, err := logging.NewLogger(appConfig.LogLevel)
+	logger, err := logging.NewLogger(loggerConfig(appConfig))
 	if err != nil {
 		return err
 	}
 	defer logger.Sync() //nolint:errcheck
 
-	db, err := database.OpenSQLite(appConfig.DatabasePath, logger)
+	build := buildinfo.Current()
+	logger.Info("gravity-api starting",
+		zap.String("version", build.Version),
+		zap.String("commit", build.Commit),
+		zap.String("build_date", build.Date))
+
+	db, err := database.Open(appConfig.Database(), logger)
 	if err != nil {
 		return err
 	}
@@ -105,17 +190,31 @@ func runServer(ctx context.Context) error {
 	defer sqlDB.Close()
 
 	sessionValidator, err := auth.NewSessionValidator(auth.SessionValidatorConfig{
-		SigningSecret: []byte(appConfig.TAuthSigningKey),
-		CookieName:    appConfig.TAuthCookieName,
+		SigningSecret:   []byte(appConfig.TAuthSigningKey),
+		CookieName:      appConfig.TAuthCookieName,
+		Leeway:          appConfig.TAuthLeeway,
+		RevocationStore: auth.NewInMemoryRevocationStore(time.Now),
 	})
 	if err != nil {
 		return err
 	}
 
+	var metrics *server.Metrics
+	if appConfig.MetricsEnabled {
+		metrics = server.NewMetrics()
+	}
+
 	notesService, err := notes.NewService(notes.ServiceConfig{
-		Database: db,
-		Clock:    time.Now,
-		Logger:   logger,
+		Database:            db,
+		Clock:               time.Now,
+		Logger:              logger,
+		MaxNotesPerUser:     appConfig.MaxNotesPerUser,
+		MaxUpdatesPerSync:   appConfig.MaxUpdatesPerSync,
+		SyncTimeout:         appConfig.SyncTimeout,
+		BusyRetries:         appConfig.SyncBusyRetries,
+		CompactionThreshold: appConfig.CompactionThreshold,
+		TracerProvider:      otel.GetTracerProvider(),
+		Observer:            metrics.NotesObserver(),
 	})
 	if err != nil {
 		return err
@@ -129,12 +228,31 @@ func runServer(ctx context.Context) error {
 		return err
 	}
 
+	realtime := server.NewRealtimeDispatcher()
+
 	handler, err := server.NewHTTPHandler(server.Dependencies{
-		SessionValidator: sessionValidator,
-		SessionCookie:    appConfig.TAuthCookieName,
-		NotesService:     notesService,
-		UserIdentities:   identityService,
-		Logger:           logger,
+		SessionValidator:         sessionValidator,
+		SessionCookie:            appConfig.TAuthCookieName,
+		SessionRevoker:           sessionValidator,
+		NotesService:             notesService,
+		UserIdentities:           identityService,
+		IdentityRemover:          identityService,
+		Logger:                   logger,
+		Realtime:                 realtime,
+		Metrics:                  metrics,
+		Pinger:                   sqlDB,
+		TracerProvider:           otel.GetTracerProvider(),
+		MaxRequestBodyBytes:      appConfig.HTTPMaxBodySize,
+		StreamIdleTimeout:        appConfig.HTTPStreamIdleTimeout,
+		DisableUnversionedRoutes: !appConfig.HTTPUnversionedRoutes,
+		CORS: server.CORSConfig{
+			AllowedMethods: appConfig.CORSAllowedMethods,
+			MaxAge:         appConfig.CORSMaxAge,
+		},
+		RateLimit: server.RateLimitConfig{
+			RequestsPerSecond: appConfig.RateLimitRPS,
+			Burst:             appConfig.RateLimitBurst,
+		},
 	})
 	if err != nil {
 		return err
@@ -162,6 +280,7 @@ func runServer(ctx context.Context) error {
 	case <-signalCtx.Done():
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
+		realtime.CloseAll()
 		return httpServer.Shutdown(shutdownCtx)
 	case err := <-errCh:
 		return err
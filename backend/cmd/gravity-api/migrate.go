@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/config"
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/database"
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/logging"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	migrationStateApplied = "applied"
+	migrationStatePending = "pending"
+)
+
+func newMigrateCommand() *cobra.Command {
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply database migrations and report their status",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return initConfig()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMigrate(cmd.OutOrStdout(), dryRun)
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List pending migrations without applying them")
+	cmd.AddCommand(newMigrateDownCommand())
+	return cmd
+}
+
+func newMigrateDownCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "down <name>",
+		Short: "Roll back a single applied migration",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return initConfig()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMigrateDown(cmd.OutOrStdout(), args[0])
+		},
+	}
+}
+
+func runMigrate(out io.Writer, dryRun bool) error {
+	appConfig, err := config.LoadDatabase(viper.GetViper())
+	if err != nil {
+		return err
+	}
+
+	logger, err := logging.NewLogger(loggerConfig(appConfig))
+	if err != nil {
+		return err
+	}
+	defer logger.Sync() //nolint:errcheck
+
+	db, err := database.Connect(appConfig.Database())
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	if !dryRun {
+		if err := database.Migrate(db, logger); err != nil {
+			return err
+		}
+	}
+
+	statuses, err := database.MigrationStatuses(db)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if !status.Applied {
+			fmt.Fprintf(out, "%s\t%s\n", migrationStatePending, status.Name)
+			continue
+		}
+		appliedAt := time.Unix(status.AppliedAtSeconds, 0).UTC().Format(time.RFC3339)
+		fmt.Fprintf(out, "%s\t%s\t%s\n", migrationStateApplied, status.Name, appliedAt)
+	}
+	return nil
+}
+
+func runMigrateDown(out io.Writer, name string) error {
+	appConfig, err := config.LoadDatabase(viper.GetViper())
+	if err != nil {
+		return err
+	}
+
+	logger, err := logging.NewLogger(loggerConfig(appConfig))
+	if err != nil {
+		return err
+	}
+	defer logger.Sync() //nolint:errcheck
+
+	db, err := database.Connect(appConfig.Database())
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	if err := database.RollbackMigration(db, name, logger); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "rolled back\t%s\n", name)
+	return nil
+}
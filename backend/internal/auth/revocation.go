@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// RevocationStore records revoked session token identifiers. Implementations may forget an
+// identifier once expiresAt has passed; callers include any validation leeway in expiresAt, because
+// the token can no longer validate by then. A zero expiresAt marks a token without an exp claim, which
+// never stops validating, so its revocation must be kept for good.
+type RevocationStore interface {
+	Revoke(tokenID string, expiresAt time.Time) error
+	IsRevoked(tokenID string) (bool, error)
+}
+
+// InMemoryRevocationStore keeps revoked identifiers in process memory. Expired entries are ignored on
+// lookup and evicted when the next revocation is recorded, so lookups stay O(1). Entries without an
+// expiry are never evicted.
+type InMemoryRevocationStore struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	clock   func() time.Time
+}
+
+// NewInMemoryRevocationStore constructs an empty store; a nil clock defaults to time.Now.
+func NewInMemoryRevocationStore(clock func() time.Time) *InMemoryRevocationStore {
+	if clock == nil {
+		clock = time.Now
+	}
+	return &InMemoryRevocationStore{
+		entries: make(map[string]time.Time),
+		clock:   clock,
+	}
+}
+
+// Revoke records the identifier until expiresAt, or permanently when expiresAt is zero.
+func (store *InMemoryRevocationStore) Revoke(tokenID string, expiresAt time.Time) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.evictExpired(store.clock())
+	store.entries[tokenID] = expiresAt
+	return nil
+}
+
+// IsRevoked reports whether the identifier was revoked and has not yet expired.
+func (store *InMemoryRevocationStore) IsRevoked(tokenID string) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	expiresAt, revoked := store.entries[tokenID]
+	return revoked && (expiresAt.IsZero() || store.clock().Before(expiresAt)), nil
+}
+
+func (store *InMemoryRevocationStore) evictExpired(now time.Time) {
+	for tokenID, expiresAt := range store.entries {
+		if !expiresAt.IsZero() && !now.Before(expiresAt) {
+			delete(store.entries, tokenID)
+		}
+	}
+}
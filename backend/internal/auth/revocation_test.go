@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestSessionValidatorRevokeSession(t *testing.T) {
+	testCases := []struct {
+		name    string
+		tokenID string
+	}{
+		{name: "with-jti", tokenID: "token-id-1"},
+		{name: "without-jti"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			validator, err := NewSessionValidator(SessionValidatorConfig{
+				SigningSecret:   []byte(testSessionSigningSecret),
+				CookieName:      testSessionCookieName,
+				RevocationStore: NewInMemoryRevocationStore(nil),
+			})
+			if err != nil {
+				t.Fatalf("failed to construct validator: %v", err)
+			}
+			revokedToken := mustSignRevocableSessionToken(t, testCase.tokenID, "user-revoked")
+			otherToken := mustSignRevocableSessionToken(t, "", "user-other")
+
+			if _, err := validator.ValidateToken(revokedToken); err != nil {
+				t.Fatalf("expected token to validate before revocation: %v", err)
+			}
+			if err := validator.RevokeSession(revokedToken); err != nil {
+				t.Fatalf("revoke failed: %v", err)
+			}
+			if _, err := validator.ValidateToken(revokedToken); !errors.Is(err, ErrRevokedSessionToken) {
+				t.Fatalf("expected ErrRevokedSessionToken, got %v", err)
+			}
+			if _, err := validator.ValidateToken(otherToken); err != nil {
+				t.Fatalf("expected unrelated token to stay valid: %v", err)
+			}
+			if err := validator.RevokeSession(revokedToken); err != nil {
+				t.Fatalf("expected repeated revocation to succeed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestInMemoryRevocationStoreEvictsAfterExpiry(t *testing.T) {
+	clockNow := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	store := NewInMemoryRevocationStore(func() time.Time {
+		return clockNow
+	})
+
+	if err := store.Revoke("token-short", clockNow.Add(time.Minute)); err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+	if err := store.Revoke("token-default", time.Time{}); err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+
+	clockNow = clockNow.Add(2 * time.Minute)
+	if revoked, _ := store.IsRevoked("token-short"); revoked {
+		t.Fatal("expected expired revocation to be ignored")
+	}
+	if revoked, _ := store.IsRevoked("token-default"); !revoked {
+		t.Fatal("expected revocation without expiry to hold")
+	}
+	if len(store.entries) != 2 {
+		t.Fatalf("expected lookups not to evict, got %d entries", len(store.entries))
+	}
+	if err := store.Revoke("token-later", clockNow.Add(time.Minute)); err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+	if _, retained := store.entries["token-short"]; retained || len(store.entries) != 2 {
+		t.Fatalf("expected the next revocation to evict the expired entry, got %v", store.entries)
+	}
+
+	clockNow = clockNow.Add(48 * time.Hour)
+	if err := store.Revoke("token-final", clockNow.Add(time.Minute)); err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+	if revoked, _ := store.IsRevoked("token-default"); !revoked {
+		t.Fatal("expected revocation without expiry to survive eviction")
+	}
+}
+
+func TestSessionValidatorRevocationOfTokenWithoutExpiryIsPermanent(t *testing.T) {
+	clockNow := time.Now()
+	store := NewInMemoryRevocationStore(func() time.Time { return clockNow })
+	validator, err := NewSessionValidator(SessionValidatorConfig{
+		SigningSecret:   []byte(testSessionSigningSecret),
+		CookieName:      testSessionCookieName,
+		RevocationStore: store,
+	})
+	if err != nil {
+		t.Fatalf("failed to construct validator: %v", err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, SessionClaims{
+		UserID: "user-no-exp",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       "token-no-exp",
+			Issuer:   defaultSessionIssuer,
+			Subject:  "user-no-exp",
+			IssuedAt: jwt.NewNumericDate(clockNow.Add(-time.Minute)),
+		},
+	})
+	signed, err := token.SignedString([]byte(testSessionSigningSecret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	if _, err := validator.ValidateToken(signed); err != nil {
+		t.Fatalf("expected token without exp to validate: %v", err)
+	}
+	if err := validator.RevokeSession(signed); err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+
+	clockNow = clockNow.Add(25 * time.Hour)
+	if err := store.Revoke("token-other", clockNow.Add(time.Minute)); err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+	if _, err := validator.ValidateToken(signed); !errors.Is(err, ErrRevokedSessionToken) {
+		t.Fatalf("expected the token without exp to stay revoked past a day, got %v", err)
+	}
+}
+
+func TestSessionValidatorRevocationCoversLeeway(t *testing.T) {
+	const leeway = 5 * time.Minute
+	store := NewInMemoryRevocationStore(nil)
+	validator, err := NewSessionValidator(SessionValidatorConfig{
+		SigningSecret:   []byte(testSessionSigningSecret),
+		CookieName:      testSessionCookieName,
+		RevocationStore: store,
+		Leeway:          leeway,
+	})
+	if err != nil {
+		t.Fatalf("failed to construct validator: %v", err)
+	}
+	token := mustSignRevocableSessionToken(t, "token-leeway", "user-leeway")
+	claims, err := validator.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("expected token to validate: %v", err)
+	}
+	if err := validator.RevokeSession(token); err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+
+	store.clock = func() time.Time { return claims.ExpiryTime().Add(leeway / 2) }
+	if revoked, _ := store.IsRevoked("token-leeway"); !revoked {
+		t.Fatal("expected the revocation to last while the leeway still accepts the token")
+	}
+	store.clock = func() time.Time { return claims.ExpiryTime().Add(leeway) }
+	if revoked, _ := store.IsRevoked("token-leeway"); revoked {
+		t.Fatal("expected the revocation to lapse once the leeway has passed")
+	}
+}
+
+func mustSignRevocableSessionToken(t *testing.T, tokenID string, userID string) string {
+	t.Helper()
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, SessionClaims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Issuer:    defaultSessionIssuer,
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	})
+	signed, err := token.SignedString([]byte(testSessionSigningSecret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
@@ -1,6 +1,13 @@
 package auth
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
@@ -11,15 +18,45 @@ import (
 )
 
 var (
-	ErrMissingSessionSigningKey = errors.New("session validator: signing key required")
-	ErrMissingSessionCookieName = errors.New("session validator: cookie name required")
-	ErrMissingSessionToken      = errors.New("session validator: token required")
-	ErrInvalidSessionToken      = errors.New("session validator: invalid token")
-	ErrExpiredSessionToken      = errors.New("session validator: token expired")
-	ErrMissingSessionSubject    = errors.New("session validator: subject required")
+	ErrMissingSessionSigningKey    = errors.New("session validator: signing key required")
+	ErrMissingSessionCookieName    = errors.New("session validator: cookie name required")
+	ErrMissingSessionToken         = errors.New("session validator: token required")
+	ErrInvalidSessionToken         = errors.New("session validator: invalid token")
+	ErrExpiredSessionToken         = errors.New("session validator: token expired")
+	ErrMissingSessionSubject       = errors.New("session validator: subject required")
+	ErrUnsupportedSessionAlgorithm = errors.New("session validator: unsupported signing algorithm")
+	ErrSessionKeyMismatch          = errors.New("session validator: key does not match signing algorithm")
+	ErrInvalidSessionLeeway        = errors.New("session validator: leeway must not be negative")
+	ErrRevokedSessionToken         = errors.New("session validator: token revoked")
 )
 
-const defaultSessionIssuer = "tauth"
+const (
+	defaultSessionIssuer = "tauth"
+	authorizationHeader  = "Authorization"
+	bearerPrefix         = "Bearer "
+)
+
+// SessionSigningAlgorithm identifies the JWS algorithm TAuth uses to sign session tokens.
+type SessionSigningAlgorithm string
+
+const (
+	SessionSigningAlgorithmHS256 SessionSigningAlgorithm = "HS256"
+	SessionSigningAlgorithmRS256 SessionSigningAlgorithm = "RS256"
+	SessionSigningAlgorithmES256 SessionSigningAlgorithm = "ES256"
+)
+
+type sessionKeyResolver func(key SigningKey) (interface{}, error)
+
+type sessionAlgorithmDefinition struct {
+	method     jwt.SigningMethod
+	resolveKey sessionKeyResolver
+}
+
+var sessionAlgorithms = map[SessionSigningAlgorithm]sessionAlgorithmDefinition{
+	SessionSigningAlgorithmHS256: {method: jwt.SigningMethodHS256, resolveKey: resolveSessionSecret},
+	SessionSigningAlgorithmRS256: {method: jwt.SigningMethodRS256, resolveKey: resolveSessionRSAKey},
+	SessionSigningAlgorithmES256: {method: jwt.SigningMethodES256, resolveKey: resolveSessionECDSAKey},
+}
 
 // SessionClaims mirror the payload emitted by TAuth.
 type SessionClaims struct {
@@ -31,42 +68,166 @@ type SessionClaims struct {
 	jwt.RegisteredClaims
 }
 
-// SessionValidatorConfig describes how to validate HS256 session cookies.
+// SigningKey describes a single verification key. KeyID is optional and matches the token's kid header.
+// HS256 keys use Secret; RS256 and ES256 keys use PublicKey.
+type SigningKey struct {
+	KeyID     string
+	Secret    []byte
+	PublicKey crypto.PublicKey
+}
+
+// ExpiryTime returns the parsed exp claim, or the zero time when the token carries none.
+func (claims SessionClaims) ExpiryTime() time.Time {
+	if claims.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return claims.ExpiresAt.Time
+}
+
+// SessionValidatorConfig describes how to validate session cookies.
+// Algorithm defaults to HS256. SigningKeys lists the accepted keys in preference order;
+// SigningSecret and PublicKey remain supported as a single leading key. Leeway tolerates
+// clock skew when checking exp, nbf, and iat. RevocationStore, when set, rejects tokens revoked via RevokeSession.
 type SessionValidatorConfig struct {
-	SigningSecret []byte
-	CookieName    string
-	Clock         func() time.Time
+	Algorithm       SessionSigningAlgorithm
+	SigningKeys     []SigningKey
+	SigningSecret   []byte
+	PublicKey       crypto.PublicKey
+	CookieName      string
+	Clock           func() time.Time
+	Leeway          time.Duration
+	RevocationStore RevocationStore
 }
 
-// SessionValidator validates HS256 JWTs and extracts the session claims.
+// SessionValidator validates session JWTs and extracts the session claims.
 type SessionValidator struct {
-	signingSecret []byte
-	issuer        string
-	cookieName    string
-	clock         func() time.Time
+	signingMethod    jwt.SigningMethod
+	verificationKeys []sessionVerificationKey
+	issuer           string
+	cookieName       string
+	clock            func() time.Time
+	leeway           time.Duration
+	revocations      RevocationStore
+}
+
+type sessionVerificationKey struct {
+	keyID string
+	key   interface{}
 }
 
 // NewSessionValidator constructs a validator with the provided configuration.
 func NewSessionValidator(cfg SessionValidatorConfig) (*SessionValidator, error) {
-	if len(cfg.SigningSecret) == 0 {
-		return nil, ErrMissingSessionSigningKey
+	algorithm := cfg.Algorithm
+	if algorithm == "" {
+		algorithm = SessionSigningAlgorithmHS256
+	}
+	definition, ok := sessionAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSessionAlgorithm, algorithm)
+	}
+	verificationKeys, err := resolveSessionVerificationKeys(definition, cfg)
+	if err != nil {
+		return nil, err
 	}
 	cookieName := strings.TrimSpace(cfg.CookieName)
 	if cookieName == "" {
 		return nil, ErrMissingSessionCookieName
 	}
+	if cfg.Leeway < 0 {
+		return nil, ErrInvalidSessionLeeway
+	}
 	clock := cfg.Clock
 	if clock == nil {
 		clock = time.Now
 	}
 	return &SessionValidator{
-		signingSecret: append([]byte(nil), cfg.SigningSecret...),
-		issuer:        defaultSessionIssuer,
-		cookieName:    cookieName,
-		clock:         clock,
+		signingMethod:    definition.method,
+		verificationKeys: verificationKeys,
+		issuer:           defaultSessionIssuer,
+		cookieName:       cookieName,
+		clock:            clock,
+		leeway:           cfg.Leeway,
+		revocations:      cfg.RevocationStore,
 	}, nil
 }
 
+func resolveSessionVerificationKeys(definition sessionAlgorithmDefinition, cfg SessionValidatorConfig) ([]sessionVerificationKey, error) {
+	configuredKeys := make([]SigningKey, 0, len(cfg.SigningKeys)+1)
+	if len(cfg.SigningSecret) > 0 || cfg.PublicKey != nil {
+		configuredKeys = append(configuredKeys, SigningKey{Secret: cfg.SigningSecret, PublicKey: cfg.PublicKey})
+	}
+	configuredKeys = append(configuredKeys, cfg.SigningKeys...)
+	if len(configuredKeys) == 0 {
+		return nil, ErrMissingSessionSigningKey
+	}
+
+	verificationKeys := make([]sessionVerificationKey, 0, len(configuredKeys))
+	for _, configuredKey := range configuredKeys {
+		resolvedKey, err := definition.resolveKey(configuredKey)
+		if err != nil {
+			return nil, err
+		}
+		if containsSessionKey(verificationKeys, resolvedKey) {
+			continue
+		}
+		verificationKeys = append(verificationKeys, sessionVerificationKey{
+			keyID: strings.TrimSpace(configuredKey.KeyID),
+			key:   resolvedKey,
+		})
+	}
+	return verificationKeys, nil
+}
+
+func containsSessionKey(verificationKeys []sessionVerificationKey, candidate interface{}) bool {
+	for _, existing := range verificationKeys {
+		if sessionKeysEqual(existing.key, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+func sessionKeysEqual(left, right interface{}) bool {
+	if leftSecret, ok := left.([]byte); ok {
+		rightSecret, ok := right.([]byte)
+		return ok && bytes.Equal(leftSecret, rightSecret)
+	}
+	comparable, ok := left.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && comparable.Equal(right)
+}
+
+func resolveSessionSecret(key SigningKey) (interface{}, error) {
+	if len(key.Secret) == 0 {
+		return nil, ErrMissingSessionSigningKey
+	}
+	return append([]byte(nil), key.Secret...), nil
+}
+
+func resolveSessionRSAKey(key SigningKey) (interface{}, error) {
+	if key.PublicKey == nil {
+		return nil, ErrMissingSessionSigningKey
+	}
+	publicKey, ok := key.PublicKey.(*rsa.PublicKey)
+	if !ok || publicKey == nil {
+		return nil, fmt.Errorf("%w: RS256 requires *rsa.PublicKey", ErrSessionKeyMismatch)
+	}
+	return publicKey, nil
+}
+
+func resolveSessionECDSAKey(key SigningKey) (interface{}, error) {
+	if key.PublicKey == nil {
+		return nil, ErrMissingSessionSigningKey
+	}
+	publicKey, ok := key.PublicKey.(*ecdsa.PublicKey)
+	if !ok || publicKey == nil {
+		return nil, fmt.Errorf("%w: ES256 requires *ecdsa.PublicKey", ErrSessionKeyMismatch)
+	}
+	if publicKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("%w: ES256 requires a P-256 key", ErrSessionKeyMismatch)
+	}
+	return publicKey, nil
+}
+
 // CookieName exposes the configured cookie name.
 func (v *SessionValidator) CookieName() string {
 	return v.cookieName
@@ -84,13 +245,14 @@ func (v *SessionValidator) ValidateToken(tokenString string) (SessionClaims, err
 		token,
 		claims,
 		func(t *jwt.Token) (interface{}, error) {
-			if t.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			if t.Method.Alg() != v.signingMethod.Alg() {
 				return nil, fmt.Errorf("%w: unexpected signing algorithm %s", ErrInvalidSessionToken, t.Method.Alg())
 			}
-			return v.signingSecret, nil
+			return v.keyForToken(t), nil
 		},
 		jwt.WithTimeFunc(v.clock),
-		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithLeeway(v.leeway),
+		jwt.WithValidMethods([]string{v.signingMethod.Alg()}),
 	)
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -107,17 +269,86 @@ func (v *SessionValidator) ValidateToken(tokenString string) (SessionClaims, err
 	if strings.TrimSpace(claims.Subject) == "" || strings.TrimSpace(claims.UserID) == "" {
 		return SessionClaims{}, ErrMissingSessionSubject
 	}
+	if v.revocations != nil {
+		revoked, revocationErr := v.revocations.IsRevoked(sessionTokenID(*claims, token))
+		if revocationErr != nil {
+			return SessionClaims{}, fmt.Errorf("%w: %v", ErrInvalidSessionToken, revocationErr)
+		}
+		if revoked {
+			return SessionClaims{}, ErrRevokedSessionToken
+		}
+	}
 	return *claims, nil
 }
 
-// ValidateRequest extracts the configured cookie from the request and validates it.
+// RevokeSession revokes a still-valid token so later validations fail until it would have expired,
+// including the leeway during which an expired token still validates.
+// Tokens that no longer validate need no revocation and are ignored.
+func (v *SessionValidator) RevokeSession(tokenString string) error {
+	if v.revocations == nil {
+		return nil
+	}
+	token := strings.TrimSpace(tokenString)
+	claims, err := v.ValidateToken(token)
+	if err != nil {
+		return nil
+	}
+	expiresAt := claims.ExpiryTime()
+	if !expiresAt.IsZero() {
+		expiresAt = expiresAt.Add(v.leeway)
+	}
+	return v.revocations.Revoke(sessionTokenID(claims, token), expiresAt)
+}
+
+// sessionTokenID prefers the jti claim and falls back to a digest of the raw token.
+func sessionTokenID(claims SessionClaims, token string) string {
+	if tokenID := strings.TrimSpace(claims.ID); tokenID != "" {
+		return tokenID
+	}
+	digest := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(digest[:])
+}
+
+// ValidateTokenWithExpiry validates the supplied JWT string and additionally returns its expiry.
+// Tokens without an exp claim yield the zero time.
+func (v *SessionValidator) ValidateTokenWithExpiry(tokenString string) (SessionClaims, time.Time, error) {
+	claims, err := v.ValidateToken(tokenString)
+	if err != nil {
+		return SessionClaims{}, time.Time{}, err
+	}
+	return claims, claims.ExpiryTime(), nil
+}
+
+func (v *SessionValidator) keyForToken(token *jwt.Token) interface{} {
+	if keyID, ok := token.Header["kid"].(string); ok && keyID != "" {
+		for _, verificationKey := range v.verificationKeys {
+			if verificationKey.keyID == keyID {
+				return verificationKey.key
+			}
+		}
+	}
+	if len(v.verificationKeys) == 1 {
+		return v.verificationKeys[0].key
+	}
+	keySet := jwt.VerificationKeySet{Keys: make([]jwt.VerificationKey, 0, len(v.verificationKeys))}
+	for _, verificationKey := range v.verificationKeys {
+		keySet.Keys = append(keySet.Keys, verificationKey.key)
+	}
+	return keySet
+}
+
+// ValidateRequest extracts the session token from the configured cookie, falling back to an
+// Authorization bearer header, and validates it. The cookie wins when both are present.
 func (v *SessionValidator) ValidateRequest(r *http.Request) (SessionClaims, error) {
 	if r == nil {
 		return SessionClaims{}, ErrMissingSessionToken
 	}
-	cookie, err := r.Cookie(v.cookieName)
-	if err != nil || cookie == nil {
-		return SessionClaims{}, ErrMissingSessionToken
+	if cookie, err := r.Cookie(v.cookieName); err == nil && cookie != nil && strings.TrimSpace(cookie.Value) != "" {
+		return v.ValidateToken(cookie.Value)
+	}
+	header := r.Header.Get(authorizationHeader)
+	if strings.HasPrefix(header, bearerPrefix) {
+		return v.ValidateToken(strings.TrimPrefix(header, bearerPrefix))
 	}
-	return v.ValidateToken(cookie.Value)
+	return SessionClaims{}, ErrMissingSessionToken
 }
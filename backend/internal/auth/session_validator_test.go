@@ -1,6 +1,11 @@
 package auth
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -124,3 +129,375 @@ func TestSessionValidatorValidateRequestUsesCookie(t *testing.T) {
 		t.Fatalf("unexpected user id: %s", claims.UserID)
 	}
 }
+
+func TestSessionValidatorValidateTokenAsymmetricAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate rsa key: %v", err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ecdsa key: %v", err)
+	}
+
+	testCases := []struct {
+		name          string
+		algorithm     SessionSigningAlgorithm
+		publicKey     interface{}
+		signingMethod jwt.SigningMethod
+		signingKey    interface{}
+	}{
+		{
+			name:          "rs256",
+			algorithm:     SessionSigningAlgorithmRS256,
+			publicKey:     &rsaKey.PublicKey,
+			signingMethod: jwt.SigningMethodRS256,
+			signingKey:    rsaKey,
+		},
+		{
+			name:          "es256",
+			algorithm:     SessionSigningAlgorithmES256,
+			publicKey:     &ecdsaKey.PublicKey,
+			signingMethod: jwt.SigningMethodES256,
+			signingKey:    ecdsaKey,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			validator, err := NewSessionValidator(SessionValidatorConfig{
+				Algorithm:  testCase.algorithm,
+				PublicKey:  testCase.publicKey,
+				CookieName: testSessionCookieName,
+			})
+			if err != nil {
+				t.Fatalf("failed to construct validator: %v", err)
+			}
+
+			signed := mustSignSessionToken(t, testCase.signingMethod, testCase.signingKey)
+			claims, err := validator.ValidateToken(signed)
+			if err != nil {
+				t.Fatalf("unexpected validation failure: %v", err)
+			}
+			if claims.UserID != testSessionUserID {
+				t.Fatalf("unexpected user id: %s", claims.UserID)
+			}
+
+			downgraded := mustSignSessionToken(t, jwt.SigningMethodHS256, []byte(testSessionSigningSecret))
+			if _, err := validator.ValidateToken(downgraded); !errors.Is(err, ErrInvalidSessionToken) {
+				t.Fatalf("expected HS256 token to be rejected, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewSessionValidatorRejectsMismatchedKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate rsa key: %v", err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ecdsa key: %v", err)
+	}
+
+	testCases := []struct {
+		name    string
+		config  SessionValidatorConfig
+		wantErr error
+	}{
+		{
+			name:    "unsupported-algorithm",
+			config:  SessionValidatorConfig{Algorithm: "none", SigningSecret: []byte(testSessionSigningSecret)},
+			wantErr: ErrUnsupportedSessionAlgorithm,
+		},
+		{
+			name:    "hs256-missing-secret",
+			config:  SessionValidatorConfig{Algorithm: SessionSigningAlgorithmHS256, PublicKey: &rsaKey.PublicKey},
+			wantErr: ErrMissingSessionSigningKey,
+		},
+		{
+			name:    "rs256-missing-key",
+			config:  SessionValidatorConfig{Algorithm: SessionSigningAlgorithmRS256, SigningSecret: []byte(testSessionSigningSecret)},
+			wantErr: ErrMissingSessionSigningKey,
+		},
+		{
+			name:    "rs256-ecdsa-key",
+			config:  SessionValidatorConfig{Algorithm: SessionSigningAlgorithmRS256, PublicKey: &ecdsaKey.PublicKey},
+			wantErr: ErrSessionKeyMismatch,
+		},
+		{
+			name:    "es256-rsa-key",
+			config:  SessionValidatorConfig{Algorithm: SessionSigningAlgorithmES256, PublicKey: &rsaKey.PublicKey},
+			wantErr: ErrSessionKeyMismatch,
+		},
+		{
+			name:    "es256-wrong-curve",
+			config:  SessionValidatorConfig{Algorithm: SessionSigningAlgorithmES256, PublicKey: &ecdsaKey.PublicKey},
+			wantErr: ErrSessionKeyMismatch,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.config.CookieName = testSessionCookieName
+			if _, err := NewSessionValidator(testCase.config); !errors.Is(err, testCase.wantErr) {
+				t.Fatalf("expected %v, got %v", testCase.wantErr, err)
+			}
+		})
+	}
+}
+
+func mustSignSessionToken(t *testing.T, method jwt.SigningMethod, key interface{}) string {
+	t.Helper()
+	return mustSignSessionTokenWithKeyID(t, method, "", key)
+}
+
+func TestSessionValidatorValidateTokenWithRotatedKeys(t *testing.T) {
+	const (
+		currentSecret  = "current-secret"
+		previousSecret = "previous-secret"
+		unknownSecret  = "unknown-secret"
+	)
+	validator, err := NewSessionValidator(SessionValidatorConfig{
+		SigningKeys: []SigningKey{
+			{KeyID: "current", Secret: []byte(currentSecret)},
+			{KeyID: "previous", Secret: []byte(previousSecret)},
+		},
+		CookieName: testSessionCookieName,
+	})
+	if err != nil {
+		t.Fatalf("failed to construct validator: %v", err)
+	}
+
+	testCases := []struct {
+		name      string
+		keyID     string
+		secret    string
+		wantValid bool
+	}{
+		{name: "second-key-without-kid", secret: previousSecret, wantValid: true},
+		{name: "second-key-with-kid", keyID: "previous", secret: previousSecret, wantValid: true},
+		{name: "unknown-kid-falls-back", keyID: "retired", secret: previousSecret, wantValid: true},
+		{name: "kid-mismatched-secret", keyID: "current", secret: previousSecret, wantValid: false},
+		{name: "unknown-secret", secret: unknownSecret, wantValid: false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			signed := mustSignSessionTokenWithKeyID(t, jwt.SigningMethodHS256, testCase.keyID, []byte(testCase.secret))
+			_, err := validator.ValidateToken(signed)
+			if testCase.wantValid && err != nil {
+				t.Fatalf("unexpected validation failure: %v", err)
+			}
+			if !testCase.wantValid && !errors.Is(err, ErrInvalidSessionToken) {
+				t.Fatalf("expected invalid token error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewSessionValidatorSigningKeySet(t *testing.T) {
+	if _, err := NewSessionValidator(SessionValidatorConfig{CookieName: testSessionCookieName}); !errors.Is(err, ErrMissingSessionSigningKey) {
+		t.Fatalf("expected missing signing key error, got %v", err)
+	}
+
+	validator, err := NewSessionValidator(SessionValidatorConfig{
+		SigningSecret: []byte(testSessionSigningSecret),
+		SigningKeys: []SigningKey{
+			{KeyID: "duplicate", Secret: []byte(testSessionSigningSecret)},
+			{KeyID: "next", Secret: []byte("next-secret")},
+		},
+		CookieName: testSessionCookieName,
+	})
+	if err != nil {
+		t.Fatalf("failed to construct validator: %v", err)
+	}
+	if len(validator.verificationKeys) != 2 {
+		t.Fatalf("expected duplicate secrets to collapse into 2 keys, got %d", len(validator.verificationKeys))
+	}
+}
+
+func mustSignSessionTokenWithKeyID(t *testing.T, method jwt.SigningMethod, keyID string, key interface{}) string {
+	t.Helper()
+	now := time.Now()
+	token := jwt.NewWithClaims(method, SessionClaims{
+		UserID: testSessionUserID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    defaultSessionIssuer,
+			Subject:   testSessionUserID,
+			IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	})
+	if keyID != "" {
+		token.Header["kid"] = keyID
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func TestSessionValidatorValidateTokenWithExpiry(t *testing.T) {
+	clockNow := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	validator, err := NewSessionValidator(SessionValidatorConfig{
+		SigningSecret: []byte(testSessionSigningSecret),
+		CookieName:    testSessionCookieName,
+		Clock: func() time.Time {
+			return clockNow
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to construct validator: %v", err)
+	}
+
+	nearExpiry := clockNow.Add(time.Second)
+	testCases := []struct {
+		name       string
+		expiresAt  *jwt.NumericDate
+		wantExpiry time.Time
+	}{
+		{name: "near-expiry", expiresAt: jwt.NewNumericDate(nearExpiry), wantExpiry: nearExpiry},
+		{name: "without-exp", expiresAt: nil, wantExpiry: time.Time{}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, SessionClaims{
+				UserID: testSessionUserID,
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer:    defaultSessionIssuer,
+					Subject:   testSessionUserID,
+					IssuedAt:  jwt.NewNumericDate(clockNow.Add(-time.Minute)),
+					ExpiresAt: testCase.expiresAt,
+				},
+			})
+			signed, err := token.SignedString([]byte(testSessionSigningSecret))
+			if err != nil {
+				t.Fatalf("failed to sign token: %v", err)
+			}
+
+			claims, expiry, err := validator.ValidateTokenWithExpiry(signed)
+			if err != nil {
+				t.Fatalf("unexpected validation failure: %v", err)
+			}
+			if claims.UserID != testSessionUserID {
+				t.Fatalf("unexpected user id: %s", claims.UserID)
+			}
+			if !expiry.Equal(testCase.wantExpiry) {
+				t.Fatalf("unexpected expiry: got %v want %v", expiry, testCase.wantExpiry)
+			}
+		})
+	}
+}
+
+func TestSessionValidatorValidateRequestTokenSources(t *testing.T) {
+	validator, err := NewSessionValidator(SessionValidatorConfig{
+		SigningSecret: []byte(testSessionSigningSecret),
+		CookieName:    testSessionCookieName,
+	})
+	if err != nil {
+		t.Fatalf("failed to construct validator: %v", err)
+	}
+	signed := mustSignSessionToken(t, jwt.SigningMethodHS256, []byte(testSessionSigningSecret))
+
+	testCases := []struct {
+		name       string
+		cookie     string
+		header     string
+		wantErr    error
+		wantUserID string
+	}{
+		{name: "header-only", header: "Bearer " + signed, wantUserID: testSessionUserID},
+		{name: "cookie-only", cookie: signed, wantUserID: testSessionUserID},
+		{name: "cookie-wins-over-header", cookie: signed, header: "Bearer not-a-token", wantUserID: testSessionUserID},
+		{name: "invalid-cookie-not-rescued-by-header", cookie: "not-a-token", header: "Bearer " + signed, wantErr: ErrInvalidSessionToken},
+		{name: "non-bearer-header", header: "Basic " + signed, wantErr: ErrMissingSessionToken},
+		{name: "no-token", wantErr: ErrMissingSessionToken},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/notes", http.NoBody)
+			if testCase.cookie != "" {
+				request.AddCookie(&http.Cookie{Name: testSessionCookieName, Value: testCase.cookie})
+			}
+			if testCase.header != "" {
+				request.Header.Set("Authorization", testCase.header)
+			}
+
+			claims, err := validator.ValidateRequest(request)
+			if testCase.wantErr != nil {
+				if !errors.Is(err, testCase.wantErr) {
+					t.Fatalf("expected %v, got %v", testCase.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validation failed: %v", err)
+			}
+			if claims.UserID != testCase.wantUserID {
+				t.Fatalf("unexpected user id: %s", claims.UserID)
+			}
+		})
+	}
+}
+
+func TestSessionValidatorValidateTokenLeeway(t *testing.T) {
+	clockNow := time.Date(2024, 9, 1, 12, 0, 0, 0, time.UTC)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, SessionClaims{
+		UserID: testSessionUserID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    defaultSessionIssuer,
+			Subject:   testSessionUserID,
+			IssuedAt:  jwt.NewNumericDate(clockNow.Add(-time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(clockNow.Add(-20 * time.Second)),
+		},
+	})
+	signed, err := token.SignedString([]byte(testSessionSigningSecret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	testCases := []struct {
+		name    string
+		leeway  time.Duration
+		wantErr error
+	}{
+		{name: "within-leeway", leeway: 30 * time.Second},
+		{name: "without-leeway", wantErr: ErrExpiredSessionToken},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			validator, err := NewSessionValidator(SessionValidatorConfig{
+				SigningSecret: []byte(testSessionSigningSecret),
+				CookieName:    testSessionCookieName,
+				Leeway:        testCase.leeway,
+				Clock: func() time.Time {
+					return clockNow
+				},
+			})
+			if err != nil {
+				t.Fatalf("failed to construct validator: %v", err)
+			}
+			_, err = validator.ValidateToken(signed)
+			if testCase.wantErr == nil && err != nil {
+				t.Fatalf("unexpected validation failure: %v", err)
+			}
+			if testCase.wantErr != nil && !errors.Is(err, testCase.wantErr) {
+				t.Fatalf("expected %v, got %v", testCase.wantErr, err)
+			}
+		})
+	}
+
+	if _, err := NewSessionValidator(SessionValidatorConfig{
+		SigningSecret: []byte(testSessionSigningSecret),
+		CookieName:    testSessionCookieName,
+		Leeway:        -time.Second,
+	}); !errors.Is(err, ErrInvalidSessionLeeway) {
+		t.Fatalf("expected negative leeway to be rejected, got %v", err)
+	}
+}
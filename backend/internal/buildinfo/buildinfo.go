@@ -0,0 +1,27 @@
+// Package buildinfo holds release metadata injected at link time, e.g.
+//
+//	go build -ldflags "-X github.com/MarcoPoloResearchLab/gravity/backend/internal/buildinfo.Version=v1.2.3"
+package buildinfo
+
+// Version, Commit and Date are overridden with -ldflags -X; the defaults identify a local development build.
+var (
+	Version = "dev"
+	Commit  = "unknown"
+	Date    = "unknown"
+)
+
+// Info is a snapshot of the embedded build metadata.
+type Info struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Date    string `json:"date"`
+}
+
+// Current returns the build metadata linked into the running binary.
+func Current() Info {
+	return Info{
+		Version: Version,
+		Commit:  Commit,
+		Date:    Date,
+	}
+}
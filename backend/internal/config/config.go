@@ -2,26 +2,70 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/dbconfig"
 	"github.com/spf13/viper"
 )
 
 const (
-	envPrefix           = "GRAVITY"
-	defaultHTTPAddress  = "0.0.0.0:8080"
-	defaultDatabasePath = "gravity.db"
-	defaultLogLevel     = "info"
-	defaultCookieName   = "app_session"
+	envPrefix              = "GRAVITY"
+	defaultHTTPAddress     = "0.0.0.0:8080"
+	defaultDatabasePath    = "gravity.db"
+	defaultLogLevel        = "info"
+	defaultLogFormat       = "json"
+	logFormatConsole       = "console"
+	defaultCookieName      = "app_session"
+	defaultCORSMaxAge      = 12 * time.Hour
+	defaultSyncBusyRetries = 3
 )
 
 // AppConfig captures runtime configuration for the API server.
 type AppConfig struct {
-	HTTPAddress     string
-	TAuthSigningKey string
-	TAuthCookieName string
-	DatabasePath    string
-	LogLevel        string
+	HTTPAddress           string
+	HTTPMaxBodySize       int64
+	HTTPUnversionedRoutes bool
+	HTTPStreamIdleTimeout time.Duration
+	TAuthSigningKey       string
+	TAuthCookieName       string
+	TAuthLeeway           time.Duration
+	DatabaseDriver        string
+	DatabasePath          string
+	DatabaseDSN           string
+	DatabasePool          DatabasePoolConfig
+	LogLevel              string
+	LogFormat             string
+	LogSampling           LogSamplingConfig
+	MetricsEnabled        bool
+	RateLimitRPS          float64
+	RateLimitBurst        int
+	MaxNotesPerUser       int
+	MaxUpdatesPerSync     int
+	SyncTimeout           time.Duration
+	SyncBusyRetries       int
+	CompactionThreshold   int
+	CORSAllowedMethods    []string
+	CORSMaxAge            time.Duration
+}
+
+// DatabasePoolConfig captures connection pool limits and SQLite concurrency pragmas.
+type DatabasePoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	BusyTimeout     time.Duration
+	JournalMode     string
+}
+
+// LogSamplingConfig tunes how repeated log entries are sampled; zero values keep the encoding default.
+type LogSamplingConfig struct {
+	Initial    int
+	Thereafter int
+	Disabled   bool
 }
 
 // NewViper returns a viper instance with defaults and env bindings configured.
@@ -38,21 +82,25 @@ func ApplyDefaults(configViper *viper.Viper) {
 	configViper.AutomaticEnv()
 
 	configViper.SetDefault("http.address", defaultHTTPAddress)
+	configViper.SetDefault("http.unversioned_routes", true)
+	configViper.SetDefault("database.driver", dbconfig.DriverSQLite)
 	configViper.SetDefault("database.path", defaultDatabasePath)
 	configViper.SetDefault("log.level", defaultLogLevel)
+	configViper.SetDefault("log.format", defaultLogFormat)
 	configViper.SetDefault("tauth.cookie_name", defaultCookieName)
+	configViper.SetDefault("cors.max_age", defaultCORSMaxAge)
+	configViper.SetDefault("notes.sync_busy_retries", defaultSyncBusyRetries)
 }
 
 // Load parses runtime configuration from viper.
+// When tauth.signing_secret_file is set, the secret is read from that file and overrides tauth.signing_secret.
 func Load(configViper *viper.Viper) (AppConfig, error) {
-	cfg := AppConfig{
-		HTTPAddress:     configViper.GetString("http.address"),
-		TAuthSigningKey: configViper.GetString("tauth.signing_secret"),
-		TAuthCookieName: configViper.GetString("tauth.cookie_name"),
-		DatabasePath:    configViper.GetString("database.path"),
-		LogLevel:        configViper.GetString("log.level"),
+	cfg := read(configViper)
+	signingSecret, err := loadSigningSecret(cfg.TAuthSigningKey, configViper.GetString("tauth.signing_secret_file"))
+	if err != nil {
+		return AppConfig{}, err
 	}
-
+	cfg.TAuthSigningKey = signingSecret
 	if err := cfg.validate(); err != nil {
 		return AppConfig{}, err
 	}
@@ -60,15 +108,176 @@ func Load(configViper *viper.Viper) (AppConfig, error) {
 	return cfg, nil
 }
 
+// LoadDatabase parses runtime configuration but validates only the database and logging settings,
+// for commands such as migrate that never serve requests.
+func LoadDatabase(configViper *viper.Viper) (AppConfig, error) {
+	cfg := read(configViper)
+	if err := cfg.validateDatabase(); err != nil {
+		return AppConfig{}, err
+	}
+	if err := cfg.validateLogging(); err != nil {
+		return AppConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// loadSigningSecret prefers the mounted secret file, trimming the trailing newline editors and secret stores add.
+func loadSigningSecret(inlineSecret, secretFile string) (string, error) {
+	path := strings.TrimSpace(secretFile)
+	if path == "" {
+		return inlineSecret, nil
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("tauth.signing_secret_file: %w", err)
+	}
+	secret := strings.TrimRight(string(contents), "\r\n")
+	if strings.TrimSpace(secret) == "" {
+		return "", fmt.Errorf("tauth.signing_secret_file %s is empty", path)
+	}
+	return secret, nil
+}
+
+func read(configViper *viper.Viper) AppConfig {
+	return AppConfig{
+		HTTPAddress:           strings.TrimSpace(configViper.GetString("http.address")),
+		HTTPMaxBodySize:       configViper.GetInt64("http.max_body_bytes"),
+		HTTPUnversionedRoutes: configViper.GetBool("http.unversioned_routes"),
+		HTTPStreamIdleTimeout: configViper.GetDuration("http.stream_idle_timeout"),
+		TAuthSigningKey:       configViper.GetString("tauth.signing_secret"),
+		TAuthCookieName:       configViper.GetString("tauth.cookie_name"),
+		TAuthLeeway:           configViper.GetDuration("tauth.leeway"),
+		DatabaseDriver:        configViper.GetString("database.driver"),
+		DatabasePath:          configViper.GetString("database.path"),
+		DatabaseDSN:           configViper.GetString("database.dsn"),
+		DatabasePool: DatabasePoolConfig{
+			MaxOpenConns:    configViper.GetInt("database.max_open_conns"),
+			MaxIdleConns:    configViper.GetInt("database.max_idle_conns"),
+			ConnMaxLifetime: configViper.GetDuration("database.conn_max_lifetime"),
+			BusyTimeout:     configViper.GetDuration("database.busy_timeout"),
+			JournalMode:     configViper.GetString("database.journal_mode"),
+		},
+		LogLevel:  configViper.GetString("log.level"),
+		LogFormat: strings.ToLower(strings.TrimSpace(configViper.GetString("log.format"))),
+		LogSampling: LogSamplingConfig{
+			Initial:    configViper.GetInt("log.sampling.initial"),
+			Thereafter: configViper.GetInt("log.sampling.thereafter"),
+			Disabled:   configViper.GetBool("log.sampling.disabled"),
+		},
+		MetricsEnabled:      configViper.GetBool("metrics.enabled"),
+		RateLimitRPS:        configViper.GetFloat64("ratelimit.requests_per_second"),
+		RateLimitBurst:      configViper.GetInt("ratelimit.burst"),
+		MaxNotesPerUser:     configViper.GetInt("notes.max_per_user"),
+		MaxUpdatesPerSync:   configViper.GetInt("notes.max_updates_per_sync"),
+		SyncTimeout:         configViper.GetDuration("notes.sync_timeout"),
+		SyncBusyRetries:     configViper.GetInt("notes.sync_busy_retries"),
+		CompactionThreshold: configViper.GetInt("notes.compaction_threshold"),
+		CORSAllowedMethods:  splitList(configViper.GetString("cors.allowed_methods")),
+		CORSMaxAge:          configViper.GetDuration("cors.max_age"),
+	}
+}
+
 func (c AppConfig) validate() error {
 	if strings.TrimSpace(c.TAuthSigningKey) == "" {
-		return fmt.Errorf("tauth.signing_secret is required")
+		return fmt.Errorf("tauth.signing_secret or tauth.signing_secret_file is required")
+	}
+	if err := validateHTTPAddress(c.HTTPAddress); err != nil {
+		return err
+	}
+	if c.HTTPMaxBodySize < 0 {
+		return fmt.Errorf("http.max_body_bytes must not be negative")
 	}
-	if strings.TrimSpace(c.DatabasePath) == "" {
-		return fmt.Errorf("database.path is required")
+	if c.HTTPStreamIdleTimeout < 0 {
+		return fmt.Errorf("http.stream_idle_timeout must not be negative")
+	}
+	if err := c.validateDatabase(); err != nil {
+		return err
+	}
+	if err := c.validateLogging(); err != nil {
+		return err
 	}
 	if strings.TrimSpace(c.TAuthCookieName) == "" {
 		return fmt.Errorf("tauth.cookie_name is required")
 	}
+	if c.TAuthLeeway < 0 {
+		return fmt.Errorf("tauth.leeway must not be negative")
+	}
+	if c.RateLimitRPS < 0 {
+		return fmt.Errorf("ratelimit.requests_per_second must not be negative")
+	}
+	if c.RateLimitBurst < 0 {
+		return fmt.Errorf("ratelimit.burst must not be negative")
+	}
+	if c.MaxNotesPerUser < 0 {
+		return fmt.Errorf("notes.max_per_user must not be negative")
+	}
+	if c.MaxUpdatesPerSync < 0 {
+		return fmt.Errorf("notes.max_updates_per_sync must not be negative")
+	}
+	if c.SyncTimeout < 0 {
+		return fmt.Errorf("notes.sync_timeout must not be negative")
+	}
+	if c.SyncBusyRetries < 0 {
+		return fmt.Errorf("notes.sync_busy_retries must not be negative")
+	}
+	if c.CompactionThreshold < 0 {
+		return fmt.Errorf("notes.compaction_threshold must not be negative")
+	}
+	if c.CORSMaxAge < 0 {
+		return fmt.Errorf("cors.max_age must not be negative")
+	}
+	return nil
+}
+
+// splitList parses a comma-separated setting, dropping blank entries.
+func splitList(raw string) []string {
+	var values []string
+	for _, value := range strings.Split(raw, ",") {
+		if trimmed := strings.TrimSpace(value); trimmed != "" {
+			values = append(values, trimmed)
+		}
+	}
+	return values
+}
+
+// validateHTTPAddress accepts host:port with an optional host; the port must be numeric and in range.
+func validateHTTPAddress(address string) error {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("http.address %q must be host:port: %w", address, err)
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil || portNumber < 1 || portNumber > 65535 {
+		return fmt.Errorf("http.address %q must include a port between 1 and 65535", address)
+	}
 	return nil
 }
+
+func (c AppConfig) validateLogging() error {
+	if c.LogFormat != defaultLogFormat && c.LogFormat != logFormatConsole {
+		return fmt.Errorf("log.format must be %q or %q", defaultLogFormat, logFormatConsole)
+	}
+	if c.LogSampling.Initial < 0 || c.LogSampling.Thereafter < 0 {
+		return fmt.Errorf("log.sampling values must not be negative")
+	}
+	return nil
+}
+
+func (c AppConfig) validateDatabase() error {
+	return c.Database().Validate()
+}
+
+// Database maps the database settings onto the connection config that database.Open and database.Connect take.
+func (c AppConfig) Database() dbconfig.Config {
+	return dbconfig.Config{
+		Driver:          c.DatabaseDriver,
+		Path:            c.DatabasePath,
+		DSN:             c.DatabaseDSN,
+		MaxOpenConns:    c.DatabasePool.MaxOpenConns,
+		MaxIdleConns:    c.DatabasePool.MaxIdleConns,
+		ConnMaxLifetime: c.DatabasePool.ConnMaxLifetime,
+		BusyTimeout:     c.DatabasePool.BusyTimeout,
+		JournalMode:     c.DatabasePool.JournalMode,
+	}
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSigningSecret = "inline-secret"
+
+func TestLoadReadsSigningSecretFile(t *testing.T) {
+	secretDir := t.TempDir()
+	secretPath := filepath.Join(secretDir, "signing_secret")
+	if err := os.WriteFile(secretPath, []byte("file-secret\n"), 0o600); err != nil {
+		t.Fatalf("failed to write secret file: %v", err)
+	}
+	emptyPath := filepath.Join(secretDir, "empty_secret")
+	if err := os.WriteFile(emptyPath, []byte("\n"), 0o600); err != nil {
+		t.Fatalf("failed to write empty secret file: %v", err)
+	}
+
+	testCases := []struct {
+		name           string
+		inlineSecret   string
+		secretFile     string
+		expectedSecret string
+		expectedError  string
+	}{
+		{name: "file-only", secretFile: secretPath, expectedSecret: "file-secret"},
+		{name: "file-overrides-inline", inlineSecret: testSigningSecret, secretFile: secretPath, expectedSecret: "file-secret"},
+		{name: "inline-only", inlineSecret: testSigningSecret, expectedSecret: testSigningSecret},
+		{name: "missing-file", secretFile: filepath.Join(secretDir, "missing"), expectedError: "tauth.signing_secret_file"},
+		{name: "empty-file", inlineSecret: testSigningSecret, secretFile: emptyPath, expectedError: "is empty"},
+		{name: "neither", expectedError: "tauth.signing_secret or tauth.signing_secret_file is required"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configViper := NewViper()
+			configViper.Set("tauth.signing_secret", testCase.inlineSecret)
+			configViper.Set("tauth.signing_secret_file", testCase.secretFile)
+
+			cfg, err := Load(configViper)
+			if testCase.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), testCase.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", testCase.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("load failed: %v", err)
+			}
+			if cfg.TAuthSigningKey != testCase.expectedSecret {
+				t.Fatalf("expected signing secret %q, got %q", testCase.expectedSecret, cfg.TAuthSigningKey)
+			}
+		})
+	}
+}
+
+func TestLoadValidatesHTTPAddress(t *testing.T) {
+	testCases := []struct {
+		name            string
+		address         string
+		expectedAddress string
+		valid           bool
+	}{
+		{name: "all-interfaces", address: ":8080", expectedAddress: ":8080", valid: true},
+		{name: "explicit-host", address: "0.0.0.0:8080", expectedAddress: "0.0.0.0:8080", valid: true},
+		{name: "hostname", address: "localhost:9000", expectedAddress: "localhost:9000", valid: true},
+		{name: "ipv6", address: "[::1]:8080", expectedAddress: "[::1]:8080", valid: true},
+		{name: "surrounding-whitespace", address: "  :8080 ", expectedAddress: ":8080", valid: true},
+		{name: "missing-colon", address: "8080"},
+		{name: "empty-port", address: "localhost:"},
+		{name: "non-numeric-port", address: "localhost:http"},
+		{name: "port-out-of-range", address: ":70000"},
+		{name: "port-zero", address: ":0"},
+		{name: "empty", address: ""},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configViper := NewViper()
+			configViper.Set("tauth.signing_secret", testSigningSecret)
+			configViper.Set("http.address", testCase.address)
+
+			cfg, err := Load(configViper)
+			if !testCase.valid {
+				if err == nil || !strings.Contains(err.Error(), "http.address") {
+					t.Fatalf("expected http.address error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("load failed: %v", err)
+			}
+			if cfg.HTTPAddress != testCase.expectedAddress {
+				t.Fatalf("expected address %q, got %q", testCase.expectedAddress, cfg.HTTPAddress)
+			}
+		})
+	}
+}
+
+func TestLoadReadsCORSSettings(t *testing.T) {
+	configViper := NewViper()
+	configViper.Set("tauth.signing_secret", testSigningSecret)
+	configViper.Set("cors.allowed_methods", " GET, POST ,PUT,, DELETE ")
+
+	cfg, err := Load(configViper)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if got := strings.Join(cfg.CORSAllowedMethods, ","); got != "GET,POST,PUT,DELETE" {
+		t.Fatalf("unexpected allowed methods %q", got)
+	}
+	if cfg.CORSMaxAge != defaultCORSMaxAge {
+		t.Fatalf("expected default max age %s, got %s", defaultCORSMaxAge, cfg.CORSMaxAge)
+	}
+
+	configViper.Set("cors.max_age", "-1s")
+	if _, err := Load(configViper); err == nil || !strings.Contains(err.Error(), "cors.max_age") {
+		t.Fatalf("expected negative max age to be rejected, got %v", err)
+	}
+}
+
+func TestLoadValidatesDatabaseSettings(t *testing.T) {
+	testCases := []struct {
+		name          string
+		settings      map[string]any
+		expectedError string
+	}{
+		{name: "defaults", settings: map[string]any{}},
+		{name: "unknown-driver", settings: map[string]any{"database.driver": "mysql"}, expectedError: "database driver must be"},
+		{name: "postgres-without-dsn", settings: map[string]any{"database.driver": "postgres"}, expectedError: "database dsn is required"},
+		{name: "negative-max-open", settings: map[string]any{"database.max_open_conns": -1}, expectedError: "max open connections"},
+		{name: "unknown-journal-mode", settings: map[string]any{"database.journal_mode": "MEMORYISH"}, expectedError: "journal mode"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			configViper := NewViper()
+			configViper.Set("tauth.signing_secret", testSigningSecret)
+			for key, value := range testCase.settings {
+				configViper.Set(key, value)
+			}
+
+			_, err := Load(configViper)
+			if testCase.expectedError == "" {
+				if err != nil {
+					t.Fatalf("load failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), testCase.expectedError) {
+				t.Fatalf("expected error containing %q, got %v", testCase.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestLoadDefaultsSyncBusyRetries(t *testing.T) {
+	configViper := NewViper()
+	configViper.Set("tauth.signing_secret", testSigningSecret)
+	cfg, err := Load(configViper)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if cfg.SyncBusyRetries != defaultSyncBusyRetries {
+		t.Fatalf("expected %d busy retries by default, got %d", defaultSyncBusyRetries, cfg.SyncBusyRetries)
+	}
+
+	configViper.Set("notes.sync_busy_retries", 0)
+	cfg, err = Load(configViper)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if cfg.SyncBusyRetries != 0 {
+		t.Fatalf("expected an explicit zero to disable retries, got %d", cfg.SyncBusyRetries)
+	}
+}
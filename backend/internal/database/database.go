@@ -0,0 +1,136 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/dbconfig"
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/notes"
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/users"
+	sqlite "github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const (
+	// DriverSQLite selects the embedded SQLite driver; DatabaseConfig.Path names the database file.
+	DriverSQLite = dbconfig.DriverSQLite
+	// DriverPostgres selects the PostgreSQL driver; DatabaseConfig.DSN holds the connection string.
+	DriverPostgres = dbconfig.DriverPostgres
+
+	// JournalModeWAL lets SQLite readers proceed while a writer holds the database.
+	JournalModeWAL = dbconfig.JournalModeWAL
+	// JournalModeDelete is SQLite's default rollback journal.
+	JournalModeDelete = dbconfig.JournalModeDelete
+
+	defaultSQLiteMaxOpenConns = 1
+)
+
+// DatabaseConfig selects the GORM driver, the location of the database and connection pool limits.
+// Its fields and validation live in dbconfig so configuration loading can check them without importing GORM.
+type DatabaseConfig = dbconfig.Config
+
+// sqliteDSN appends the configured pragmas so every pooled connection applies them.
+func sqliteDSN(cfg DatabaseConfig) string {
+	var pragmas []string
+	if cfg.BusyTimeout > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("_pragma=busy_timeout(%d)", cfg.BusyTimeout.Milliseconds()))
+	}
+	if cfg.JournalMode != "" {
+		pragmas = append(pragmas, fmt.Sprintf("_pragma=journal_mode(%s)", strings.ToUpper(cfg.JournalMode)))
+	}
+	if len(pragmas) == 0 {
+		return cfg.Path
+	}
+	separator := "?"
+	if strings.Contains(cfg.Path, "?") {
+		separator = "&"
+	}
+	return cfg.Path + separator + strings.Join(pragmas, "&")
+}
+
+// Open establishes a connection with the configured driver and performs schema migrations.
+func Open(cfg DatabaseConfig, logger *zap.Logger) (*gorm.DB, error) {
+	db, err := Connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Migrate(db, logger); err != nil {
+		return nil, err
+	}
+
+	if logger != nil {
+		target := ""
+		if cfg.Driver == DriverSQLite {
+			target = cfg.Path
+		}
+		logger.Info("database initialized", zap.String("driver", cfg.Driver), zap.String("path", target))
+	}
+
+	return db, nil
+}
+
+// Connect establishes a connection with the configured driver and pool limits without touching the schema.
+func Connect(cfg DatabaseConfig) (*gorm.DB, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var dialector gorm.Dialector
+	if cfg.Driver == DriverPostgres {
+		dialector = postgres.Open(cfg.DSN)
+	} else {
+		dialector = sqlite.Open(sqliteDSN(cfg))
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	maxOpenConns := cfg.MaxOpenConns
+	if maxOpenConns == 0 && cfg.Driver == DriverSQLite {
+		maxOpenConns = defaultSQLiteMaxOpenConns
+	}
+	sqlDB.SetMaxOpenConns(maxOpenConns)
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+
+	return db, nil
+}
+
+// Migrate brings the schema up to date: it auto-migrates the models and applies pending named migrations.
+func Migrate(db *gorm.DB, logger *zap.Logger) error {
+	if err := db.AutoMigrate(&notes.CrdtUpdate{}, &notes.CrdtSnapshot{}, &notes.NoteTag{}, &notes.NoteQuotaLock{}, &users.Identity{}, &migrationRecord{}); err != nil {
+		return err
+	}
+
+	if err := migrateUserIDs(db); err != nil && logger != nil {
+		logger.Warn("user id migration failed", zap.Error(err))
+	}
+
+	return applyMigrations(db, logger)
+}
+
+// migrateUserIDs strips the legacy google: prefix from stored user ids.
+// substr and LIKE behave identically in SQLite and PostgreSQL.
+func migrateUserIDs(db *gorm.DB) error {
+	const prefix = "google:"
+	start := len(prefix) + 1
+	updateCrdtUpdates := fmt.Sprintf("UPDATE note_crdt_updates SET user_id = substr(user_id, %d) WHERE user_id LIKE '%s%%';", start, prefix)
+	if err := db.Exec(updateCrdtUpdates).Error; err != nil {
+		return err
+	}
+	updateCrdtSnapshots := fmt.Sprintf("UPDATE note_crdt_snapshots SET user_id = substr(user_id, %d) WHERE user_id LIKE '%s%%';", start, prefix)
+	return db.Exec(updateCrdtSnapshots).Error
+}
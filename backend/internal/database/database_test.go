@@ -0,0 +1,153 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/notes"
+	"go.uber.org/zap"
+)
+
+const postgresTestDSNEnv = "GRAVITY_TEST_POSTGRES_DSN"
+
+func TestOpenValidatesDriverConfiguration(testContext *testing.T) {
+	testCases := []struct {
+		name   string
+		config DatabaseConfig
+	}{
+		{name: "unknown-driver", config: DatabaseConfig{Driver: "mysql", DSN: "user@/db"}},
+		{name: "sqlite-without-path", config: DatabaseConfig{Driver: DriverSQLite}},
+		{name: "postgres-without-dsn", config: DatabaseConfig{Driver: DriverPostgres}},
+	}
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(testContext *testing.T) {
+			if _, err := Open(testCase.config, zap.NewNop()); err == nil {
+				testContext.Fatal("expected configuration error")
+			}
+		})
+	}
+}
+
+func TestOpenSQLiteAppliesMigrations(testContext *testing.T) {
+	db, err := Open(DatabaseConfig{Driver: DriverSQLite, Path: filepath.Join(testContext.TempDir(), "open.db")}, zap.NewNop())
+	if err != nil {
+		testContext.Fatalf("failed to open sqlite: %v", err)
+	}
+	assertSchemaMigrated(testContext, db.Migrator().HasTable, db.Where("name = ?", migrationRepairCrdtSnapshotCoverage).Take(&migrationRecord{}).Error)
+}
+
+func TestOpenPostgresAppliesMigrations(testContext *testing.T) {
+	dsn := os.Getenv(postgresTestDSNEnv)
+	if dsn == "" {
+		testContext.Skipf("%s not set; skipping PostgreSQL integration test", postgresTestDSNEnv)
+	}
+	db, err := Open(DatabaseConfig{Driver: DriverPostgres, DSN: dsn}, zap.NewNop())
+	if err != nil {
+		testContext.Fatalf("failed to open postgres: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		testContext.Fatalf("failed to access connection pool: %v", err)
+	}
+	testContext.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+	assertSchemaMigrated(testContext, db.Migrator().HasTable, db.Where("name = ?", migrationRepairCrdtSnapshotCoverage).Take(&migrationRecord{}).Error)
+}
+
+func assertSchemaMigrated(testContext *testing.T, hasTable func(any) bool, migrationLookupErr error) {
+	testContext.Helper()
+	for _, model := range []any{&notes.CrdtUpdate{}, &notes.CrdtSnapshot{}, &migrationRecord{}} {
+		if !hasTable(model) {
+			testContext.Fatalf("expected table for %T", model)
+		}
+	}
+	if migrationLookupErr != nil {
+		testContext.Fatalf("expected migration record: %v", migrationLookupErr)
+	}
+}
+
+func TestOpenRejectsInvalidPoolSettings(testContext *testing.T) {
+	databasePath := filepath.Join(testContext.TempDir(), "pool.db")
+	testCases := []struct {
+		name   string
+		config DatabaseConfig
+	}{
+		{name: "negative-max-open", config: DatabaseConfig{Driver: DriverSQLite, Path: databasePath, MaxOpenConns: -1}},
+		{name: "negative-max-idle", config: DatabaseConfig{Driver: DriverSQLite, Path: databasePath, MaxIdleConns: -1}},
+		{name: "negative-lifetime", config: DatabaseConfig{Driver: DriverSQLite, Path: databasePath, ConnMaxLifetime: -time.Second}},
+		{name: "negative-busy-timeout", config: DatabaseConfig{Driver: DriverSQLite, Path: databasePath, BusyTimeout: -time.Second}},
+		{name: "unknown-journal-mode", config: DatabaseConfig{Driver: DriverSQLite, Path: databasePath, JournalMode: "MEMORYISH"}},
+	}
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(testContext *testing.T) {
+			if _, err := Open(testCase.config, zap.NewNop()); err == nil {
+				testContext.Fatal("expected pool configuration error")
+			}
+		})
+	}
+}
+
+func TestOpenSQLiteWithWALAllowsConcurrentReads(testContext *testing.T) {
+	const (
+		readerCount    = 8
+		readsPerReader = 25
+	)
+	db, err := Open(DatabaseConfig{
+		Driver:       DriverSQLite,
+		Path:         filepath.Join(testContext.TempDir(), "wal.db"),
+		MaxOpenConns: readerCount + 1,
+		BusyTimeout:  5 * time.Second,
+		JournalMode:  JournalModeWAL,
+	}, zap.NewNop())
+	if err != nil {
+		testContext.Fatalf("failed to open sqlite with wal: %v", err)
+	}
+
+	var journalMode string
+	if err := db.Raw("PRAGMA journal_mode").Scan(&journalMode).Error; err != nil {
+		testContext.Fatalf("failed to read journal mode: %v", err)
+	}
+	if !strings.EqualFold(journalMode, JournalModeWAL) {
+		testContext.Fatalf("expected WAL journal mode, got %q", journalMode)
+	}
+
+	if err := db.Create(&notes.CrdtSnapshot{UserID: "user-wal", NoteID: "note-wal", SnapshotB64: "AQID"}).Error; err != nil {
+		testContext.Fatalf("failed to seed snapshot: %v", err)
+	}
+
+	var waitGroup sync.WaitGroup
+	errs := make(chan error, readerCount*readsPerReader+1)
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		for index := 0; index < readsPerReader; index++ {
+			update := notes.CrdtUpdate{UserID: "user-wal", NoteID: "note-wal", UpdateB64: "AQID", UpdateHash: fmt.Sprintf("hash-%d", index)}
+			if err := db.Create(&update).Error; err != nil {
+				errs <- err
+			}
+		}
+	}()
+	for reader := 0; reader < readerCount; reader++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for index := 0; index < readsPerReader; index++ {
+				var snapshots []notes.CrdtSnapshot
+				if err := db.Where("user_id = ?", "user-wal").Find(&snapshots).Error; err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	waitGroup.Wait()
+	close(errs)
+	for err := range errs {
+		testContext.Fatalf("concurrent access failed: %v", err)
+	}
+}
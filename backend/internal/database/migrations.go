@@ -2,6 +2,7 @@ package database
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/MarcoPoloResearchLab/gravity/backend/internal/notes"
@@ -9,7 +10,10 @@ import (
 	"gorm.io/gorm"
 )
 
-const migrationRepairCrdtSnapshotCoverage = "2026-02-03_repair_crdt_snapshot_coverage"
+const (
+	migrationRepairCrdtSnapshotCoverage  = "2026-02-03_repair_crdt_snapshot_coverage"
+	migrationBackfillCrdtSnapshotChanged = "2026-10-15_backfill_crdt_snapshot_changed_at"
+)
 
 type migrationRecord struct {
 	Name             string `gorm:"column:name;primaryKey;size:190;not null"`
@@ -20,25 +24,87 @@ func (migrationRecord) TableName() string {
 	return "db_migrations"
 }
 
+var (
+	// ErrUnknownMigration indicates the name does not match a registered migration.
+	ErrUnknownMigration = errors.New("database: unknown migration")
+	// ErrMigrationNotApplied indicates a rollback was requested for a migration that has not run.
+	ErrMigrationNotApplied = errors.New("database: migration not applied")
+	// ErrMigrationIrreversible indicates the migration has no rollback.
+	ErrMigrationIrreversible = errors.New("database: migration has no rollback")
+)
+
+// migrationDefinition describes a named migration; rollback is nil when the change cannot be undone.
 type migrationDefinition struct {
-	name  string
-	apply func(*gorm.DB) error
+	name     string
+	apply    func(*gorm.DB) error
+	rollback func(*gorm.DB) error
 }
 
-func applyMigrations(db *gorm.DB, logger *zap.Logger) error {
-	migrations := []migrationDefinition{
+// MigrationStatus reports whether a named migration has been recorded in db_migrations.
+type MigrationStatus struct {
+	Name             string
+	Applied          bool
+	AppliedAtSeconds int64
+}
+
+// registeredMigrations lists migrations in application order.
+// repairCrdtSnapshotCoverage discards the previous coverage values, so it has no rollback.
+// backfillCrdtSnapshotChangedAt has none either: once applies maintain changed_at_s, the backfilled
+// values can no longer be told apart from live ones.
+func registeredMigrations() []migrationDefinition {
+	return []migrationDefinition{
 		{name: migrationRepairCrdtSnapshotCoverage, apply: repairCrdtSnapshotCoverage},
+		{name: migrationBackfillCrdtSnapshotChanged, apply: backfillCrdtSnapshotChangedAt},
+	}
+}
+
+// MigrationStatuses lists every registered migration in order with its applied state, without executing any.
+// A database that has never been migrated reports every migration as pending.
+func MigrationStatuses(db *gorm.DB) ([]MigrationStatus, error) {
+	migrations := registeredMigrations()
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	if !db.Migrator().HasTable(&migrationRecord{}) {
+		for _, migration := range migrations {
+			statuses = append(statuses, MigrationStatus{Name: migration.name})
+		}
+		return statuses, nil
 	}
 
 	for _, migration := range migrations {
-		var record migrationRecord
-		err := db.Where("name = ?", migration.name).Take(&record).Error
-		if err == nil {
-			continue
+		status, err := migrationStatus(db, migration.name)
+		if err != nil {
+			return nil, err
 		}
-		if !errors.Is(err, gorm.ErrRecordNotFound) {
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func migrationStatus(db *gorm.DB, name string) (MigrationStatus, error) {
+	var record migrationRecord
+	err := db.Where("name = ?", name).Take(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return MigrationStatus{Name: name}, nil
+	}
+	if err != nil {
+		return MigrationStatus{}, err
+	}
+	return MigrationStatus{Name: name, Applied: true, AppliedAtSeconds: record.AppliedAtSeconds}, nil
+}
+
+func applyMigrations(db *gorm.DB, logger *zap.Logger) error {
+	return applyMigrationDefinitions(db, registeredMigrations(), logger)
+}
+
+func applyMigrationDefinitions(db *gorm.DB, migrations []migrationDefinition, logger *zap.Logger) error {
+	for _, migration := range migrations {
+		status, err := migrationStatus(db, migration.name)
+		if err != nil {
 			return err
 		}
+		if status.Applied {
+			continue
+		}
 		if err := migration.apply(db); err != nil {
 			return err
 		}
@@ -53,8 +119,63 @@ func applyMigrations(db *gorm.DB, logger *zap.Logger) error {
 	return nil
 }
 
+// RollbackMigration runs the named migration's rollback and removes its db_migrations record.
+func RollbackMigration(db *gorm.DB, name string, logger *zap.Logger) error {
+	return rollbackMigrationDefinition(db, registeredMigrations(), name, logger)
+}
+
+func rollbackMigrationDefinition(db *gorm.DB, migrations []migrationDefinition, name string, logger *zap.Logger) error {
+	var target *migrationDefinition
+	for index := range migrations {
+		if migrations[index].name == name {
+			target = &migrations[index]
+			break
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownMigration, name)
+	}
+	if target.rollback == nil {
+		return fmt.Errorf("%w: %s", ErrMigrationIrreversible, name)
+	}
+
+	if !db.Migrator().HasTable(&migrationRecord{}) {
+		return fmt.Errorf("%w: %s", ErrMigrationNotApplied, name)
+	}
+	status, err := migrationStatus(db, name)
+	if err != nil {
+		return err
+	}
+	if !status.Applied {
+		return fmt.Errorf("%w: %s", ErrMigrationNotApplied, name)
+	}
+
+	err = db.Transaction(func(transaction *gorm.DB) error {
+		if err := target.rollback(transaction); err != nil {
+			return err
+		}
+		return transaction.Where("name = ?", name).Delete(&migrationRecord{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	if logger != nil {
+		logger.Info("database migration rolled back", zap.String("migration", name))
+	}
+	return nil
+}
+
 func repairCrdtSnapshotCoverage(db *gorm.DB) error {
 	return db.Model(&notes.CrdtSnapshot{}).
 		Where("snapshot_update_id <> 0").
 		Update("snapshot_update_id", 0).Error
 }
+
+// backfillCrdtSnapshotChangedAt sets changed_at_s on snapshots written before the column existed to the
+// newest applied_at_s among their stored updates. Notes already compacted to zero updates stay at 0.
+func backfillCrdtSnapshotChangedAt(db *gorm.DB) error {
+	return db.Exec(`UPDATE note_crdt_snapshots SET changed_at_s = COALESCE((
+		SELECT MAX(updates.applied_at_s) FROM note_crdt_updates AS updates
+		WHERE updates.user_id = note_crdt_snapshots.user_id AND updates.note_id = note_crdt_snapshots.note_id
+	), 0) WHERE changed_at_s = 0`).Error
+}
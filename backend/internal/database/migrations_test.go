@@ -1,6 +1,7 @@
 package database
 
 import (
+	"errors"
 	"path/filepath"
 	"testing"
 
@@ -53,3 +54,150 @@ func TestApplyMigrationsRepairsSnapshotCoverage(testContext *testing.T) {
 		testContext.Fatalf("expected migration timestamp to be set")
 	}
 }
+
+func TestApplyMigrationsBackfillsSnapshotChangedAt(testContext *testing.T) {
+	database, err := gorm.Open(sqlite.Open(filepath.Join(testContext.TempDir(), "backfill.db")), &gorm.Config{})
+	if err != nil {
+		testContext.Fatalf("failed to open sqlite: %v", err)
+	}
+	if err := database.AutoMigrate(&notes.CrdtUpdate{}, &notes.CrdtSnapshot{}, &migrationRecord{}); err != nil {
+		testContext.Fatalf("failed to migrate schema: %v", err)
+	}
+
+	seeds := []any{
+		&notes.CrdtSnapshot{UserID: "user-1", NoteID: "note-updated", SnapshotB64: "AQID"},
+		&notes.CrdtSnapshot{UserID: "user-1", NoteID: "note-compacted", SnapshotB64: "AQID"},
+		&notes.CrdtSnapshot{UserID: "user-1", NoteID: "note-current", SnapshotB64: "AQID", ChangedAtSeconds: 900},
+		&notes.CrdtUpdate{UserID: "user-1", NoteID: "note-updated", UpdateB64: "AQID", UpdateHash: "hash-1", AppliedAtSeconds: 100},
+		&notes.CrdtUpdate{UserID: "user-1", NoteID: "note-updated", UpdateB64: "AQIE", UpdateHash: "hash-2", AppliedAtSeconds: 300},
+		&notes.CrdtUpdate{UserID: "user-2", NoteID: "note-updated", UpdateB64: "AQIF", UpdateHash: "hash-3", AppliedAtSeconds: 500},
+		&notes.CrdtUpdate{UserID: "user-1", NoteID: "note-current", UpdateB64: "AQID", UpdateHash: "hash-4", AppliedAtSeconds: 200},
+	}
+	for _, seed := range seeds {
+		if err := database.Create(seed).Error; err != nil {
+			testContext.Fatalf("failed to seed %T: %v", seed, err)
+		}
+	}
+
+	if err := applyMigrations(database, zap.NewNop()); err != nil {
+		testContext.Fatalf("failed to apply migrations: %v", err)
+	}
+
+	expected := map[string]int64{"note-updated": 300, "note-compacted": 0, "note-current": 900}
+	for noteID, changedAt := range expected {
+		var stored notes.CrdtSnapshot
+		if err := database.Where("user_id = ? AND note_id = ?", "user-1", noteID).Take(&stored).Error; err != nil {
+			testContext.Fatalf("failed to reload %s: %v", noteID, err)
+		}
+		if stored.ChangedAtSeconds != changedAt {
+			testContext.Fatalf("expected %s changed_at_s %d, got %d", noteID, changedAt, stored.ChangedAtSeconds)
+		}
+	}
+	var record migrationRecord
+	if err := database.Where("name = ?", migrationBackfillCrdtSnapshotChanged).Take(&record).Error; err != nil {
+		testContext.Fatalf("expected migration record to be created: %v", err)
+	}
+}
+
+func TestMigrationStatusesReportsPendingThenApplied(testContext *testing.T) {
+	database, err := Connect(DatabaseConfig{Driver: DriverSQLite, Path: filepath.Join(testContext.TempDir(), "status.db")})
+	if err != nil {
+		testContext.Fatalf("failed to connect: %v", err)
+	}
+
+	pending, err := MigrationStatuses(database)
+	if err != nil {
+		testContext.Fatalf("failed to read pending statuses: %v", err)
+	}
+	if len(pending) != len(registeredMigrations()) {
+		testContext.Fatalf("expected %d statuses, got %d", len(registeredMigrations()), len(pending))
+	}
+	for _, status := range pending {
+		if status.Applied {
+			testContext.Fatalf("expected %s to be pending before migrating", status.Name)
+		}
+	}
+	if database.Migrator().HasTable(&migrationRecord{}) {
+		testContext.Fatal("expected status check not to create the migrations table")
+	}
+
+	if err := Migrate(database, zap.NewNop()); err != nil {
+		testContext.Fatalf("failed to migrate: %v", err)
+	}
+	applied, err := MigrationStatuses(database)
+	if err != nil {
+		testContext.Fatalf("failed to read applied statuses: %v", err)
+	}
+	for _, status := range applied {
+		if !status.Applied || status.AppliedAtSeconds == 0 {
+			testContext.Fatalf("expected %s to be applied, got %#v", status.Name, status)
+		}
+	}
+}
+
+func TestRollbackMigrationRemovesRecord(testContext *testing.T) {
+	const (
+		reversibleMigration   = "2099-01-01_add_scratch_table"
+		irreversibleMigration = "2099-01-02_irreversible"
+		scratchTable          = "migration_scratch"
+	)
+	database, err := Connect(DatabaseConfig{Driver: DriverSQLite, Path: filepath.Join(testContext.TempDir(), "rollback.db")})
+	if err != nil {
+		testContext.Fatalf("failed to connect: %v", err)
+	}
+	if err := database.AutoMigrate(&migrationRecord{}); err != nil {
+		testContext.Fatalf("failed to migrate schema: %v", err)
+	}
+	migrations := []migrationDefinition{
+		{
+			name: reversibleMigration,
+			apply: func(db *gorm.DB) error {
+				return db.Exec("CREATE TABLE " + scratchTable + " (id INTEGER PRIMARY KEY)").Error
+			},
+			rollback: func(db *gorm.DB) error {
+				return db.Exec("DROP TABLE " + scratchTable).Error
+			},
+		},
+		{
+			name: irreversibleMigration,
+			apply: func(db *gorm.DB) error {
+				return nil
+			},
+		},
+	}
+	if err := applyMigrationDefinitions(database, migrations, zap.NewNop()); err != nil {
+		testContext.Fatalf("failed to apply migrations: %v", err)
+	}
+
+	if err := rollbackMigrationDefinition(database, migrations, reversibleMigration, zap.NewNop()); err != nil {
+		testContext.Fatalf("rollback failed: %v", err)
+	}
+	if database.Migrator().HasTable(scratchTable) {
+		testContext.Fatal("expected rollback to drop the scratch table")
+	}
+	var remaining int64
+	if err := database.Model(&migrationRecord{}).Where("name = ?", reversibleMigration).Count(&remaining).Error; err != nil {
+		testContext.Fatalf("failed to count migration records: %v", err)
+	}
+	if remaining != 0 {
+		testContext.Fatalf("expected migration record to be removed, found %d", remaining)
+	}
+
+	testCases := []struct {
+		name      string
+		migration string
+		expected  error
+	}{
+		{name: "already-rolled-back", migration: reversibleMigration, expected: ErrMigrationNotApplied},
+		{name: "no-rollback", migration: irreversibleMigration, expected: ErrMigrationIrreversible},
+		{name: "unknown", migration: "missing", expected: ErrUnknownMigration},
+	}
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(testContext *testing.T) {
+			err := rollbackMigrationDefinition(database, migrations, testCase.migration, zap.NewNop())
+			if !errors.Is(err, testCase.expected) {
+				testContext.Fatalf("expected %v, got %v", testCase.expected, err)
+			}
+		})
+	}
+}
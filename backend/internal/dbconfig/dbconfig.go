@@ -0,0 +1,70 @@
+// Package dbconfig describes database connection settings without depending on any driver, so configuration
+// loading can validate them without pulling in GORM.
+package dbconfig
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DriverSQLite selects the embedded SQLite driver; Config.Path names the database file.
+	DriverSQLite = "sqlite"
+	// DriverPostgres selects the PostgreSQL driver; Config.DSN holds the connection string.
+	DriverPostgres = "postgres"
+
+	// JournalModeWAL lets SQLite readers proceed while a writer holds the database.
+	JournalModeWAL = "WAL"
+	// JournalModeDelete is SQLite's default rollback journal.
+	JournalModeDelete = "DELETE"
+)
+
+// Config selects the driver, the location of the database and connection pool limits.
+// Zero pool values keep the driver defaults, except that SQLite is limited to a single open connection
+// unless MaxOpenConns is set. BusyTimeout and JournalMode apply to SQLite only.
+type Config struct {
+	Driver          string
+	Path            string
+	DSN             string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	BusyTimeout     time.Duration
+	JournalMode     string
+}
+
+// Validate checks the driver, its location and the pool settings. It is the only validation of database
+// settings, so configuration loading and database.Connect reject the same values with the same messages.
+func (cfg Config) Validate() error {
+	switch cfg.Driver {
+	case DriverSQLite:
+		if strings.TrimSpace(cfg.Path) == "" {
+			return fmt.Errorf("database path is required")
+		}
+	case DriverPostgres:
+		if strings.TrimSpace(cfg.DSN) == "" {
+			return fmt.Errorf("database dsn is required for the postgres driver")
+		}
+	default:
+		return fmt.Errorf("database driver must be %q or %q, got %q", DriverSQLite, DriverPostgres, cfg.Driver)
+	}
+	if cfg.MaxOpenConns < 0 {
+		return fmt.Errorf("database max open connections must not be negative")
+	}
+	if cfg.MaxIdleConns < 0 {
+		return fmt.Errorf("database max idle connections must not be negative")
+	}
+	if cfg.ConnMaxLifetime < 0 {
+		return fmt.Errorf("database connection max lifetime must not be negative")
+	}
+	if cfg.BusyTimeout < 0 {
+		return fmt.Errorf("database busy timeout must not be negative")
+	}
+	switch strings.ToUpper(cfg.JournalMode) {
+	case "", JournalModeWAL, JournalModeDelete:
+	default:
+		return fmt.Errorf("unsupported sqlite journal mode %q", cfg.JournalMode)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package dbconfig
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(testContext *testing.T) {
+	testCases := []struct {
+		name          string
+		config        Config
+		expectedError string
+	}{
+		{name: "sqlite", config: Config{Driver: DriverSQLite, Path: "gravity.db", JournalMode: "wal"}},
+		{name: "postgres", config: Config{Driver: DriverPostgres, DSN: "postgres://localhost/gravity"}},
+		{name: "unknown-driver", config: Config{Driver: "mysql", DSN: "user@/db"}, expectedError: "database driver must be"},
+		{name: "sqlite-without-path", config: Config{Driver: DriverSQLite}, expectedError: "database path is required"},
+		{name: "postgres-without-dsn", config: Config{Driver: DriverPostgres}, expectedError: "database dsn is required"},
+		{name: "negative-max-open", config: Config{Driver: DriverSQLite, Path: "gravity.db", MaxOpenConns: -1}, expectedError: "max open connections"},
+		{name: "negative-lifetime", config: Config{Driver: DriverSQLite, Path: "gravity.db", ConnMaxLifetime: -time.Second}, expectedError: "max lifetime"},
+		{name: "unknown-journal-mode", config: Config{Driver: DriverSQLite, Path: "gravity.db", JournalMode: "MEMORYISH"}, expectedError: "journal mode"},
+	}
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(testContext *testing.T) {
+			err := testCase.config.Validate()
+			if testCase.expectedError == "" {
+				if err != nil {
+					testContext.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), testCase.expectedError) {
+				testContext.Fatalf("expected error containing %q, got %v", testCase.expectedError, err)
+			}
+		})
+	}
+}
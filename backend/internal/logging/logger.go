@@ -1,17 +1,60 @@
 package logging
 
 import (
+	"fmt"
 	"strings"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-// NewLogger returns a zap logger configured for structured production logging.
-func NewLogger(level string) (*zap.Logger, error) {
-	cfg := zap.NewProductionConfig()
+const (
+	// FormatJSON emits structured JSON lines suited to log aggregation.
+	FormatJSON = "json"
+	// FormatConsole emits human-readable lines for local development.
+	FormatConsole = "console"
 
-	switch strings.ToLower(strings.TrimSpace(level)) {
+	// defaultSamplingInitial and defaultSamplingThereafter mirror zap's production sampling
+	// and fill whichever sampling value is left unset.
+	defaultSamplingInitial    = 100
+	defaultSamplingThereafter = 100
+)
+
+// Config selects the logger level, encoding and sampling.
+type Config struct {
+	Level  string
+	Format string
+	// SamplingInitial and SamplingThereafter override the per-second sampling of identical messages:
+	// the first SamplingInitial entries are logged, then every SamplingThereafter-th one.
+	// Leaving both zero keeps the encoding's default, which samples JSON at 100/100 and leaves console unsampled.
+	SamplingInitial    int
+	SamplingThereafter int
+	// DisableSampling logs every entry regardless of the sampling settings.
+	DisableSampling bool
+}
+
+// NewLogger returns a zap logger at the configured level using the JSON or console encoding.
+// An empty format selects JSON.
+func NewLogger(loggerConfig Config) (*zap.Logger, error) {
+	cfg, err := newConfig(loggerConfig)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Build()
+}
+
+func newConfig(loggerConfig Config) (zap.Config, error) {
+	var cfg zap.Config
+	switch strings.ToLower(strings.TrimSpace(loggerConfig.Format)) {
+	case FormatJSON, "":
+		cfg = zap.NewProductionConfig()
+	case FormatConsole:
+		cfg = zap.NewDevelopmentConfig()
+	default:
+		return zap.Config{}, fmt.Errorf("log format %q must be %q or %q", loggerConfig.Format, FormatJSON, FormatConsole)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(loggerConfig.Level)) {
 	case "debug":
 		cfg.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
 	case "info", "":
@@ -24,5 +67,22 @@ func NewLogger(level string) (*zap.Logger, error) {
 		cfg.Level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
 	}
 
-	return cfg.Build()
+	if loggerConfig.SamplingInitial < 0 || loggerConfig.SamplingThereafter < 0 {
+		return zap.Config{}, fmt.Errorf("log sampling values must not be negative")
+	}
+	switch {
+	case loggerConfig.DisableSampling:
+		cfg.Sampling = nil
+	case loggerConfig.SamplingInitial > 0 || loggerConfig.SamplingThereafter > 0:
+		sampling := zap.SamplingConfig{Initial: defaultSamplingInitial, Thereafter: defaultSamplingThereafter}
+		if loggerConfig.SamplingInitial > 0 {
+			sampling.Initial = loggerConfig.SamplingInitial
+		}
+		if loggerConfig.SamplingThereafter > 0 {
+			sampling.Thereafter = loggerConfig.SamplingThereafter
+		}
+		cfg.Sampling = &sampling
+	}
+
+	return cfg, nil
 }
@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNewConfigSelectsEncoding(t *testing.T) {
+	testCases := []struct {
+		name         string
+		format       string
+		wantEncoding string
+	}{
+		{name: "default", format: "", wantEncoding: "json"},
+		{name: "json", format: FormatJSON, wantEncoding: "json"},
+		{name: "console", format: " Console ", wantEncoding: "console"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			loggerConfig := Config{Level: "warn", Format: testCase.format}
+			cfg, err := newConfig(loggerConfig)
+			if err != nil {
+				t.Fatalf("newConfig failed: %v", err)
+			}
+			if cfg.Encoding != testCase.wantEncoding {
+				t.Fatalf("expected %s encoding, got %s", testCase.wantEncoding, cfg.Encoding)
+			}
+			if cfg.Level.Level() != zapcore.WarnLevel {
+				t.Fatalf("expected configured level to be kept, got %s", cfg.Level.Level())
+			}
+			if _, err := NewLogger(loggerConfig); err != nil {
+				t.Fatalf("NewLogger failed: %v", err)
+			}
+		})
+	}
+
+	if _, err := NewLogger(Config{Level: "info", Format: "xml"}); err == nil {
+		t.Fatal("expected unknown log format to be rejected")
+	}
+}
+
+func TestNewConfigAppliesSampling(t *testing.T) {
+	testCases := []struct {
+		name           string
+		loggerConfig   Config
+		wantSampling   bool
+		wantInitial    int
+		wantThereafter int
+	}{
+		{name: "json-default", loggerConfig: Config{}, wantSampling: true, wantInitial: 100, wantThereafter: 100},
+		{name: "json-disabled", loggerConfig: Config{DisableSampling: true, SamplingInitial: 5}},
+		{name: "json-tuned", loggerConfig: Config{SamplingInitial: 10, SamplingThereafter: 500}, wantSampling: true, wantInitial: 10, wantThereafter: 500},
+		{name: "json-partial", loggerConfig: Config{SamplingThereafter: 1000}, wantSampling: true, wantInitial: 100, wantThereafter: 1000},
+		{name: "console-default", loggerConfig: Config{Format: FormatConsole}},
+		{name: "console-tuned", loggerConfig: Config{Format: FormatConsole, SamplingInitial: 20}, wantSampling: true, wantInitial: 20, wantThereafter: 100},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			cfg, err := newConfig(testCase.loggerConfig)
+			if err != nil {
+				t.Fatalf("newConfig failed: %v", err)
+			}
+			if !testCase.wantSampling {
+				if cfg.Sampling != nil {
+					t.Fatalf("expected sampling to be disabled, got %+v", *cfg.Sampling)
+				}
+				return
+			}
+			if cfg.Sampling == nil {
+				t.Fatal("expected sampling to be configured")
+			}
+			if cfg.Sampling.Initial != testCase.wantInitial || cfg.Sampling.Thereafter != testCase.wantThereafter {
+				t.Fatalf("unexpected sampling: got %d/%d want %d/%d", cfg.Sampling.Initial, cfg.Sampling.Thereafter, testCase.wantInitial, testCase.wantThereafter)
+			}
+		})
+	}
+
+	if _, err := NewLogger(Config{SamplingInitial: -1}); err == nil {
+		t.Fatal("expected negative sampling to be rejected")
+	}
+}
@@ -0,0 +1,53 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	sqliteBusyCode   = 5
+	sqliteLockedCode = 6
+
+	busyRetryBackoff = 10 * time.Millisecond
+)
+
+// sqliteCodedError matches the driver error type, which exposes the SQLite result code.
+type sqliteCodedError interface {
+	Code() int
+}
+
+// isBusyError reports whether err is a SQLite busy or locked result, including extended codes.
+func isBusyError(err error) bool {
+	var coded sqliteCodedError
+	if !errors.As(err, &coded) {
+		return false
+	}
+	primaryCode := coded.Code() & 0xff
+	return primaryCode == sqliteBusyCode || primaryCode == sqliteLockedCode
+}
+
+// retryOnBusy runs attempt and re-runs it with linear backoff while it fails with a busy error.
+// attempt must be a whole transaction so a retry starts from a clean state. Other errors, an
+// exhausted retry budget, or a finished ctx return the latest error unchanged.
+func (service *Service) retryOnBusy(ctx context.Context, operation string, attempt func() error) error {
+	err := attempt()
+	for retry := 1; retry <= service.busyRetries && isBusyError(err); retry++ {
+		service.loggerOrDefault().Warn("retrying busy transaction",
+			zap.String("operation", operation),
+			zap.Int("retry", retry),
+			zap.Error(err))
+		timer := time.NewTimer(time.Duration(retry) * busyRetryBackoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		err = attempt()
+	}
+	return err
+}
@@ -16,8 +16,31 @@ var (
 	ErrInvalidCrdtUpdateID = errors.New("notes: invalid crdt update id")
 	// ErrInvalidCrdtCursor indicates that a CRDT cursor payload is invalid.
 	ErrInvalidCrdtCursor = errors.New("notes: invalid crdt cursor")
+	// ErrInvalidListLimit indicates that a page size is negative or exceeds the maximum.
+	ErrInvalidListLimit = errors.New("notes: invalid list limit")
+	// ErrInvalidListCursor indicates that a page cursor could not be decoded.
+	ErrInvalidListCursor = errors.New("notes: invalid list cursor")
+	// ErrPayloadTooLarge indicates that a CRDT update or snapshot exceeds the configured size limit.
+	ErrPayloadTooLarge = errors.New("notes: payload too large")
+	// ErrNoteQuotaExceeded indicates that an update would create a note beyond the user's configured note quota.
+	ErrNoteQuotaExceeded = errors.New("notes: note quota exceeded")
+	// ErrTooManyUpdates indicates that a single sync carried more updates than the configured maximum.
+	ErrTooManyUpdates = errors.New("notes: too many updates")
+	// ErrSyncTimeout indicates that applying a sync batch exceeded the configured transaction timeout.
+	ErrSyncTimeout = errors.New("notes: sync timeout")
+	// ErrTooManyNoteIDs indicates that a batch lookup requested more than MaxBatchNoteIDs notes.
+	ErrTooManyNoteIDs = errors.New("notes: too many note ids")
+	// ErrNoteNotFound indicates that the requesting user owns no note with the given identifier,
+	// whether the note is missing or belongs to another user.
+	ErrNoteNotFound = errors.New("notes: note not found")
 )
 
+// MaxListLimit bounds the page size accepted by paginated listings.
+const MaxListLimit = 1000
+
+// MaxBatchNoteIDs bounds the number of note identifiers accepted by a batch snapshot lookup.
+const MaxBatchNoteIDs = 500
+
 const (
 	errFormatEmpty         = "%w: empty"
 	errFormatInvalidBase64 = "%w: invalid base64"
@@ -27,15 +50,17 @@ const (
 type CrdtUpdateBase64 string
 
 // NewCrdtUpdateBase64 validates raw input and returns a CrdtUpdateBase64.
+// URL-safe input, padded or not, is re-encoded to standard base64 so stored payloads decode uniformly.
 func NewCrdtUpdateBase64(rawInput string) (CrdtUpdateBase64, error) {
 	trimmed := strings.TrimSpace(rawInput)
 	if trimmed == "" {
 		return "", fmt.Errorf(errFormatEmpty, ErrInvalidCrdtUpdate)
 	}
-	if _, err := base64.StdEncoding.DecodeString(trimmed); err != nil {
+	normalized, ok := normalizeBase64(trimmed)
+	if !ok {
 		return "", fmt.Errorf(errFormatInvalidBase64, ErrInvalidCrdtUpdate)
 	}
-	return CrdtUpdateBase64(trimmed), nil
+	return CrdtUpdateBase64(normalized), nil
 }
 
 // String returns the update payload as a string.
@@ -47,15 +72,17 @@ func (payload CrdtUpdateBase64) String() string {
 type CrdtSnapshotBase64 string
 
 // NewCrdtSnapshotBase64 validates raw input and returns a CrdtSnapshotBase64.
+// URL-safe input, padded or not, is re-encoded to standard base64 so stored payloads decode uniformly.
 func NewCrdtSnapshotBase64(rawInput string) (CrdtSnapshotBase64, error) {
 	trimmed := strings.TrimSpace(rawInput)
 	if trimmed == "" {
 		return "", fmt.Errorf(errFormatEmpty, ErrInvalidCrdtSnapshot)
 	}
-	if _, err := base64.StdEncoding.DecodeString(trimmed); err != nil {
+	normalized, ok := normalizeBase64(trimmed)
+	if !ok {
 		return "", fmt.Errorf(errFormatInvalidBase64, ErrInvalidCrdtSnapshot)
 	}
-	return CrdtSnapshotBase64(trimmed), nil
+	return CrdtSnapshotBase64(normalized), nil
 }
 
 // String returns the snapshot payload as a string.
@@ -63,6 +90,18 @@ func (payload CrdtSnapshotBase64) String() string {
 	return string(payload)
 }
 
+// normalizeBase64 returns payload in standard padded base64, converting the URL-safe alphabet some clients send.
+func normalizeBase64(payload string) (string, bool) {
+	if _, err := base64.StdEncoding.DecodeString(payload); err == nil {
+		return payload, true
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(payload, "="))
+	if err != nil {
+		return "", false
+	}
+	return base64.StdEncoding.EncodeToString(decoded), true
+}
+
 // CrdtUpdateID represents a validated CRDT update identifier.
 type CrdtUpdateID int64
 
@@ -183,3 +222,43 @@ func (cursor CrdtCursor) NoteID() NoteID {
 func (cursor CrdtCursor) LastUpdateID() CrdtUpdateID {
 	return cursor.lastUpdateID
 }
+
+// CrdtSnapshotListOptions bounds a snapshot listing. A zero limit returns every remaining snapshot.
+type CrdtSnapshotListOptions struct {
+	limit       int
+	afterNoteID NoteID
+}
+
+// NewCrdtSnapshotListOptions validates the page size and decodes the opaque cursor returned by a previous page.
+func NewCrdtSnapshotListOptions(limit int, cursor string) (CrdtSnapshotListOptions, error) {
+	if limit < 0 || limit > MaxListLimit {
+		return CrdtSnapshotListOptions{}, fmt.Errorf("%w: %d", ErrInvalidListLimit, limit)
+	}
+	trimmedCursor := strings.TrimSpace(cursor)
+	if trimmedCursor == "" {
+		return CrdtSnapshotListOptions{limit: limit}, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(trimmedCursor)
+	if err != nil {
+		return CrdtSnapshotListOptions{}, fmt.Errorf("%w: invalid encoding", ErrInvalidListCursor)
+	}
+	afterNoteID, err := NewNoteID(string(decoded))
+	if err != nil {
+		return CrdtSnapshotListOptions{}, fmt.Errorf("%w: %v", ErrInvalidListCursor, err)
+	}
+	return CrdtSnapshotListOptions{limit: limit, afterNoteID: afterNoteID}, nil
+}
+
+// Limit returns the page size; zero means unbounded.
+func (options CrdtSnapshotListOptions) Limit() int {
+	return options.limit
+}
+
+// AfterNoteID returns the note identifier the page starts after, or empty for the first page.
+func (options CrdtSnapshotListOptions) AfterNoteID() NoteID {
+	return options.afterNoteID
+}
+
+func encodeSnapshotListCursor(noteID NoteID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(noteID.String()))
+}
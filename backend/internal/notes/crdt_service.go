@@ -6,9 +6,14 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"sort"
 	"strings"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -17,18 +22,40 @@ import (
 const (
 	opApplyCrdtUpdates            = "notes.apply_crdt_updates"
 	opListCrdtSnapshots           = "notes.list_crdt_snapshots"
+	opStreamCrdtSnapshots         = "notes.stream_crdt_snapshots"
 	opListCrdtUpdates             = "notes.list_crdt_updates"
+	opGetCrdtSnapshot             = "notes.get_crdt_snapshot"
+	opGetCrdtSnapshots            = "notes.get_crdt_snapshots"
+	opCompactCrdtUpdates          = "notes.compact_crdt_updates"
+	opCrdtListingVersion          = "notes.crdt_listing_version"
+	opExportUserData              = "notes.export_user_data"
+	opUserStats                   = "notes.user_stats"
+	opDeleteUserData              = "notes.delete_user_data"
 	fieldUserID                   = "user_id"
 	fieldNoteID                   = "note_id"
 	columnUpdateID                = "update_id"
 	orderUpdateIDAsc              = columnUpdateID + " ASC"
+	columnSnapshotUpdateID        = "snapshot_update_id"
+	columnLockedAt                = "locked_at_s"
+	orderNoteIDAsc                = fieldNoteID + " ASC"
 	queryUserID                   = fieldUserID + " = ?"
+	queryNoteIDAfter              = fieldNoteID + " > ?"
+	queryUserChangedSince         = fieldUserID + " = ? AND changed_at_s >= ?"
+	queryNoteIDIn                 = fieldNoteID + " IN (?)"
 	queryUserNote                 = fieldUserID + " = ? AND " + fieldNoteID + " = ?"
 	queryUserNoteHash             = fieldUserID + " = ? AND " + fieldNoteID + " = ? AND update_hash = ?"
 	queryNoteUpdateAfter          = fieldNoteID + " = ? AND " + columnUpdateID + " > ?"
+	queryUserNoteUpdateThrough    = fieldUserID + " = ? AND " + fieldNoteID + " = ? AND " + columnUpdateID + " <= ?"
+	querySnapshotCoversUpdates    = "snapshot_update_id > 0"
 	sqliteMaxVariables            = 999
 	cursorQueryBaseVariables      = 1
 	cursorQueryVariablesPerCursor = 2
+	compactionQueryBaseVariables  = 1
+	tableCrdtUpdatesAlias         = "note_crdt_updates AS u"
+	joinNoteSnapshot              = "LEFT JOIN note_crdt_snapshots AS s ON s.user_id = u.user_id AND s.note_id = u.note_id"
+	selectRetainedByNote          = "u.note_id AS note_id, COUNT(*) AS retained"
+	queryRetainedAfterSnapshot    = "u.user_id = ? AND u.note_id IN (?) AND u.update_id > COALESCE(s.snapshot_update_id, 0)"
+	groupUpdateNoteID             = "u.note_id"
 	reasonMissingDatabase         = "missing_database"
 	reasonUpdateHashFailed        = "update_hash_failed"
 	reasonUpdateInsertFailed      = "update_insert_failed"
@@ -36,18 +63,32 @@ const (
 	reasonUpdateIDInvalid         = "update_id_invalid"
 	reasonSnapshotUpsertFailed    = "snapshot_upsert_failed"
 	reasonQueryFailed             = "query_failed"
+	reasonInvalidLimit            = "invalid_limit"
 	reasonSnapshotNoteInvalid     = "snapshot_note_invalid"
 	reasonSnapshotPayloadInvalid  = "snapshot_payload_invalid"
 	reasonSnapshotUpdateIDInvalid = "snapshot_update_id_invalid"
 	reasonUpdateNoteInvalid       = "update_note_invalid"
 	reasonUpdatePayloadInvalid    = "update_payload_invalid"
+	reasonPayloadTooLarge         = "payload_too_large"
+	reasonNoteQuotaExceeded       = "note_quota_exceeded"
+	reasonTooManyUpdates          = "too_many_updates"
+	reasonTooManyNoteIDs          = "too_many_note_ids"
+	reasonSyncTimeout             = "sync_timeout"
+	reasonNoteQuotaCheckFailed    = "note_quota_check_failed"
+	reasonUpdateDeleteFailed      = "update_delete_failed"
+	reasonSnapshotDeleteFailed    = "snapshot_delete_failed"
+	reasonQuotaLockDeleteFailed   = "quota_lock_delete_failed"
+	reasonUserLockFailed          = "user_lock_failed"
+	reasonNoteNotFound            = "note_not_found"
+	reasonCompactionCheckFailed   = "compaction_check_failed"
 )
 
 // CrdtUpdateOutcome captures the stored outcome for a CRDT update.
 type CrdtUpdateOutcome struct {
-	noteID    NoteID
-	updateID  CrdtUpdateID
-	duplicate bool
+	noteID                NoteID
+	updateID              CrdtUpdateID
+	duplicate             bool
+	compactionRecommended bool
 }
 
 // NoteID returns the associated note identifier.
@@ -65,6 +106,12 @@ func (outcome CrdtUpdateOutcome) Duplicate() bool {
 	return outcome.duplicate
 }
 
+// CompactionRecommended reports whether the note retains more updates beyond its snapshot than the configured
+// compaction threshold, so the client should push a consolidating snapshot.
+func (outcome CrdtUpdateOutcome) CompactionRecommended() bool {
+	return outcome.compactionRecommended
+}
+
 // CrdtSyncResult aggregates outcomes for applied CRDT updates.
 type CrdtSyncResult struct {
 	UpdateOutcomes []CrdtUpdateOutcome
@@ -92,6 +139,46 @@ func (record CrdtSnapshotRecord) SnapshotUpdateID() CrdtUpdateID {
 	return record.snapshotUpdateID
 }
 
+// CrdtSnapshotPage captures one page of stored snapshots ordered by note identifier.
+type CrdtSnapshotPage struct {
+	Snapshots  []CrdtSnapshotRecord
+	NextCursor string
+}
+
+// CrdtUpdatePage captures one page of updates ordered by update identifier. NextCursors holds one cursor per
+// requested note advanced past the returned updates; HasMore reports whether further updates remain.
+type CrdtUpdatePage struct {
+	Updates     []CrdtUpdateRecord
+	HasMore     bool
+	NextCursors []CrdtCursor
+}
+
+// CrdtListingVersion summarizes a user's stored CRDT state; it changes whenever an update or snapshot is stored.
+type CrdtListingVersion struct {
+	SnapshotCount    int64
+	SnapshotCoverage int64
+	MaxUpdateID      int64
+}
+
+// UserStats aggregates a user's stored CRDT footprint for quota displays.
+// Byte totals measure the stored base64 text; note deletion lives inside the opaque CRDT state and is not counted separately.
+type UserStats struct {
+	NoteCount     int64
+	UpdateCount   int64
+	SnapshotBytes int64
+	UpdateBytes   int64
+}
+
+// UserExport bundles every stored record for a user for data-portability requests.
+// Tags maps each tagged note to its sorted tags; untagged notes are absent.
+type UserExport struct {
+	UserID     UserID
+	ExportedAt time.Time
+	Snapshots  []CrdtSnapshotRecord
+	Updates    []CrdtUpdateRecord
+	Tags       map[NoteID][]Tag
+}
+
 // CrdtUpdateRecord captures a CRDT update stored for replay.
 type CrdtUpdateRecord struct {
 	noteID    NoteID
@@ -116,6 +203,28 @@ func (record CrdtUpdateRecord) UpdateB64() CrdtUpdateBase64 {
 
 // ApplyCrdtUpdates persists CRDT updates and snapshots.
 func (service *Service) ApplyCrdtUpdates(ctx context.Context, userID UserID, updates []CrdtUpdateEnvelope) (CrdtSyncResult, error) {
+	ctx, span := service.tracerOrDefault().Start(ctx, opApplyCrdtUpdates, trace.WithAttributes(
+		attribute.String(AttributeUserID, userID.String()),
+		attribute.Int(AttributeOperationCount, len(updates)),
+	))
+	defer span.End()
+
+	observer := service.observerOrDefault()
+	observer.SyncBatch(len(updates))
+	result, err := service.applyCrdtUpdates(ctx, userID, updates)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return CrdtSyncResult{}, err
+	}
+	for _, outcome := range result.UpdateOutcomes {
+		observer.UpdateApplied(outcome.Duplicate())
+	}
+	span.SetAttributes(CrdtOutcomeAttributes(result.UpdateOutcomes)...)
+	return result, nil
+}
+
+func (service *Service) applyCrdtUpdates(ctx context.Context, userID UserID, updates []CrdtUpdateEnvelope) (CrdtSyncResult, error) {
 	if service.db == nil {
 		service.logError(opApplyCrdtUpdates, reasonMissingDatabase, errMissingDatabase)
 		return CrdtSyncResult{}, newServiceError(opApplyCrdtUpdates, reasonMissingDatabase, errMissingDatabase)
@@ -126,84 +235,238 @@ func (service *Service) ApplyCrdtUpdates(ctx context.Context, userID UserID, upd
 		return result, nil
 	}
 
-	transactionError := service.db.WithContext(ctx).Transaction(func(transaction *gorm.DB) error {
-		for _, update := range updates {
-			updateHash, hashErr := hashCrdtPayload(update.UpdateB64().String())
-			if hashErr != nil {
-				service.logError(opApplyCrdtUpdates, reasonUpdateHashFailed, hashErr,
-					zap.String(fieldUserID, userID.String()),
-					zap.String(fieldNoteID, update.NoteID().String()))
-				return newServiceError(opApplyCrdtUpdates, reasonUpdateHashFailed, hashErr)
-			}
+	if len(updates) > service.maxUpdatesPerSync {
+		batchErr := fmt.Errorf("%w: %d exceeds %d", ErrTooManyUpdates, len(updates), service.maxUpdatesPerSync)
+		service.logError(opApplyCrdtUpdates, reasonTooManyUpdates, batchErr, zap.String(fieldUserID, userID.String()))
+		return CrdtSyncResult{}, newServiceError(opApplyCrdtUpdates, reasonTooManyUpdates, batchErr)
+	}
 
-			appliedAtSeconds := service.clock().UTC().Unix()
-			model := CrdtUpdate{
-				UserID:           userID.String(),
-				NoteID:           update.NoteID().String(),
-				UpdateB64:        update.UpdateB64().String(),
-				UpdateHash:       updateHash,
-				AppliedAtSeconds: appliedAtSeconds,
-			}
-			createResult := transaction.Clauses(clause.OnConflict{DoNothing: true}).Create(&model)
-			if createResult.Error != nil {
-				service.logError(opApplyCrdtUpdates, reasonUpdateInsertFailed, createResult.Error,
-					zap.String(fieldUserID, userID.String()),
-					zap.String(fieldNoteID, update.NoteID().String()))
-				return newServiceError(opApplyCrdtUpdates, reasonUpdateInsertFailed, createResult.Error)
+	for _, update := range updates {
+		if err := service.checkPayloadSize(update); err != nil {
+			reason := reasonPayloadTooLarge
+			if !errors.Is(err, ErrPayloadTooLarge) {
+				reason = reasonUpdatePayloadInvalid
 			}
+			service.logError(opApplyCrdtUpdates, reason, err,
+				zap.String(fieldUserID, userID.String()),
+				zap.String(fieldNoteID, update.NoteID().String()))
+			return CrdtSyncResult{}, newServiceError(opApplyCrdtUpdates, reason, err)
+		}
+	}
+
+	transactionCtx := ctx
+	if service.syncTimeout > 0 {
+		var cancel context.CancelFunc
+		transactionCtx, cancel = context.WithTimeout(ctx, service.syncTimeout)
+		defer cancel()
+	}
+
+	if service.userLocks != nil {
+		release, lockErr := service.userLocks.acquire(transactionCtx, userID)
+		if lockErr != nil {
+			return CrdtSyncResult{}, service.transactionFailure(ctx, transactionCtx, userID, len(updates), lockErr)
+		}
+		defer release()
+	}
 
-			duplicate := createResult.RowsAffected == 0
-			updateID := model.UpdateID
-			if duplicate {
-				var existing CrdtUpdate
-				err := transaction.Select(columnUpdateID).
-					Where(queryUserNoteHash, userID.String(), update.NoteID().String(), updateHash).
-					Take(&existing).Error
-				if err != nil {
-					service.logError(opApplyCrdtUpdates, reasonUpdateLookupFailed, err,
+	transactionError := service.retryOnBusy(transactionCtx, opApplyCrdtUpdates, func() error {
+		result.UpdateOutcomes = result.UpdateOutcomes[:0]
+		return service.db.WithContext(transactionCtx).Transaction(func(transaction *gorm.DB) error {
+			for _, update := range updates {
+				if quotaErr := service.checkNoteQuota(transaction, userID, update.NoteID()); quotaErr != nil {
+					return quotaErr
+				}
+
+				updateHash, hashErr := hashCrdtPayload(update.UpdateB64().String())
+				if hashErr != nil {
+					service.logError(opApplyCrdtUpdates, reasonUpdateHashFailed, hashErr,
 						zap.String(fieldUserID, userID.String()),
 						zap.String(fieldNoteID, update.NoteID().String()))
-					return newServiceError(opApplyCrdtUpdates, reasonUpdateLookupFailed, err)
+					return newServiceError(opApplyCrdtUpdates, reasonUpdateHashFailed, hashErr)
 				}
-				updateID = existing.UpdateID
-			}
 
-			updateIDDomain, idErr := NewCrdtUpdateID(updateID)
-			if idErr != nil {
-				service.logError(opApplyCrdtUpdates, reasonUpdateIDInvalid, idErr,
-					zap.String(fieldUserID, userID.String()),
-					zap.String(fieldNoteID, update.NoteID().String()))
-				return newServiceError(opApplyCrdtUpdates, reasonUpdateIDInvalid, idErr)
-			}
+				appliedAtSeconds := service.clock().UTC().Unix()
+				model := CrdtUpdate{
+					UserID:           userID.String(),
+					NoteID:           update.NoteID().String(),
+					UpdateB64:        update.UpdateB64().String(),
+					UpdateHash:       updateHash,
+					AppliedAtSeconds: appliedAtSeconds,
+				}
+				createResult := transaction.Clauses(clause.OnConflict{DoNothing: true}).Create(&model)
+				if createResult.Error != nil {
+					service.logError(opApplyCrdtUpdates, reasonUpdateInsertFailed, createResult.Error,
+						zap.String(fieldUserID, userID.String()),
+						zap.String(fieldNoteID, update.NoteID().String()))
+					return newServiceError(opApplyCrdtUpdates, reasonUpdateInsertFailed, createResult.Error)
+				}
 
-			outcome := CrdtUpdateOutcome{
-				noteID:    update.NoteID(),
-				updateID:  updateIDDomain,
-				duplicate: duplicate,
-			}
-			result.UpdateOutcomes = append(result.UpdateOutcomes, outcome)
+				duplicate := createResult.RowsAffected == 0
+				updateID := model.UpdateID
+				if duplicate {
+					var existing CrdtUpdate
+					err := transaction.Select(columnUpdateID).
+						Where(queryUserNoteHash, userID.String(), update.NoteID().String(), updateHash).
+						Take(&existing).Error
+					if err != nil {
+						service.logError(opApplyCrdtUpdates, reasonUpdateLookupFailed, err,
+							zap.String(fieldUserID, userID.String()),
+							zap.String(fieldNoteID, update.NoteID().String()))
+						return newServiceError(opApplyCrdtUpdates, reasonUpdateLookupFailed, err)
+					}
+					updateID = existing.UpdateID
+				}
 
-			snapshotUpdateID := update.SnapshotUpdateID().Int64()
-			if snapshotUpdateID > updateID {
-				snapshotUpdateID = updateID
-			}
-			allowEqualSnapshotUpdateID := !duplicate
-			if snapshotErr := service.upsertCrdtSnapshot(transaction, userID, update.NoteID(), update.SnapshotB64(), snapshotUpdateID, allowEqualSnapshotUpdateID); snapshotErr != nil {
-				service.logError(opApplyCrdtUpdates, reasonSnapshotUpsertFailed, snapshotErr,
-					zap.String(fieldUserID, userID.String()),
-					zap.String(fieldNoteID, update.NoteID().String()))
-				return newServiceError(opApplyCrdtUpdates, reasonSnapshotUpsertFailed, snapshotErr)
+				updateIDDomain, idErr := NewCrdtUpdateID(updateID)
+				if idErr != nil {
+					service.logError(opApplyCrdtUpdates, reasonUpdateIDInvalid, idErr,
+						zap.String(fieldUserID, userID.String()),
+						zap.String(fieldNoteID, update.NoteID().String()))
+					return newServiceError(opApplyCrdtUpdates, reasonUpdateIDInvalid, idErr)
+				}
+
+				outcome := CrdtUpdateOutcome{
+					noteID:    update.NoteID(),
+					updateID:  updateIDDomain,
+					duplicate: duplicate,
+				}
+				result.UpdateOutcomes = append(result.UpdateOutcomes, outcome)
+
+				snapshotUpdateID := update.SnapshotUpdateID().Int64()
+				if snapshotUpdateID > updateID {
+					snapshotUpdateID = updateID
+				}
+				allowEqualSnapshotUpdateID := !duplicate
+				changedAtSeconds := appliedAtSeconds
+				if duplicate {
+					changedAtSeconds = 0
+				}
+				if snapshotErr := service.upsertCrdtSnapshot(transaction, userID, update.NoteID(), update.SnapshotB64(), snapshotUpdateID, allowEqualSnapshotUpdateID, changedAtSeconds); snapshotErr != nil {
+					service.logError(opApplyCrdtUpdates, reasonSnapshotUpsertFailed, snapshotErr,
+						zap.String(fieldUserID, userID.String()),
+						zap.String(fieldNoteID, update.NoteID().String()))
+					return newServiceError(opApplyCrdtUpdates, reasonSnapshotUpsertFailed, snapshotErr)
+				}
 			}
-		}
-		return nil
+			return service.flagCompaction(transaction, userID, result.UpdateOutcomes)
+		})
 	})
 
 	if transactionError != nil {
-		return CrdtSyncResult{}, transactionError
+		return CrdtSyncResult{}, service.transactionFailure(ctx, transactionCtx, userID, len(updates), transactionError)
 	}
 	return result, nil
 }
 
+// flagCompaction marks the outcomes of every note that retains more updates after its snapshot than the
+// compaction threshold. It only signals the client; no updates are merged or removed. The retained counts
+// come from one grouped query per sqliteMaxVariables chunk of the batch's note ids.
+func (service *Service) flagCompaction(transaction *gorm.DB, userID UserID, outcomes []CrdtUpdateOutcome) error {
+	seen := make(map[NoteID]bool, len(outcomes))
+	noteIDs := make([]string, 0, len(outcomes))
+	for _, outcome := range outcomes {
+		if !seen[outcome.noteID] {
+			seen[outcome.noteID] = true
+			noteIDs = append(noteIDs, outcome.noteID.String())
+		}
+	}
+
+	retainedByNoteID := make(map[string]int64, len(noteIDs))
+	maxNotesPerQuery := sqliteMaxVariables - compactionQueryBaseVariables
+	for chunkStart := 0; chunkStart < len(noteIDs); chunkStart += maxNotesPerQuery {
+		chunkEnd := chunkStart + maxNotesPerQuery
+		if chunkEnd > len(noteIDs) {
+			chunkEnd = len(noteIDs)
+		}
+		var counts []noteRetainedCount
+		if err := transaction.Table(tableCrdtUpdatesAlias).
+			Select(selectRetainedByNote).
+			Joins(joinNoteSnapshot).
+			Where(queryRetainedAfterSnapshot, userID.String(), noteIDs[chunkStart:chunkEnd]).
+			Group(groupUpdateNoteID).
+			Scan(&counts).Error; err != nil {
+			service.logError(opApplyCrdtUpdates, reasonCompactionCheckFailed, err,
+				zap.String(fieldUserID, userID.String()))
+			return newServiceError(opApplyCrdtUpdates, reasonCompactionCheckFailed, err)
+		}
+		for _, count := range counts {
+			retainedByNoteID[count.NoteID] = count.Retained
+		}
+	}
+
+	for index := range outcomes {
+		outcomes[index].compactionRecommended = retainedByNoteID[outcomes[index].noteID.String()] > int64(service.compactionThreshold)
+	}
+	return nil
+}
+
+// noteRetainedCount is one row of flagCompaction's grouped count of updates beyond the snapshot.
+type noteRetainedCount struct {
+	NoteID   string
+	Retained int64
+}
+
+// transactionFailure reports err as a sync timeout when the configured deadline, rather than the caller, ended the work.
+func (service *Service) transactionFailure(ctx, transactionCtx context.Context, userID UserID, updateCount int, err error) error {
+	if ctx.Err() == nil && errors.Is(transactionCtx.Err(), context.DeadlineExceeded) {
+		timeoutErr := fmt.Errorf("%w after %s: %w", ErrSyncTimeout, service.syncTimeout, err)
+		service.logError(opApplyCrdtUpdates, reasonSyncTimeout, timeoutErr,
+			zap.String(fieldUserID, userID.String()),
+			zap.Int("update_count", updateCount))
+		return newServiceError(opApplyCrdtUpdates, reasonSyncTimeout, timeoutErr)
+	}
+	return err
+}
+
+// checkNoteQuota rejects an update that would create a new note once the user holds MaxNotesPerUser notes.
+// Updates to notes that already exist, including CRDT deletions, always pass. Before counting, a new note
+// locks the user's NoteQuotaLock row, which makes the count-then-insert atomic across every process
+// sharing the database rather than only within this one.
+func (service *Service) checkNoteQuota(transaction *gorm.DB, userID UserID, noteID NoteID) error {
+	if service.maxNotesPerUser <= 0 {
+		return nil
+	}
+	var existing int64
+	if err := transaction.Model(&CrdtSnapshot{}).
+		Where(queryUserNote, userID.String(), noteID.String()).
+		Count(&existing).Error; err != nil {
+		service.logError(opApplyCrdtUpdates, reasonNoteQuotaCheckFailed, err,
+			zap.String(fieldUserID, userID.String()),
+			zap.String(fieldNoteID, noteID.String()))
+		return newServiceError(opApplyCrdtUpdates, reasonNoteQuotaCheckFailed, err)
+	}
+	if existing > 0 {
+		return nil
+	}
+	quotaLock := NoteQuotaLock{UserID: userID.String(), LockedAtSeconds: service.clock().UTC().Unix()}
+	if err := transaction.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: fieldUserID}},
+		DoUpdates: clause.AssignmentColumns([]string{columnLockedAt}),
+	}).Create(&quotaLock).Error; err != nil {
+		service.logError(opApplyCrdtUpdates, reasonNoteQuotaCheckFailed, err,
+			zap.String(fieldUserID, userID.String()),
+			zap.String(fieldNoteID, noteID.String()))
+		return newServiceError(opApplyCrdtUpdates, reasonNoteQuotaCheckFailed, err)
+	}
+	var noteCount int64
+	if err := transaction.Model(&CrdtSnapshot{}).
+		Where(queryUserID, userID.String()).
+		Count(&noteCount).Error; err != nil {
+		service.logError(opApplyCrdtUpdates, reasonNoteQuotaCheckFailed, err,
+			zap.String(fieldUserID, userID.String()),
+			zap.String(fieldNoteID, noteID.String()))
+		return newServiceError(opApplyCrdtUpdates, reasonNoteQuotaCheckFailed, err)
+	}
+	if noteCount < int64(service.maxNotesPerUser) {
+		return nil
+	}
+	quotaErr := fmt.Errorf("%w: limit %d", ErrNoteQuotaExceeded, service.maxNotesPerUser)
+	service.logError(opApplyCrdtUpdates, reasonNoteQuotaExceeded, quotaErr,
+		zap.String(fieldUserID, userID.String()),
+		zap.String(fieldNoteID, noteID.String()))
+	return newServiceError(opApplyCrdtUpdates, reasonNoteQuotaExceeded, quotaErr)
+}
+
 // ListCrdtSnapshots returns stored CRDT snapshots for a user.
 func (service *Service) ListCrdtSnapshots(ctx context.Context, userID UserID) ([]CrdtSnapshotRecord, error) {
 	if service.db == nil {
@@ -219,40 +482,399 @@ func (service *Service) ListCrdtSnapshots(ctx context.Context, userID UserID) ([
 		return nil, newServiceError(opListCrdtSnapshots, reasonQueryFailed, err)
 	}
 
-	records := make([]CrdtSnapshotRecord, 0, len(snapshots))
+	return service.decodeCrdtSnapshots(opListCrdtSnapshots, snapshots)
+}
+
+// streamSnapshotBatchSize is how many snapshot rows StreamCrdtSnapshots reads per query.
+const streamSnapshotBatchSize = 100
+
+// StreamCrdtSnapshots calls visit for each of the user's snapshots in note identifier order. Rows are read
+// in keyset batches of streamSnapshotBatchSize and each batch is fully read before visit runs, so a slow
+// visitor never holds a database connection and large accounts are never held in memory. An error
+// returned by visit stops the iteration and is returned unchanged.
+func (service *Service) StreamCrdtSnapshots(ctx context.Context, userID UserID, visit func(CrdtSnapshotRecord) error) error {
+	if service.db == nil {
+		service.logError(opStreamCrdtSnapshots, reasonMissingDatabase, errMissingDatabase)
+		return newServiceError(opStreamCrdtSnapshots, reasonMissingDatabase, errMissingDatabase)
+	}
+
+	afterNoteID := ""
+	for {
+		query := service.db.WithContext(ctx).
+			Where(queryUserID, userID.String()).
+			Order(orderNoteIDAsc).
+			Limit(streamSnapshotBatchSize)
+		if afterNoteID != "" {
+			query = query.Where(queryNoteIDAfter, afterNoteID)
+		}
+		var batch []CrdtSnapshot
+		if err := query.Find(&batch).Error; err != nil {
+			service.logError(opStreamCrdtSnapshots, reasonQueryFailed, err, zap.String(fieldUserID, userID.String()))
+			return newServiceError(opStreamCrdtSnapshots, reasonQueryFailed, err)
+		}
+
+		for _, snapshot := range batch {
+			record, err := service.decodeCrdtSnapshot(opStreamCrdtSnapshots, snapshot)
+			if err != nil {
+				return err
+			}
+			if err := visit(record); err != nil {
+				return err
+			}
+		}
+		if len(batch) < streamSnapshotBatchSize {
+			return nil
+		}
+		afterNoteID = batch[len(batch)-1].NoteID
+	}
+}
+
+// ListCrdtSnapshotsPage returns one page of stored CRDT snapshots for a user ordered by note identifier.
+func (service *Service) ListCrdtSnapshotsPage(ctx context.Context, userID UserID, options CrdtSnapshotListOptions) (CrdtSnapshotPage, error) {
+	if service.db == nil {
+		service.logError(opListCrdtSnapshots, reasonMissingDatabase, errMissingDatabase)
+		return CrdtSnapshotPage{}, newServiceError(opListCrdtSnapshots, reasonMissingDatabase, errMissingDatabase)
+	}
+
+	query := service.db.WithContext(ctx).
+		Where(queryUserID, userID.String()).
+		Order(orderNoteIDAsc)
+	if afterNoteID := options.AfterNoteID(); afterNoteID != "" {
+		query = query.Where(queryNoteIDAfter, afterNoteID.String())
+	}
+	if options.Limit() > 0 {
+		query = query.Limit(options.Limit() + 1)
+	}
+
+	var snapshots []CrdtSnapshot
+	if err := query.Find(&snapshots).Error; err != nil {
+		service.logError(opListCrdtSnapshots, reasonQueryFailed, err, zap.String(fieldUserID, userID.String()))
+		return CrdtSnapshotPage{}, newServiceError(opListCrdtSnapshots, reasonQueryFailed, err)
+	}
+	hasMore := options.Limit() > 0 && len(snapshots) > options.Limit()
+	if hasMore {
+		snapshots = snapshots[:options.Limit()]
+	}
+
+	records, err := service.decodeCrdtSnapshots(opListCrdtSnapshots, snapshots)
+	if err != nil {
+		return CrdtSnapshotPage{}, err
+	}
+	page := CrdtSnapshotPage{Snapshots: records}
+	if hasMore && len(records) > 0 {
+		page.NextCursor = encodeSnapshotListCursor(records[len(records)-1].NoteID())
+	}
+	return page, nil
+}
+
+// ListCrdtSnapshotsSince returns snapshots for notes that received an update at or after the provided unix time.
+// Deletions are CRDT updates as well, so notes removed since then are included. Notes are matched by the
+// snapshot's changed_at_s rather than by their update rows, so compaction does not hide them.
+func (service *Service) ListCrdtSnapshotsSince(ctx context.Context, userID UserID, sinceSeconds int64) ([]CrdtSnapshotRecord, error) {
+	if service.db == nil {
+		service.logError(opListCrdtSnapshots, reasonMissingDatabase, errMissingDatabase)
+		return nil, newServiceError(opListCrdtSnapshots, reasonMissingDatabase, errMissingDatabase)
+	}
+
+	var snapshots []CrdtSnapshot
+	if err := service.db.WithContext(ctx).
+		Where(queryUserChangedSince, userID.String(), sinceSeconds).
+		Order(orderNoteIDAsc).
+		Find(&snapshots).Error; err != nil {
+		service.logError(opListCrdtSnapshots, reasonQueryFailed, err, zap.String(fieldUserID, userID.String()))
+		return nil, newServiceError(opListCrdtSnapshots, reasonQueryFailed, err)
+	}
+	return service.decodeCrdtSnapshots(opListCrdtSnapshots, snapshots)
+}
+
+// GetCrdtSnapshot returns the user's stored snapshot for one note, or ErrNoteNotFound when the user owns none.
+func (service *Service) GetCrdtSnapshot(ctx context.Context, userID UserID, noteID NoteID) (CrdtSnapshotRecord, error) {
+	snapshot, err := service.loadOwnedSnapshot(ctx, opGetCrdtSnapshot, userID, noteID)
+	if err != nil {
+		return CrdtSnapshotRecord{}, err
+	}
+	return service.decodeCrdtSnapshot(opGetCrdtSnapshot, snapshot)
+}
+
+// GetCrdtSnapshotsByNoteIDs returns the user's snapshots for the requested notes ordered by note identifier.
+// Identifiers without a snapshot owned by the user are skipped rather than reported.
+func (service *Service) GetCrdtSnapshotsByNoteIDs(ctx context.Context, userID UserID, noteIDs []NoteID) ([]CrdtSnapshotRecord, error) {
+	if len(noteIDs) > MaxBatchNoteIDs {
+		batchErr := fmt.Errorf("%w: %d exceeds %d", ErrTooManyNoteIDs, len(noteIDs), MaxBatchNoteIDs)
+		service.logError(opGetCrdtSnapshots, reasonTooManyNoteIDs, batchErr, zap.String(fieldUserID, userID.String()))
+		return nil, newServiceError(opGetCrdtSnapshots, reasonTooManyNoteIDs, batchErr)
+	}
+	if len(noteIDs) == 0 {
+		return nil, nil
+	}
+	if service.db == nil {
+		service.logError(opGetCrdtSnapshots, reasonMissingDatabase, errMissingDatabase)
+		return nil, newServiceError(opGetCrdtSnapshots, reasonMissingDatabase, errMissingDatabase)
+	}
+
+	noteIDValues := make([]string, 0, len(noteIDs))
+	for _, noteID := range noteIDs {
+		noteIDValues = append(noteIDValues, noteID.String())
+	}
+
+	var snapshots []CrdtSnapshot
+	if err := service.db.WithContext(ctx).
+		Where(queryUserID, userID.String()).
+		Where(queryNoteIDIn, noteIDValues).
+		Order(orderNoteIDAsc).
+		Find(&snapshots).Error; err != nil {
+		service.logError(opGetCrdtSnapshots, reasonQueryFailed, err, zap.String(fieldUserID, userID.String()))
+		return nil, newServiceError(opGetCrdtSnapshots, reasonQueryFailed, err)
+	}
+	return service.decodeCrdtSnapshots(opGetCrdtSnapshots, snapshots)
+}
+
+// GetCrdtListingVersion returns aggregate counters describing the user's current snapshots and updates.
+func (service *Service) GetCrdtListingVersion(ctx context.Context, userID UserID) (CrdtListingVersion, error) {
+	if service.db == nil {
+		service.logError(opCrdtListingVersion, reasonMissingDatabase, errMissingDatabase)
+		return CrdtListingVersion{}, newServiceError(opCrdtListingVersion, reasonMissingDatabase, errMissingDatabase)
+	}
+
+	var version CrdtListingVersion
+	if err := service.db.WithContext(ctx).Model(&CrdtSnapshot{}).
+		Select("COUNT(*) AS snapshot_count, COALESCE(SUM(snapshot_update_id), 0) AS snapshot_coverage").
+		Where(queryUserID, userID.String()).
+		Scan(&version).Error; err != nil {
+		service.logError(opCrdtListingVersion, reasonQueryFailed, err, zap.String(fieldUserID, userID.String()))
+		return CrdtListingVersion{}, newServiceError(opCrdtListingVersion, reasonQueryFailed, err)
+	}
+	if err := service.db.WithContext(ctx).Model(&CrdtUpdate{}).
+		Select("COALESCE(MAX(update_id), 0)").
+		Where(queryUserID, userID.String()).
+		Scan(&version.MaxUpdateID).Error; err != nil {
+		service.logError(opCrdtListingVersion, reasonQueryFailed, err, zap.String(fieldUserID, userID.String()))
+		return CrdtListingVersion{}, newServiceError(opCrdtListingVersion, reasonQueryFailed, err)
+	}
+	return version, nil
+}
+
+// UserStats returns note and update counts plus stored byte totals, computed in the database without loading rows.
+func (service *Service) UserStats(ctx context.Context, userID UserID) (UserStats, error) {
+	if service.db == nil {
+		service.logError(opUserStats, reasonMissingDatabase, errMissingDatabase)
+		return UserStats{}, newServiceError(opUserStats, reasonMissingDatabase, errMissingDatabase)
+	}
+
+	var stats UserStats
+	if err := service.db.WithContext(ctx).Model(&CrdtSnapshot{}).
+		Select("COUNT(*) AS note_count, COALESCE(SUM(LENGTH(snapshot_b64)), 0) AS snapshot_bytes").
+		Where(queryUserID, userID.String()).
+		Scan(&stats).Error; err != nil {
+		service.logError(opUserStats, reasonQueryFailed, err, zap.String(fieldUserID, userID.String()))
+		return UserStats{}, newServiceError(opUserStats, reasonQueryFailed, err)
+	}
+	var updateStats UserStats
+	if err := service.db.WithContext(ctx).Model(&CrdtUpdate{}).
+		Select("COUNT(*) AS update_count, COALESCE(SUM(LENGTH(update_b64)), 0) AS update_bytes").
+		Where(queryUserID, userID.String()).
+		Scan(&updateStats).Error; err != nil {
+		service.logError(opUserStats, reasonQueryFailed, err, zap.String(fieldUserID, userID.String()))
+		return UserStats{}, newServiceError(opUserStats, reasonQueryFailed, err)
+	}
+	stats.UpdateCount = updateStats.UpdateCount
+	stats.UpdateBytes = updateStats.UpdateBytes
+	return stats, nil
+}
+
+// ExportUserData gathers the user's snapshots and the full retained update history for each note.
+func (service *Service) ExportUserData(ctx context.Context, userID UserID) (UserExport, error) {
+	snapshots, err := service.ListCrdtSnapshots(ctx, userID)
+	if err != nil {
+		return UserExport{}, err
+	}
+
+	cursors := make([]CrdtCursor, 0, len(snapshots))
 	for _, snapshot := range snapshots {
-		noteID, noteErr := NewNoteID(snapshot.NoteID)
-		if noteErr != nil {
-			service.logError(opListCrdtSnapshots, reasonSnapshotNoteInvalid, noteErr, zap.String(fieldNoteID, snapshot.NoteID))
-			return nil, newServiceError(opListCrdtSnapshots, reasonSnapshotNoteInvalid, noteErr)
+		cursor, cursorErr := NewCrdtCursor(CrdtCursorConfig{NoteID: snapshot.NoteID(), LastUpdateID: CrdtUpdateID(0)})
+		if cursorErr != nil {
+			service.logError(opExportUserData, reasonSnapshotNoteInvalid, cursorErr, zap.String(fieldUserID, userID.String()))
+			return UserExport{}, newServiceError(opExportUserData, reasonSnapshotNoteInvalid, cursorErr)
 		}
-		snapshotB64, snapErr := NewCrdtSnapshotBase64(snapshot.SnapshotB64)
-		if snapErr != nil {
-			service.logError(opListCrdtSnapshots, reasonSnapshotPayloadInvalid, snapErr, zap.String(fieldNoteID, snapshot.NoteID))
-			return nil, newServiceError(opListCrdtSnapshots, reasonSnapshotPayloadInvalid, snapErr)
+		cursors = append(cursors, cursor)
+	}
+	updates, err := service.ListCrdtUpdates(ctx, userID, cursors)
+	if err != nil {
+		return UserExport{}, err
+	}
+	tags, err := service.listUserTags(ctx, opExportUserData, userID)
+	if err != nil {
+		return UserExport{}, err
+	}
+
+	return UserExport{
+		UserID:     userID,
+		ExportedAt: service.clock().UTC(),
+		Snapshots:  snapshots,
+		Updates:    updates,
+		Tags:       tags,
+	}, nil
+}
+
+// CompactCrdtUpdates deletes updates already covered by each note's snapshot and returns the number removed.
+// Notes without a snapshot covering any update are left untouched.
+func (service *Service) CompactCrdtUpdates(ctx context.Context, userID UserID) (int64, error) {
+	if service.db == nil {
+		service.logError(opCompactCrdtUpdates, reasonMissingDatabase, errMissingDatabase)
+		return 0, newServiceError(opCompactCrdtUpdates, reasonMissingDatabase, errMissingDatabase)
+	}
+
+	var removed int64
+	transactionError := service.db.WithContext(ctx).Transaction(func(transaction *gorm.DB) error {
+		var snapshots []CrdtSnapshot
+		if err := transaction.
+			Where(queryUserID, userID.String()).
+			Where(querySnapshotCoversUpdates).
+			Find(&snapshots).Error; err != nil {
+			service.logError(opCompactCrdtUpdates, reasonQueryFailed, err, zap.String(fieldUserID, userID.String()))
+			return newServiceError(opCompactCrdtUpdates, reasonQueryFailed, err)
 		}
-		snapshotUpdateID, idErr := NewCrdtUpdateID(snapshot.SnapshotUpdateID)
-		if idErr != nil {
-			service.logError(opListCrdtSnapshots, reasonSnapshotUpdateIDInvalid, idErr, zap.String(fieldNoteID, snapshot.NoteID))
-			return nil, newServiceError(opListCrdtSnapshots, reasonSnapshotUpdateIDInvalid, idErr)
+
+		for _, snapshot := range snapshots {
+			deleteResult := transaction.
+				Where(queryUserNoteUpdateThrough, userID.String(), snapshot.NoteID, snapshot.SnapshotUpdateID).
+				Delete(&CrdtUpdate{})
+			if deleteResult.Error != nil {
+				service.logError(opCompactCrdtUpdates, reasonUpdateDeleteFailed, deleteResult.Error,
+					zap.String(fieldUserID, userID.String()),
+					zap.String(fieldNoteID, snapshot.NoteID))
+				return newServiceError(opCompactCrdtUpdates, reasonUpdateDeleteFailed, deleteResult.Error)
+			}
+			removed += deleteResult.RowsAffected
 		}
-		records = append(records, CrdtSnapshotRecord{
-			noteID:           noteID,
-			snapshotB64:      snapshotB64,
-			snapshotUpdateID: snapshotUpdateID,
-		})
+		return nil
+	})
+	if transactionError != nil {
+		return 0, transactionError
+	}
+	return removed, nil
+}
+
+// DeleteUserData removes every CRDT update, snapshot, tag and quota lock owned by the user in a single
+// transaction. It holds the user's write lock, so a sync already in flight commits first and cannot
+// recreate data after the delete. Deleting a user without stored data succeeds.
+func (service *Service) DeleteUserData(ctx context.Context, userID UserID) error {
+	if service.db == nil {
+		service.logError(opDeleteUserData, reasonMissingDatabase, errMissingDatabase)
+		return newServiceError(opDeleteUserData, reasonMissingDatabase, errMissingDatabase)
+	}
+
+	if service.userLocks != nil {
+		release, lockErr := service.userLocks.acquire(ctx, userID)
+		if lockErr != nil {
+			service.logError(opDeleteUserData, reasonUserLockFailed, lockErr, zap.String(fieldUserID, userID.String()))
+			return newServiceError(opDeleteUserData, reasonUserLockFailed, lockErr)
+		}
+		defer release()
+	}
+
+	return service.db.WithContext(ctx).Transaction(func(transaction *gorm.DB) error {
+		if err := transaction.Where(queryUserID, userID.String()).Delete(&CrdtUpdate{}).Error; err != nil {
+			service.logError(opDeleteUserData, reasonUpdateDeleteFailed, err, zap.String(fieldUserID, userID.String()))
+			return newServiceError(opDeleteUserData, reasonUpdateDeleteFailed, err)
+		}
+		if err := transaction.Where(queryUserID, userID.String()).Delete(&CrdtSnapshot{}).Error; err != nil {
+			service.logError(opDeleteUserData, reasonSnapshotDeleteFailed, err, zap.String(fieldUserID, userID.String()))
+			return newServiceError(opDeleteUserData, reasonSnapshotDeleteFailed, err)
+		}
+		if err := transaction.Where(queryUserID, userID.String()).Delete(&NoteTag{}).Error; err != nil {
+			service.logError(opDeleteUserData, reasonTagDeleteFailed, err, zap.String(fieldUserID, userID.String()))
+			return newServiceError(opDeleteUserData, reasonTagDeleteFailed, err)
+		}
+		if err := transaction.Where(queryUserID, userID.String()).Delete(&NoteQuotaLock{}).Error; err != nil {
+			service.logError(opDeleteUserData, reasonQuotaLockDeleteFailed, err, zap.String(fieldUserID, userID.String()))
+			return newServiceError(opDeleteUserData, reasonQuotaLockDeleteFailed, err)
+		}
+		return nil
+	})
+}
+
+// loadOwnedSnapshot fetches the snapshot for noteID scoped to userID. A note that exists only under
+// another user is reported exactly like a missing one, with ErrNoteNotFound, so note-scoped routes
+// cannot be used to probe for other users' note identifiers.
+func (service *Service) loadOwnedSnapshot(ctx context.Context, operation string, userID UserID, noteID NoteID) (CrdtSnapshot, error) {
+	if service.db == nil {
+		service.logError(operation, reasonMissingDatabase, errMissingDatabase)
+		return CrdtSnapshot{}, newServiceError(operation, reasonMissingDatabase, errMissingDatabase)
+	}
+	var snapshot CrdtSnapshot
+	err := service.db.WithContext(ctx).
+		Where(queryUserNote, userID.String(), noteID.String()).
+		Take(&snapshot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return CrdtSnapshot{}, newServiceError(operation, reasonNoteNotFound, ErrNoteNotFound)
+	}
+	if err != nil {
+		service.logError(operation, reasonQueryFailed, err,
+			zap.String(fieldUserID, userID.String()),
+			zap.String(fieldNoteID, noteID.String()))
+		return CrdtSnapshot{}, newServiceError(operation, reasonQueryFailed, err)
+	}
+	return snapshot, nil
+}
+
+func (service *Service) decodeCrdtSnapshots(operation string, snapshots []CrdtSnapshot) ([]CrdtSnapshotRecord, error) {
+	records := make([]CrdtSnapshotRecord, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		record, err := service.decodeCrdtSnapshot(operation, snapshot)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
 	}
 	return records, nil
 }
 
+func (service *Service) decodeCrdtSnapshot(operation string, snapshot CrdtSnapshot) (CrdtSnapshotRecord, error) {
+	noteID, noteErr := NewNoteID(snapshot.NoteID)
+	if noteErr != nil {
+		service.logError(operation, reasonSnapshotNoteInvalid, noteErr, zap.String(fieldNoteID, snapshot.NoteID))
+		return CrdtSnapshotRecord{}, newServiceError(operation, reasonSnapshotNoteInvalid, noteErr)
+	}
+	snapshotB64, snapErr := NewCrdtSnapshotBase64(snapshot.SnapshotB64)
+	if snapErr != nil {
+		service.logError(operation, reasonSnapshotPayloadInvalid, snapErr, zap.String(fieldNoteID, snapshot.NoteID))
+		return CrdtSnapshotRecord{}, newServiceError(operation, reasonSnapshotPayloadInvalid, snapErr)
+	}
+	snapshotUpdateID, idErr := NewCrdtUpdateID(snapshot.SnapshotUpdateID)
+	if idErr != nil {
+		service.logError(operation, reasonSnapshotUpdateIDInvalid, idErr, zap.String(fieldNoteID, snapshot.NoteID))
+		return CrdtSnapshotRecord{}, newServiceError(operation, reasonSnapshotUpdateIDInvalid, idErr)
+	}
+	return CrdtSnapshotRecord{
+		noteID:           noteID,
+		snapshotB64:      snapshotB64,
+		snapshotUpdateID: snapshotUpdateID,
+	}, nil
+}
+
 // ListCrdtUpdates returns updates after the provided cursors.
 func (service *Service) ListCrdtUpdates(ctx context.Context, userID UserID, cursors []CrdtCursor) ([]CrdtUpdateRecord, error) {
+	page, err := service.ListCrdtUpdatesPage(ctx, userID, cursors, 0)
+	if err != nil {
+		return nil, err
+	}
+	return page.Updates, nil
+}
+
+// ListCrdtUpdatesPage returns at most limit updates after the provided cursors ordered by update identifier;
+// a zero limit returns every remaining update. Passing NextCursors back resumes after the last returned update.
+func (service *Service) ListCrdtUpdatesPage(ctx context.Context, userID UserID, cursors []CrdtCursor, limit int) (CrdtUpdatePage, error) {
 	if service.db == nil {
 		service.logError(opListCrdtUpdates, reasonMissingDatabase, errMissingDatabase)
-		return nil, newServiceError(opListCrdtUpdates, reasonMissingDatabase, errMissingDatabase)
+		return CrdtUpdatePage{}, newServiceError(opListCrdtUpdates, reasonMissingDatabase, errMissingDatabase)
+	}
+	if limit < 0 || limit > MaxListLimit {
+		limitErr := fmt.Errorf("%w: %d", ErrInvalidListLimit, limit)
+		return CrdtUpdatePage{}, newServiceError(opListCrdtUpdates, reasonInvalidLimit, limitErr)
 	}
 	if len(cursors) == 0 {
-		return nil, nil
+		return CrdtUpdatePage{}, nil
 	}
 
 	cursorByNoteID := make(map[string]int64, len(cursors))
@@ -292,13 +914,16 @@ func (service *Service) ListCrdtUpdates(ctx context.Context, userID UserID, curs
 		}
 		cursorQuery := queryUserID + " AND (" + strings.Join(queryParts, " OR ") + ")"
 
-		var chunkUpdates []CrdtUpdate
-		if err := service.db.WithContext(ctx).
+		query := service.db.WithContext(ctx).
 			Where(cursorQuery, queryArgs...).
-			Order(orderUpdateIDAsc).
-			Find(&chunkUpdates).Error; err != nil {
+			Order(orderUpdateIDAsc)
+		if limit > 0 {
+			query = query.Limit(limit + 1)
+		}
+		var chunkUpdates []CrdtUpdate
+		if err := query.Find(&chunkUpdates).Error; err != nil {
 			service.logError(opListCrdtUpdates, reasonQueryFailed, err, zap.String(fieldUserID, userIDValue))
-			return nil, newServiceError(opListCrdtUpdates, reasonQueryFailed, err)
+			return CrdtUpdatePage{}, newServiceError(opListCrdtUpdates, reasonQueryFailed, err)
 		}
 		updates = append(updates, chunkUpdates...)
 	}
@@ -307,34 +932,50 @@ func (service *Service) ListCrdtUpdates(ctx context.Context, userID UserID, curs
 			return updates[leftIndex].UpdateID < updates[rightIndex].UpdateID
 		})
 	}
+	// Every chunk returns its lowest update ids, so the merged prefix is the page across all chunks.
+	hasMore := limit > 0 && len(updates) > limit
+	if hasMore {
+		updates = updates[:limit]
+	}
 
 	records := make([]CrdtUpdateRecord, 0, len(updates))
 	for _, update := range updates {
 		noteID, noteErr := NewNoteID(update.NoteID)
 		if noteErr != nil {
 			service.logError(opListCrdtUpdates, reasonUpdateNoteInvalid, noteErr, zap.String(fieldNoteID, update.NoteID))
-			return nil, newServiceError(opListCrdtUpdates, reasonUpdateNoteInvalid, noteErr)
+			return CrdtUpdatePage{}, newServiceError(opListCrdtUpdates, reasonUpdateNoteInvalid, noteErr)
 		}
 		updateID, idErr := NewCrdtUpdateID(update.UpdateID)
 		if idErr != nil {
 			service.logError(opListCrdtUpdates, reasonUpdateIDInvalid, idErr, zap.String(fieldNoteID, update.NoteID))
-			return nil, newServiceError(opListCrdtUpdates, reasonUpdateIDInvalid, idErr)
+			return CrdtUpdatePage{}, newServiceError(opListCrdtUpdates, reasonUpdateIDInvalid, idErr)
 		}
 		updateB64, updateErr := NewCrdtUpdateBase64(update.UpdateB64)
 		if updateErr != nil {
 			service.logError(opListCrdtUpdates, reasonUpdatePayloadInvalid, updateErr, zap.String(fieldNoteID, update.NoteID))
-			return nil, newServiceError(opListCrdtUpdates, reasonUpdatePayloadInvalid, updateErr)
+			return CrdtUpdatePage{}, newServiceError(opListCrdtUpdates, reasonUpdatePayloadInvalid, updateErr)
 		}
 		records = append(records, CrdtUpdateRecord{
 			noteID:    noteID,
 			updateID:  updateID,
 			updateB64: updateB64,
 		})
+		cursorByNoteID[update.NoteID] = update.UpdateID
 	}
-	return records, nil
+
+	nextCursors := make([]CrdtCursor, 0, len(noteIDs))
+	for _, noteIDValue := range noteIDs {
+		nextCursors = append(nextCursors, CrdtCursor{
+			noteID:       NoteID(noteIDValue),
+			lastUpdateID: CrdtUpdateID(cursorByNoteID[noteIDValue]),
+		})
+	}
+	return CrdtUpdatePage{Updates: records, HasMore: hasMore, NextCursors: nextCursors}, nil
 }
 
-func (service *Service) upsertCrdtSnapshot(transaction *gorm.DB, userID UserID, noteID NoteID, snapshot CrdtSnapshotBase64, snapshotUpdateID int64, allowEqualSnapshotUpdateID bool) error {
+// upsertCrdtSnapshot stores the snapshot unless it would regress coverage. A positive changedAtSeconds
+// also advances the note's change time, whether or not the snapshot itself is replaced.
+func (service *Service) upsertCrdtSnapshot(transaction *gorm.DB, userID UserID, noteID NoteID, snapshot CrdtSnapshotBase64, snapshotUpdateID int64, allowEqualSnapshotUpdateID bool, changedAtSeconds int64) error {
 	var existing CrdtSnapshot
 	err := transaction.Clauses(clause.Locking{Strength: "UPDATE"}).
 		Where(queryUserNote, userID.String(), noteID.String()).
@@ -346,13 +987,24 @@ func (service *Service) upsertCrdtSnapshot(transaction *gorm.DB, userID UserID,
 			NoteID:           noteID.String(),
 			SnapshotB64:      snapshotValue,
 			SnapshotUpdateID: snapshotUpdateID,
+			ChangedAtSeconds: changedAtSeconds,
 		}).Error
 	}
 	if err != nil {
 		return err
 	}
+	touched := changedAtSeconds > existing.ChangedAtSeconds
+	if touched {
+		existing.ChangedAtSeconds = changedAtSeconds
+	}
+	keepSnapshot := func() error {
+		if !touched {
+			return nil
+		}
+		return transaction.Save(&existing).Error
+	}
 	if snapshotUpdateID < existing.SnapshotUpdateID {
-		return nil
+		return keepSnapshot()
 	}
 	snapshotValue := snapshot.String()
 	if snapshotUpdateID == existing.SnapshotUpdateID {
@@ -365,10 +1017,10 @@ func (service *Service) upsertCrdtSnapshot(transaction *gorm.DB, userID UserID,
 			return existingHashErr
 		}
 		if incomingHash == existingHash {
-			return nil
+			return keepSnapshot()
 		}
 		if !allowEqualSnapshotUpdateID {
-			return nil
+			return keepSnapshot()
 		}
 		existing.SnapshotB64 = snapshotValue
 		return transaction.Save(&existing).Error
@@ -378,6 +1030,22 @@ func (service *Service) upsertCrdtSnapshot(transaction *gorm.DB, userID UserID,
 	return transaction.Save(&existing).Error
 }
 
+// checkPayloadSize measures each payload from its base64 length, so oversized batches are rejected without
+// decoding them. Payloads are normalized padded base64, so any other length is reported as invalid.
+func (service *Service) checkPayloadSize(update CrdtUpdateEnvelope) error {
+	for _, payload := range []string{update.UpdateB64().String(), update.SnapshotB64().String()} {
+		if len(payload)%4 != 0 {
+			return fmt.Errorf("%w: base64 length %d is not padded", ErrInvalidCrdtUpdate, len(payload))
+		}
+		padding := len(payload) - len(strings.TrimRight(payload, "="))
+		decodedBytes := base64.StdEncoding.DecodedLen(len(payload)) - padding
+		if decodedBytes > service.maxPayloadBytes {
+			return fmt.Errorf("%w: %d bytes exceeds %d", ErrPayloadTooLarge, decodedBytes, service.maxPayloadBytes)
+		}
+	}
+	return nil
+}
+
 func hashCrdtPayload(payload string) (string, error) {
 	rawBytes, err := base64.StdEncoding.DecodeString(payload)
 	if err != nil {
@@ -2,7 +2,13 @@ package notes
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -268,13 +274,1060 @@ func TestListCrdtUpdatesChunksCursorQueries(testContext *testing.T) {
 	}
 }
 
+func TestListCrdtSnapshotsPageIsDeterministic(testContext *testing.T) {
+	service := mustCrdtService(testContext)
+	userID := mustUserID(testContext, "user-crdt-page")
+	backgroundContext := context.Background()
+
+	noteIDs := []string{"note-e", "note-a", "note-d", "note-b", "note-c"}
+	updates := make([]CrdtUpdateEnvelope, 0, len(noteIDs))
+	for _, noteIDValue := range noteIDs {
+		updates = append(updates, mustCrdtUpdateEnvelope(testContext, userID, mustNoteID(testContext, noteIDValue), baseUpdateB64, baseSnapshotB64, 0))
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, userID, updates); err != nil {
+		testContext.Fatalf("apply crdt updates failed: %v", err)
+	}
+
+	collected := make([]string, 0, len(noteIDs))
+	cursor := ""
+	for pageIndex := 0; pageIndex < len(noteIDs); pageIndex++ {
+		options, err := NewCrdtSnapshotListOptions(2, cursor)
+		if err != nil {
+			testContext.Fatalf("failed to build list options: %v", err)
+		}
+		page, err := service.ListCrdtSnapshotsPage(backgroundContext, userID, options)
+		if err != nil {
+			testContext.Fatalf("list snapshots page failed: %v", err)
+		}
+		for _, snapshot := range page.Snapshots {
+			collected = append(collected, snapshot.NoteID().String())
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	expected := []string{"note-a", "note-b", "note-c", "note-d", "note-e"}
+	if fmt.Sprint(collected) != fmt.Sprint(expected) {
+		testContext.Fatalf("unexpected paged snapshots: got %v want %v", collected, expected)
+	}
+}
+
+func TestListCrdtUpdatesPageWalksUpdatesWithoutGaps(testContext *testing.T) {
+	service := mustCrdtService(testContext)
+	userID := mustUserID(testContext, "user-crdt-update-page")
+	noteID := mustNoteID(testContext, "note-update-page")
+	backgroundContext := context.Background()
+
+	const seededUpdates = 23
+	updates := make([]CrdtUpdateEnvelope, 0, seededUpdates)
+	for index := 0; index < seededUpdates; index++ {
+		updateB64 := base64.StdEncoding.EncodeToString([]byte{1, 2, byte(index)})
+		updates = append(updates, mustCrdtUpdateEnvelope(testContext, userID, noteID, updateB64, baseSnapshotB64, 0))
+	}
+	result, err := service.ApplyCrdtUpdates(backgroundContext, userID, updates)
+	if err != nil {
+		testContext.Fatalf("apply crdt updates failed: %v", err)
+	}
+	expected := make([]int64, 0, seededUpdates)
+	for _, outcome := range result.UpdateOutcomes {
+		expected = append(expected, outcome.UpdateID().Int64())
+	}
+
+	collected := make([]int64, 0, seededUpdates)
+	cursors := []CrdtCursor{mustCrdtCursor(testContext, noteID, 0)}
+	for pageIndex := 0; pageIndex <= seededUpdates; pageIndex++ {
+		page, err := service.ListCrdtUpdatesPage(backgroundContext, userID, cursors, 5)
+		if err != nil {
+			testContext.Fatalf("list updates page failed: %v", err)
+		}
+		if len(page.Updates) > 5 {
+			testContext.Fatalf("page exceeded the limit: %d updates", len(page.Updates))
+		}
+		for _, update := range page.Updates {
+			collected = append(collected, update.UpdateID().Int64())
+		}
+		if !page.HasMore {
+			break
+		}
+		cursors = page.NextCursors
+	}
+
+	if fmt.Sprint(collected) != fmt.Sprint(expected) {
+		testContext.Fatalf("unexpected paged updates: got %v want %v", collected, expected)
+	}
+}
+
+func TestListCrdtUpdatesPageRejectsInvalidLimit(testContext *testing.T) {
+	service := mustCrdtService(testContext)
+	userID := mustUserID(testContext, "user-crdt-update-page-limit")
+	cursors := []CrdtCursor{mustCrdtCursor(testContext, mustNoteID(testContext, "note-a"), 0)}
+
+	for _, limit := range []int{-1, MaxListLimit + 1} {
+		if _, err := service.ListCrdtUpdatesPage(context.Background(), userID, cursors, limit); !errors.Is(err, ErrInvalidListLimit) {
+			testContext.Fatalf("expected invalid limit error for %d, got %v", limit, err)
+		}
+	}
+}
+
+func TestStreamCrdtSnapshotsVisitsInOrderAndStopsOnError(testContext *testing.T) {
+	service := mustCrdtService(testContext)
+	userID := mustUserID(testContext, "user-crdt-stream")
+	backgroundContext := context.Background()
+
+	updates := make([]CrdtUpdateEnvelope, 0, 3)
+	for _, noteIDValue := range []string{"note-stream-c", "note-stream-a", "note-stream-b"} {
+		updates = append(updates, mustCrdtUpdateEnvelope(testContext, userID, mustNoteID(testContext, noteIDValue), baseUpdateB64, baseSnapshotB64, 0))
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, userID, updates); err != nil {
+		testContext.Fatalf("apply crdt updates failed: %v", err)
+	}
+
+	visited := make([]string, 0, len(updates))
+	if err := service.StreamCrdtSnapshots(backgroundContext, userID, func(record CrdtSnapshotRecord) error {
+		visited = append(visited, record.NoteID().String())
+		return nil
+	}); err != nil {
+		testContext.Fatalf("stream snapshots failed: %v", err)
+	}
+	if expected := []string{"note-stream-a", "note-stream-b", "note-stream-c"}; !slices.Equal(visited, expected) {
+		testContext.Fatalf("unexpected streamed snapshots: got %v want %v", visited, expected)
+	}
+
+	stop := errors.New("client went away")
+	visitCount := 0
+	err := service.StreamCrdtSnapshots(backgroundContext, userID, func(CrdtSnapshotRecord) error {
+		visitCount++
+		return stop
+	})
+	if !errors.Is(err, stop) || visitCount != 1 {
+		testContext.Fatalf("expected the visitor error after one record, got %v after %d records", err, visitCount)
+	}
+}
+
+func TestStreamCrdtSnapshotsReleasesConnectionBetweenBatches(testContext *testing.T) {
+	service := mustCrdtService(testContext)
+	userID := mustUserID(testContext, "user-crdt-stream-batches")
+	backgroundContext := context.Background()
+
+	noteCount := 2*streamSnapshotBatchSize + 1
+	updates := make([]CrdtUpdateEnvelope, 0, noteCount)
+	for index := 0; index < noteCount; index++ {
+		noteID := mustNoteID(testContext, fmt.Sprintf("note-stream-batch-%03d", index))
+		updates = append(updates, mustCrdtUpdateEnvelope(testContext, userID, noteID, baseUpdateB64, baseSnapshotB64, 0))
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, userID, updates); err != nil {
+		testContext.Fatalf("apply crdt updates failed: %v", err)
+	}
+
+	sqlDatabase, err := service.db.DB()
+	if err != nil {
+		testContext.Fatalf("failed to access connection pool: %v", err)
+	}
+	sqlDatabase.SetMaxOpenConns(1)
+	defer sqlDatabase.SetMaxOpenConns(0)
+
+	visited := 0
+	if err := service.StreamCrdtSnapshots(backgroundContext, userID, func(record CrdtSnapshotRecord) error {
+		visited++
+		lookupContext, cancel := context.WithTimeout(backgroundContext, 2*time.Second)
+		defer cancel()
+		_, lookupErr := service.GetCrdtSnapshot(lookupContext, userID, record.NoteID())
+		return lookupErr
+	}); err != nil {
+		testContext.Fatalf("expected other queries to run while visiting, got %v", err)
+	}
+	if visited != noteCount {
+		testContext.Fatalf("expected %d snapshots across batches, got %d", noteCount, visited)
+	}
+}
+
+func TestListCrdtSnapshotsSinceReturnsOnlyChangedNotes(testContext *testing.T) {
+	service := mustCrdtService(testContext)
+	userID := mustUserID(testContext, "user-crdt-since")
+	backgroundContext := context.Background()
+
+	currentTime := time.Unix(1700000000, 0).UTC()
+	service.clock = func() time.Time {
+		return currentTime
+	}
+
+	staleNoteID := mustNoteID(testContext, "note-since-stale")
+	changedNoteID := mustNoteID(testContext, "note-since-changed")
+	initialUpdates := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, staleNoteID, baseUpdateB64, baseSnapshotB64, 0),
+		mustCrdtUpdateEnvelope(testContext, userID, changedNoteID, baseUpdateB64, baseSnapshotB64, 0),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, userID, initialUpdates); err != nil {
+		testContext.Fatalf("apply initial crdt updates failed: %v", err)
+	}
+
+	currentTime = currentTime.Add(time.Hour)
+	followUp := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, changedNoteID, secondUpdateB64, secondSnapshotB64, 1<<40),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, userID, followUp); err != nil {
+		testContext.Fatalf("apply follow-up crdt update failed: %v", err)
+	}
+	if removed, err := service.CompactCrdtUpdates(backgroundContext, userID); err != nil || removed != 2 {
+		testContext.Fatalf("expected compaction to remove the changed note's updates, removed %d: %v", removed, err)
+	}
+
+	testCases := []struct {
+		name          string
+		sinceSeconds  int64
+		expectedNotes []string
+	}{
+		{name: "before-all-updates", sinceSeconds: 0, expectedNotes: []string{changedNoteID.String(), staleNoteID.String()}},
+		{name: "after-initial-updates", sinceSeconds: currentTime.Unix(), expectedNotes: []string{changedNoteID.String()}},
+		{name: "after-all-updates", sinceSeconds: currentTime.Add(time.Second).Unix(), expectedNotes: []string{}},
+	}
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(testContext *testing.T) {
+			snapshots, err := service.ListCrdtSnapshotsSince(backgroundContext, userID, testCase.sinceSeconds)
+			if err != nil {
+				testContext.Fatalf("list snapshots since failed: %v", err)
+			}
+			collected := make([]string, 0, len(snapshots))
+			for _, snapshot := range snapshots {
+				collected = append(collected, snapshot.NoteID().String())
+			}
+			if fmt.Sprint(collected) != fmt.Sprint(testCase.expectedNotes) {
+				testContext.Fatalf("unexpected changed notes: got %v want %v", collected, testCase.expectedNotes)
+			}
+		})
+	}
+}
+
+func TestGetCrdtSnapshotsByNoteIDsSkipsForeignAndMissingNotes(testContext *testing.T) {
+	service := mustCrdtService(testContext)
+	backgroundContext := context.Background()
+	userID := mustUserID(testContext, "user-batch-owner")
+	otherUserID := mustUserID(testContext, "user-batch-other")
+	firstNoteID := mustNoteID(testContext, "note-batch-first")
+	secondNoteID := mustNoteID(testContext, "note-batch-second")
+	foreignNoteID := mustNoteID(testContext, "note-batch-foreign")
+	missingNoteID := mustNoteID(testContext, "note-batch-missing")
+
+	ownUpdates := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, secondNoteID, baseUpdateB64, baseSnapshotB64, 0),
+		mustCrdtUpdateEnvelope(testContext, userID, firstNoteID, baseUpdateB64, baseSnapshotB64, 0),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, userID, ownUpdates); err != nil {
+		testContext.Fatalf("apply owner updates failed: %v", err)
+	}
+	foreignUpdates := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, otherUserID, foreignNoteID, baseUpdateB64, baseSnapshotB64, 0),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, otherUserID, foreignUpdates); err != nil {
+		testContext.Fatalf("apply foreign updates failed: %v", err)
+	}
+
+	snapshots, err := service.GetCrdtSnapshotsByNoteIDs(backgroundContext, userID, []NoteID{secondNoteID, foreignNoteID, missingNoteID, firstNoteID})
+	if err != nil {
+		testContext.Fatalf("batch get failed: %v", err)
+	}
+	collected := make([]string, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		collected = append(collected, snapshot.NoteID().String())
+	}
+	expected := []string{firstNoteID.String(), secondNoteID.String()}
+	if fmt.Sprint(collected) != fmt.Sprint(expected) {
+		testContext.Fatalf("unexpected batch snapshots: got %v want %v", collected, expected)
+	}
+
+	tooMany := make([]NoteID, MaxBatchNoteIDs+1)
+	for index := range tooMany {
+		tooMany[index] = firstNoteID
+	}
+	_, err = service.GetCrdtSnapshotsByNoteIDs(backgroundContext, userID, tooMany)
+	if !errors.Is(err, ErrTooManyNoteIDs) {
+		testContext.Fatalf("expected ErrTooManyNoteIDs, got %v", err)
+	}
+	var serviceErr *ServiceError
+	if !errors.As(err, &serviceErr) || serviceErr.Code() != opGetCrdtSnapshots+"."+reasonTooManyNoteIDs {
+		testContext.Fatalf("expected too_many_note_ids service error, got %v", err)
+	}
+}
+
+func TestLoadOwnedSnapshotHidesForeignNotes(testContext *testing.T) {
+	service := mustCrdtService(testContext)
+	backgroundContext := context.Background()
+	ownerID := mustUserID(testContext, "user-crdt-owned-owner")
+	requesterID := mustUserID(testContext, "user-crdt-owned-requester")
+	noteID := mustNoteID(testContext, "note-owned")
+
+	updates := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, ownerID, noteID, baseUpdateB64, baseSnapshotB64, 0),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, ownerID, updates); err != nil {
+		testContext.Fatalf("apply failed: %v", err)
+	}
+
+	snapshot, err := service.loadOwnedSnapshot(backgroundContext, opGetCrdtSnapshots, ownerID, noteID)
+	if err != nil {
+		testContext.Fatalf("expected owner to load the note, got %v", err)
+	}
+	if snapshot.SnapshotB64 != baseSnapshotB64 {
+		testContext.Fatalf("unexpected snapshot payload %q", snapshot.SnapshotB64)
+	}
+
+	_, foreignErr := service.loadOwnedSnapshot(backgroundContext, opGetCrdtSnapshots, requesterID, noteID)
+	_, missingErr := service.loadOwnedSnapshot(backgroundContext, opGetCrdtSnapshots, requesterID, mustNoteID(testContext, "note-owned-missing"))
+	for _, err := range []error{foreignErr, missingErr} {
+		if !errors.Is(err, ErrNoteNotFound) {
+			testContext.Fatalf("expected ErrNoteNotFound, got %v", err)
+		}
+	}
+	if foreignErr.Error() != missingErr.Error() {
+		testContext.Fatalf("expected foreign and missing notes to be indistinguishable, got %q and %q", foreignErr, missingErr)
+	}
+}
+
+func TestGetCrdtSnapshotReturnsLatestSnapshot(testContext *testing.T) {
+	service := mustCrdtService(testContext)
+	backgroundContext := context.Background()
+	userID := mustUserID(testContext, "user-crdt-get-snapshot")
+	noteID := mustNoteID(testContext, "note-get-snapshot")
+
+	updates := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, noteID, baseUpdateB64, baseSnapshotB64, 0),
+		mustCrdtUpdateEnvelope(testContext, userID, noteID, secondUpdateB64, secondSnapshotB64, 2),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, userID, updates); err != nil {
+		testContext.Fatalf("apply failed: %v", err)
+	}
+
+	snapshot, err := service.GetCrdtSnapshot(backgroundContext, userID, noteID)
+	if err != nil {
+		testContext.Fatalf("get snapshot failed: %v", err)
+	}
+	if snapshot.NoteID() != noteID || snapshot.SnapshotB64().String() != secondSnapshotB64 {
+		testContext.Fatalf("unexpected snapshot: %#v", snapshot)
+	}
+	if snapshot.SnapshotUpdateID().Int64() != 2 {
+		testContext.Fatalf("expected snapshot update id 2, got %d", snapshot.SnapshotUpdateID().Int64())
+	}
+
+	if _, err := service.GetCrdtSnapshot(backgroundContext, userID, mustNoteID(testContext, "note-get-snapshot-missing")); !errors.Is(err, ErrNoteNotFound) {
+		testContext.Fatalf("expected ErrNoteNotFound, got %v", err)
+	}
+}
+
+func TestApplyCrdtUpdatesEnforcesMaxPayloadBytes(testContext *testing.T) {
+	const maxPayloadBytes = 4
+	database := mustCrdtService(testContext).db
+	service, err := NewService(ServiceConfig{
+		Database:        database,
+		MaxPayloadBytes: maxPayloadBytes,
+	})
+	if err != nil {
+		testContext.Fatalf("failed to create service: %v", err)
+	}
+	userID := mustUserID(testContext, "user-crdt-payload-limit")
+
+	testCases := []struct {
+		name        string
+		noteID      string
+		updateB64   string
+		snapshotB64 string
+		wantErr     bool
+	}{
+		{name: "update-just-under", noteID: "note-limit-under", updateB64: "AQID", snapshotB64: baseSnapshotB64},
+		{name: "update-at-limit", noteID: "note-limit-at", updateB64: "AQIDBA==", snapshotB64: baseSnapshotB64},
+		{name: "update-just-over", noteID: "note-limit-over", updateB64: "AQIDBAU=", snapshotB64: baseSnapshotB64, wantErr: true},
+		{name: "snapshot-just-over", noteID: "note-limit-snapshot", updateB64: baseUpdateB64, snapshotB64: "AQIDBAU=", wantErr: true},
+	}
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(testContext *testing.T) {
+			noteID := mustNoteID(testContext, testCase.noteID)
+			updates := []CrdtUpdateEnvelope{
+				mustCrdtUpdateEnvelope(testContext, userID, noteID, testCase.updateB64, testCase.snapshotB64, 0),
+			}
+			_, err := service.ApplyCrdtUpdates(context.Background(), userID, updates)
+			if !testCase.wantErr {
+				if err != nil {
+					testContext.Fatalf("expected payload to be accepted, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrPayloadTooLarge) {
+				testContext.Fatalf("expected ErrPayloadTooLarge, got %v", err)
+			}
+			var serviceErr *ServiceError
+			if !errors.As(err, &serviceErr) || serviceErr.Code() != opApplyCrdtUpdates+"."+reasonPayloadTooLarge {
+				testContext.Fatalf("unexpected service error: %v", err)
+			}
+			var storedCount int64
+			if err := database.Model(&CrdtUpdate{}).Where(queryUserNote, userID.String(), noteID.String()).Count(&storedCount).Error; err != nil {
+				testContext.Fatalf("count updates failed: %v", err)
+			}
+			if storedCount != 0 {
+				testContext.Fatalf("expected oversized update to be rejected, found %d stored", storedCount)
+			}
+		})
+	}
+
+	malformed := CrdtUpdateEnvelope{
+		userID:      userID,
+		noteID:      mustNoteID(testContext, "note-limit-malformed"),
+		updateB64:   CrdtUpdateBase64("AQIDB"),
+		snapshotB64: CrdtSnapshotBase64(baseSnapshotB64),
+	}
+	_, err = service.ApplyCrdtUpdates(context.Background(), userID, []CrdtUpdateEnvelope{malformed})
+	var serviceErr *ServiceError
+	if !errors.As(err, &serviceErr) || serviceErr.Code() != opApplyCrdtUpdates+"."+reasonUpdatePayloadInvalid {
+		testContext.Fatalf("expected a malformed payload to be reported as invalid, got %v", err)
+	}
+}
+
+func TestApplyCrdtUpdatesEnforcesMaxNotesPerUser(testContext *testing.T) {
+	const maxNotesPerUser = 2
+	database := mustCrdtService(testContext).db
+	service, err := NewService(ServiceConfig{
+		Database:        database,
+		MaxNotesPerUser: maxNotesPerUser,
+	})
+	if err != nil {
+		testContext.Fatalf("failed to create service: %v", err)
+	}
+	backgroundContext := context.Background()
+	userID := mustUserID(testContext, "user-crdt-note-quota")
+	firstNoteID := mustNoteID(testContext, "note-quota-first")
+	secondNoteID := mustNoteID(testContext, "note-quota-second")
+	overflowNoteID := mustNoteID(testContext, "note-quota-overflow")
+
+	fill := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, firstNoteID, baseUpdateB64, baseSnapshotB64, 0),
+		mustCrdtUpdateEnvelope(testContext, userID, secondNoteID, baseUpdateB64, baseSnapshotB64, 0),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, userID, fill); err != nil {
+		testContext.Fatalf("filling the quota failed: %v", err)
+	}
+
+	overflow := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, overflowNoteID, baseUpdateB64, baseSnapshotB64, 0),
+	}
+	_, err = service.ApplyCrdtUpdates(backgroundContext, userID, overflow)
+	if !errors.Is(err, ErrNoteQuotaExceeded) {
+		testContext.Fatalf("expected ErrNoteQuotaExceeded, got %v", err)
+	}
+	var serviceErr *ServiceError
+	if !errors.As(err, &serviceErr) || serviceErr.Code() != opApplyCrdtUpdates+"."+reasonNoteQuotaExceeded {
+		testContext.Fatalf("unexpected service error: %v", err)
+	}
+
+	existing := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, firstNoteID, secondUpdateB64, baseSnapshotB64, 0),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, userID, existing); err != nil {
+		testContext.Fatalf("expected update to an existing note to pass the quota, got %v", err)
+	}
+
+	otherUserID := mustUserID(testContext, "user-crdt-note-quota-other")
+	otherUpdates := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, otherUserID, overflowNoteID, baseUpdateB64, baseSnapshotB64, 0),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, otherUserID, otherUpdates); err != nil {
+		testContext.Fatalf("expected quota to be tracked per user, got %v", err)
+	}
+
+	var lockCount int64
+	if err := database.Model(&NoteQuotaLock{}).Where(queryUserID, userID.String()).Count(&lockCount).Error; err != nil {
+		testContext.Fatalf("count quota locks failed: %v", err)
+	}
+	if lockCount != 1 {
+		testContext.Fatalf("expected note creation to take the user's quota lock row, found %d", lockCount)
+	}
+	if err := service.DeleteUserData(backgroundContext, userID); err != nil {
+		testContext.Fatalf("delete user data failed: %v", err)
+	}
+	if err := database.Model(&NoteQuotaLock{}).Where(queryUserID, userID.String()).Count(&lockCount).Error; err != nil {
+		testContext.Fatalf("count quota locks failed: %v", err)
+	}
+	if lockCount != 0 {
+		testContext.Fatalf("expected account deletion to remove the quota lock row, found %d", lockCount)
+	}
+
+	if _, err := NewService(ServiceConfig{Database: database, MaxNotesPerUser: -1}); err == nil {
+		testContext.Fatal("expected negative MaxNotesPerUser to be rejected")
+	}
+}
+
+func TestDeleteUserDataWaitsForInFlightWrites(testContext *testing.T) {
+	service := mustCrdtService(testContext)
+	backgroundContext := context.Background()
+	userID := mustUserID(testContext, "user-crdt-delete-locked")
+	noteID := mustNoteID(testContext, "note-delete-locked")
+
+	releaseWrite, err := service.userLocks.acquire(backgroundContext, userID)
+	if err != nil {
+		testContext.Fatalf("failed to take the user's write lock: %v", err)
+	}
+	deleted := make(chan error, 1)
+	go func() {
+		deleted <- service.DeleteUserData(backgroundContext, userID)
+	}()
+	select {
+	case err := <-deleted:
+		testContext.Fatalf("expected the delete to wait for the in-flight write, returned %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	inFlight := CrdtSnapshot{UserID: userID.String(), NoteID: noteID.String(), SnapshotB64: "AQID"}
+	if err := service.db.Create(&inFlight).Error; err != nil {
+		testContext.Fatalf("in-flight write failed: %v", err)
+	}
+	releaseWrite()
+	select {
+	case err := <-deleted:
+		if err != nil {
+			testContext.Fatalf("delete user data failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		testContext.Fatal("expected the delete to proceed once the write released the lock")
+	}
+
+	var snapshotCount int64
+	if err := service.db.Model(&CrdtSnapshot{}).Where(queryUserID, userID.String()).Count(&snapshotCount).Error; err != nil {
+		testContext.Fatalf("count snapshots failed: %v", err)
+	}
+	if snapshotCount != 0 {
+		testContext.Fatalf("expected the delete to remove data written before it, found %d snapshots", snapshotCount)
+	}
+
+	cancelledContext, cancel := context.WithCancel(backgroundContext)
+	cancel()
+	releaseWrite, err = service.userLocks.acquire(backgroundContext, userID)
+	if err != nil {
+		testContext.Fatalf("failed to take the user's write lock: %v", err)
+	}
+	defer releaseWrite()
+	err = service.DeleteUserData(cancelledContext, userID)
+	var serviceErr *ServiceError
+	if !errors.As(err, &serviceErr) || serviceErr.Code() != opDeleteUserData+"."+reasonUserLockFailed {
+		testContext.Fatalf("expected a user lock failure, got %v", err)
+	}
+}
+
+func TestApplyCrdtUpdatesEnforcesMaxUpdatesPerSync(testContext *testing.T) {
+	const maxUpdatesPerSync = 2
+	database := mustCrdtService(testContext).db
+	service, err := NewService(ServiceConfig{
+		Database:          database,
+		MaxUpdatesPerSync: maxUpdatesPerSync,
+	})
+	if err != nil {
+		testContext.Fatalf("failed to create service: %v", err)
+	}
+	backgroundContext := context.Background()
+	userID := mustUserID(testContext, "user-crdt-batch-limit")
+	noteID := mustNoteID(testContext, "note-batch-limit")
+
+	atLimit := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, noteID, baseUpdateB64, baseSnapshotB64, 0),
+		mustCrdtUpdateEnvelope(testContext, userID, noteID, secondUpdateB64, baseSnapshotB64, 0),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, userID, atLimit); err != nil {
+		testContext.Fatalf("expected batch at the limit to be accepted, got %v", err)
+	}
+
+	overLimit := append(atLimit, mustCrdtUpdateEnvelope(testContext, userID, noteID, "AQIDBA==", baseSnapshotB64, 0))
+	_, err = service.ApplyCrdtUpdates(backgroundContext, userID, overLimit)
+	if !errors.Is(err, ErrTooManyUpdates) {
+		testContext.Fatalf("expected ErrTooManyUpdates, got %v", err)
+	}
+	var serviceErr *ServiceError
+	if !errors.As(err, &serviceErr) || serviceErr.Code() != opApplyCrdtUpdates+"."+reasonTooManyUpdates {
+		testContext.Fatalf("unexpected service error: %v", err)
+	}
+	var storedCount int64
+	if err := database.Model(&CrdtUpdate{}).Where(queryUserNote, userID.String(), noteID.String()).Count(&storedCount).Error; err != nil {
+		testContext.Fatalf("count updates failed: %v", err)
+	}
+	if storedCount != int64(len(atLimit)) {
+		testContext.Fatalf("expected rejected batch to store nothing, found %d updates", storedCount)
+	}
+}
+
+func TestApplyCrdtUpdatesRecommendsCompactionPastThreshold(testContext *testing.T) {
+	const compactionThreshold = 2
+	database := mustCrdtService(testContext).db
+	service, err := NewService(ServiceConfig{
+		Database:            database,
+		CompactionThreshold: compactionThreshold,
+	})
+	if err != nil {
+		testContext.Fatalf("failed to create service: %v", err)
+	}
+	backgroundContext := context.Background()
+	userID := mustUserID(testContext, "user-crdt-compaction")
+	noteID := mustNoteID(testContext, "note-compaction")
+	quietNoteID := mustNoteID(testContext, "note-compaction-quiet")
+
+	atThreshold := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, noteID, baseUpdateB64, baseSnapshotB64, 0),
+		mustCrdtUpdateEnvelope(testContext, userID, noteID, secondUpdateB64, baseSnapshotB64, 0),
+	}
+	result, err := service.ApplyCrdtUpdates(backgroundContext, userID, atThreshold)
+	if err != nil {
+		testContext.Fatalf("apply crdt updates failed: %v", err)
+	}
+	for _, outcome := range result.UpdateOutcomes {
+		if outcome.CompactionRecommended() {
+			testContext.Fatalf("expected no recommendation at the threshold, got %+v", outcome)
+		}
+	}
+
+	pastThreshold := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, noteID, "AQIDBA==", baseSnapshotB64, 0),
+		mustCrdtUpdateEnvelope(testContext, userID, quietNoteID, baseUpdateB64, baseSnapshotB64, 0),
+	}
+	result, err = service.ApplyCrdtUpdates(backgroundContext, userID, pastThreshold)
+	if err != nil {
+		testContext.Fatalf("apply crdt updates failed: %v", err)
+	}
+	if !result.UpdateOutcomes[0].CompactionRecommended() {
+		testContext.Fatalf("expected compaction to be recommended past the threshold")
+	}
+	if result.UpdateOutcomes[1].CompactionRecommended() {
+		testContext.Fatalf("expected no recommendation for a note below the threshold")
+	}
+
+	consolidating := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, noteID, "AQIDBQ==", secondSnapshotB64, 1<<40),
+	}
+	result, err = service.ApplyCrdtUpdates(backgroundContext, userID, consolidating)
+	if err != nil {
+		testContext.Fatalf("apply crdt updates failed: %v", err)
+	}
+	if result.UpdateOutcomes[0].CompactionRecommended() {
+		testContext.Fatalf("expected a consolidating snapshot to clear the recommendation")
+	}
+
+	if _, err := NewService(ServiceConfig{Database: database, CompactionThreshold: -1}); err == nil {
+		testContext.Fatal("expected negative CompactionThreshold to be rejected")
+	}
+}
+
+func TestApplyCrdtUpdatesRetriesBusyTransactions(testContext *testing.T) {
+	const busyRetries = 3
+	database := mustCrdtService(testContext).db
+	injectedFailures := 1
+	attempts := 0
+	if err := database.Callback().Create().Before("gorm:create").Register("test:inject_busy", func(statement *gorm.DB) {
+		attempts++
+		if injectedFailures > 0 {
+			injectedFailures--
+			_ = statement.AddError(testBusyError{code: 5})
+		}
+	}); err != nil {
+		testContext.Fatalf("failed to register callback: %v", err)
+	}
+	service, err := NewService(ServiceConfig{Database: database, BusyRetries: busyRetries})
+	if err != nil {
+		testContext.Fatalf("failed to create service: %v", err)
+	}
+	backgroundContext := context.Background()
+	userID := mustUserID(testContext, "user-crdt-busy-retry")
+	noteID := mustNoteID(testContext, "note-busy-retry")
+	updates := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, noteID, baseUpdateB64, baseSnapshotB64, 0),
+	}
+
+	result, err := service.ApplyCrdtUpdates(backgroundContext, userID, updates)
+	if err != nil {
+		testContext.Fatalf("expected busy error to be retried, got %v", err)
+	}
+	if len(result.UpdateOutcomes) != 1 || result.UpdateOutcomes[0].Duplicate() {
+		testContext.Fatalf("expected one accepted outcome after retry, got %#v", result.UpdateOutcomes)
+	}
+	if attempts < 2 {
+		testContext.Fatalf("expected the insert to be attempted again, got %d attempts", attempts)
+	}
+
+	injectedFailures = busyRetries + 1
+	retried := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, noteID, secondUpdateB64, baseSnapshotB64, 0),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, userID, retried); !isBusyError(err) {
+		testContext.Fatalf("expected the busy error once retries are exhausted, got %v", err)
+	}
+
+	attempts = 0
+	injectedFailures = 0
+	if err := database.Callback().Create().Replace("test:inject_busy", func(statement *gorm.DB) {
+		attempts++
+		_ = statement.AddError(errors.New("constraint failed"))
+	}); err != nil {
+		testContext.Fatalf("failed to replace callback: %v", err)
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, userID, retried); err == nil || isBusyError(err) {
+		testContext.Fatalf("expected the non-busy error to be returned, got %v", err)
+	}
+	if attempts != 1 {
+		testContext.Fatalf("expected non-busy errors not to be retried, got %d attempts", attempts)
+	}
+}
+
+func TestApplyCrdtUpdatesWithoutBusyRetriesFailsFirstAttempt(testContext *testing.T) {
+	database := mustCrdtService(testContext).db
+	attempts := 0
+	if err := database.Callback().Create().Before("gorm:create").Register("test:inject_busy_once", func(statement *gorm.DB) {
+		attempts++
+		if attempts == 1 {
+			_ = statement.AddError(testBusyError{code: 5})
+		}
+	}); err != nil {
+		testContext.Fatalf("failed to register callback: %v", err)
+	}
+	service, err := NewService(ServiceConfig{Database: database})
+	if err != nil {
+		testContext.Fatalf("failed to create service: %v", err)
+	}
+	userID := mustUserID(testContext, "user-crdt-busy-disabled")
+	updates := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, mustNoteID(testContext, "note-busy-disabled"), baseUpdateB64, baseSnapshotB64, 0),
+	}
+
+	if _, err := service.ApplyCrdtUpdates(context.Background(), userID, updates); !isBusyError(err) {
+		testContext.Fatalf("expected the busy error to surface without a retry, got %v", err)
+	}
+	if attempts != 1 {
+		testContext.Fatalf("expected a single attempt with retries disabled, got %d", attempts)
+	}
+}
+
+type testBusyError struct {
+	code int
+}
+
+func (err testBusyError) Error() string {
+	return fmt.Sprintf("database is locked (%d)", err.code)
+}
+
+func (err testBusyError) Code() int {
+	return err.code
+}
+
+func TestApplyCrdtUpdatesReportsToObserver(testContext *testing.T) {
+	observer := &recordingObserver{}
+	service, err := NewService(ServiceConfig{
+		Database:          mustCrdtService(testContext).db,
+		MaxUpdatesPerSync: 2,
+		Observer:          observer,
+	})
+	if err != nil {
+		testContext.Fatalf("failed to create service: %v", err)
+	}
+	backgroundContext := context.Background()
+	userID := mustUserID(testContext, "user-crdt-observer")
+	noteID := mustNoteID(testContext, "note-observer")
+
+	first := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, noteID, baseUpdateB64, baseSnapshotB64, 0),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, userID, first); err != nil {
+		testContext.Fatalf("first apply failed: %v", err)
+	}
+	mixed := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, noteID, baseUpdateB64, baseSnapshotB64, 0),
+		mustCrdtUpdateEnvelope(testContext, userID, noteID, secondUpdateB64, baseSnapshotB64, 0),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, userID, mixed); err != nil {
+		testContext.Fatalf("mixed apply failed: %v", err)
+	}
+	overLimit := append(mixed, mustCrdtUpdateEnvelope(testContext, userID, noteID, "AQIDBA==", baseSnapshotB64, 0))
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, userID, overLimit); !errors.Is(err, ErrTooManyUpdates) {
+		testContext.Fatalf("expected ErrTooManyUpdates, got %v", err)
+	}
+
+	if expected := []int{1, 2, 3}; !slices.Equal(observer.batchSizes, expected) {
+		testContext.Fatalf("expected batch sizes %v, got %v", expected, observer.batchSizes)
+	}
+	if expected := []bool{false, true, false}; !slices.Equal(observer.duplicates, expected) {
+		testContext.Fatalf("expected duplicate flags %v, got %v", expected, observer.duplicates)
+	}
+}
+
+type recordingObserver struct {
+	batchSizes []int
+	duplicates []bool
+}
+
+func (observer *recordingObserver) SyncBatch(size int) {
+	observer.batchSizes = append(observer.batchSizes, size)
+}
+
+func (observer *recordingObserver) UpdateApplied(duplicate bool) {
+	observer.duplicates = append(observer.duplicates, duplicate)
+}
+
+func TestApplyCrdtUpdatesSerializesConcurrentSyncsPerUser(testContext *testing.T) {
+	const (
+		workersPerUser   = 4
+		batchesPerWorker = 10
+	)
+	database, err := gorm.Open(sqlite.Open(filepath.Join(testContext.TempDir(), "concurrent-sync.db")), &gorm.Config{})
+	if err != nil {
+		testContext.Fatalf("failed to open database: %v", err)
+	}
+	if err := database.AutoMigrate(&CrdtUpdate{}, &CrdtSnapshot{}, &NoteTag{}, &NoteQuotaLock{}); err != nil {
+		testContext.Fatalf("failed to migrate schema: %v", err)
+	}
+	sqlDatabase, err := database.DB()
+	if err != nil {
+		testContext.Fatalf("failed to access sql database: %v", err)
+	}
+	sqlDatabase.SetMaxOpenConns(1)
+	service, err := NewService(ServiceConfig{Database: database})
+	if err != nil {
+		testContext.Fatalf("failed to create service: %v", err)
+	}
+
+	userIDs := []UserID{mustUserID(testContext, "user-concurrent-a"), mustUserID(testContext, "user-concurrent-b")}
+	noteID := mustNoteID(testContext, "note-concurrent")
+	errorsByWorker := make(chan error, len(userIDs)*workersPerUser)
+	var workers sync.WaitGroup
+	for userIndex, userID := range userIDs {
+		for worker := 0; worker < workersPerUser; worker++ {
+			envelopes := make([]CrdtUpdateEnvelope, 0, batchesPerWorker)
+			for batch := 0; batch < batchesPerWorker; batch++ {
+				payload := base64.StdEncoding.EncodeToString([]byte{byte(userIndex), byte(worker), byte(batch)})
+				envelopes = append(envelopes, mustCrdtUpdateEnvelope(testContext, userID, noteID, payload, payload, 0))
+			}
+			workers.Add(1)
+			go func(userID UserID, envelopes []CrdtUpdateEnvelope) {
+				defer workers.Done()
+				for _, envelope := range envelopes {
+					if _, applyErr := service.ApplyCrdtUpdates(context.Background(), userID, []CrdtUpdateEnvelope{envelope}); applyErr != nil {
+						errorsByWorker <- applyErr
+						return
+					}
+				}
+			}(userID, envelopes)
+		}
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(30 * time.Second):
+		testContext.Fatal("concurrent syncs did not finish; possible deadlock")
+	}
+	close(errorsByWorker)
+	for err := range errorsByWorker {
+		testContext.Fatalf("concurrent sync failed: %v", err)
+	}
+
+	expectedUpdates := workersPerUser * batchesPerWorker
+	for _, userID := range userIDs {
+		updates, err := service.ListCrdtUpdates(context.Background(), userID, []CrdtCursor{mustCrdtCursor(testContext, noteID, 0)})
+		if err != nil {
+			testContext.Fatalf("list updates failed: %v", err)
+		}
+		if len(updates) != expectedUpdates {
+			testContext.Fatalf("expected %d updates for %s, got %d", expectedUpdates, userID, len(updates))
+		}
+		for index := 1; index < len(updates); index++ {
+			if updates[index].UpdateID() <= updates[index-1].UpdateID() {
+				testContext.Fatalf("expected increasing update ids for %s", userID)
+			}
+		}
+	}
+	if size := service.userLocks.size(); size != 0 {
+		testContext.Fatalf("expected idle user locks to be evicted, %d remain", size)
+	}
+}
+
+func TestApplyCrdtUpdatesEnforcesSyncTimeout(testContext *testing.T) {
+	// A file database keeps the schema when the timed out connection is discarded,
+	// which would drop a shared in-memory database.
+	database, err := gorm.Open(sqlite.Open(filepath.Join(testContext.TempDir(), "sync-timeout.db")), &gorm.Config{})
+	if err != nil {
+		testContext.Fatalf("failed to open database: %v", err)
+	}
+	if err := database.AutoMigrate(&CrdtUpdate{}, &CrdtSnapshot{}, &NoteTag{}, &NoteQuotaLock{}); err != nil {
+		testContext.Fatalf("failed to migrate schema: %v", err)
+	}
+	service, err := NewService(ServiceConfig{
+		Database:    database,
+		SyncTimeout: 10 * time.Millisecond,
+		Clock: func() time.Time {
+			time.Sleep(50 * time.Millisecond)
+			return time.Unix(1700000000, 0).UTC()
+		},
+	})
+	if err != nil {
+		testContext.Fatalf("failed to create service: %v", err)
+	}
+	userID := mustUserID(testContext, "user-crdt-sync-timeout")
+	noteID := mustNoteID(testContext, "note-sync-timeout")
+
+	updates := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, noteID, baseUpdateB64, baseSnapshotB64, 0),
+	}
+	_, err = service.ApplyCrdtUpdates(context.Background(), userID, updates)
+	if !errors.Is(err, ErrSyncTimeout) {
+		testContext.Fatalf("expected ErrSyncTimeout, got %v", err)
+	}
+	var serviceErr *ServiceError
+	if !errors.As(err, &serviceErr) || serviceErr.Code() != opApplyCrdtUpdates+"."+reasonSyncTimeout {
+		testContext.Fatalf("unexpected service error: %v", err)
+	}
+	var storedCount int64
+	if err := database.Model(&CrdtUpdate{}).Where(queryUserNote, userID.String(), noteID.String()).Count(&storedCount).Error; err != nil {
+		testContext.Fatalf("count updates failed: %v", err)
+	}
+	if storedCount != 0 {
+		testContext.Fatalf("expected timed out sync to be rolled back, found %d updates", storedCount)
+	}
+
+	if _, err := NewService(ServiceConfig{Database: database, SyncTimeout: -time.Second}); err == nil {
+		testContext.Fatal("expected negative SyncTimeout to be rejected")
+	}
+}
+
+func TestCompactCrdtUpdatesRemovesOnlySnapshotCoveredUpdates(testContext *testing.T) {
+	service := mustCrdtService(testContext)
+	userID := mustUserID(testContext, "user-crdt-compact")
+	backgroundContext := context.Background()
+	compactedNoteID := mustNoteID(testContext, "note-compact-covered")
+	uncoveredNoteID := mustNoteID(testContext, "note-compact-uncovered")
+
+	applyOne := func(noteID NoteID, updateB64 string, snapshotUpdateID int64) int64 {
+		testContext.Helper()
+		result, err := service.ApplyCrdtUpdates(backgroundContext, userID, []CrdtUpdateEnvelope{
+			mustCrdtUpdateEnvelope(testContext, userID, noteID, updateB64, baseSnapshotB64, snapshotUpdateID),
+		})
+		if err != nil {
+			testContext.Fatalf("apply crdt update failed: %v", err)
+		}
+		return result.UpdateOutcomes[0].UpdateID().Int64()
+	}
+
+	applyOne(compactedNoteID, baseUpdateB64, 0)
+	coveredUpdateID := applyOne(compactedNoteID, secondUpdateB64, 1<<40)
+	tailUpdateID := applyOne(compactedNoteID, staleSnapshotB64, 0)
+	uncoveredUpdateID := applyOne(uncoveredNoteID, baseUpdateB64, 0)
+
+	removed, err := service.CompactCrdtUpdates(backgroundContext, userID)
+	if err != nil {
+		testContext.Fatalf("compact crdt updates failed: %v", err)
+	}
+	if removed != 2 {
+		testContext.Fatalf("expected 2 compacted updates, got %d", removed)
+	}
+
+	snapshots, err := service.ListCrdtSnapshots(backgroundContext, userID)
+	if err != nil {
+		testContext.Fatalf("list snapshots failed: %v", err)
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.NoteID() == compactedNoteID && snapshot.SnapshotUpdateID().Int64() != coveredUpdateID {
+			testContext.Fatalf("expected snapshot to cover update %d, got %d", coveredUpdateID, snapshot.SnapshotUpdateID().Int64())
+		}
+	}
+
+	cursors := []CrdtCursor{
+		mustCrdtCursor(testContext, compactedNoteID, 0),
+		mustCrdtCursor(testContext, uncoveredNoteID, 0),
+	}
+	remaining, err := service.ListCrdtUpdates(backgroundContext, userID, cursors)
+	if err != nil {
+		testContext.Fatalf("list crdt updates failed: %v", err)
+	}
+	remainingIDs := make([]int64, 0, len(remaining))
+	for _, record := range remaining {
+		remainingIDs = append(remainingIDs, record.UpdateID().Int64())
+	}
+	sort.Slice(remainingIDs, func(left, right int) bool { return remainingIDs[left] < remainingIDs[right] })
+	expectedIDs := []int64{tailUpdateID, uncoveredUpdateID}
+	if fmt.Sprint(remainingIDs) != fmt.Sprint(expectedIDs) {
+		testContext.Fatalf("unexpected remaining updates: got %v want %v", remainingIDs, expectedIDs)
+	}
+
+	removedAgain, err := service.CompactCrdtUpdates(backgroundContext, userID)
+	if err != nil {
+		testContext.Fatalf("second compaction failed: %v", err)
+	}
+	if removedAgain != 0 {
+		testContext.Fatalf("expected second compaction to be a no-op, removed %d", removedAgain)
+	}
+}
+
+func TestNewCrdtSnapshotListOptionsValidation(testContext *testing.T) {
+	testCases := []struct {
+		name    string
+		limit   int
+		cursor  string
+		wantErr error
+	}{
+		{name: "unbounded", limit: 0},
+		{name: "negative-limit", limit: -1, wantErr: ErrInvalidListLimit},
+		{name: "limit-too-large", limit: MaxListLimit + 1, wantErr: ErrInvalidListLimit},
+		{name: "malformed-cursor", limit: 10, cursor: "%%%", wantErr: ErrInvalidListCursor},
+	}
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(testContext *testing.T) {
+			_, err := NewCrdtSnapshotListOptions(testCase.limit, testCase.cursor)
+			if testCase.wantErr == nil && err != nil {
+				testContext.Fatalf("unexpected error: %v", err)
+			}
+			if testCase.wantErr != nil && !errors.Is(err, testCase.wantErr) {
+				testContext.Fatalf("expected %v, got %v", testCase.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNewCrdtPayloadBase64NormalizesURLSafeInput(testContext *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{name: "standard", input: "+/8=", expected: "+/8="},
+		{name: "url-safe-padded", input: "-_8=", expected: "+/8="},
+		{name: "url-safe-unpadded", input: "-_8", expected: "+/8="},
+		{name: "invalid", input: "!!not-base64!!", wantErr: true},
+	}
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(testContext *testing.T) {
+			update, updateErr := NewCrdtUpdateBase64(testCase.input)
+			snapshot, snapshotErr := NewCrdtSnapshotBase64(testCase.input)
+			if testCase.wantErr {
+				if !errors.Is(updateErr, ErrInvalidCrdtUpdate) || !errors.Is(snapshotErr, ErrInvalidCrdtSnapshot) {
+					testContext.Fatalf("expected invalid payload errors, got %v and %v", updateErr, snapshotErr)
+				}
+				return
+			}
+			if updateErr != nil || snapshotErr != nil {
+				testContext.Fatalf("unexpected errors: %v and %v", updateErr, snapshotErr)
+			}
+			if update.String() != testCase.expected || snapshot.String() != testCase.expected {
+				testContext.Fatalf("expected %q, got %q and %q", testCase.expected, update.String(), snapshot.String())
+			}
+			if _, err := hashCrdtPayload(update.String()); err != nil {
+				testContext.Fatalf("normalized payload failed to hash: %v", err)
+			}
+		})
+	}
+}
+
 func mustCrdtService(testContext *testing.T) *Service {
 	testContext.Helper()
 	database, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
 	if err != nil {
 		testContext.Fatalf("failed to open database: %v", err)
 	}
-	if err := database.AutoMigrate(&CrdtUpdate{}, &CrdtSnapshot{}); err != nil {
+	if err := database.AutoMigrate(&CrdtUpdate{}, &CrdtSnapshot{}, &NoteTag{}, &NoteQuotaLock{}); err != nil {
 		testContext.Fatalf("failed to migrate schema: %v", err)
 	}
 	service, err := NewService(ServiceConfig{
@@ -331,3 +1384,91 @@ func mustCrdtCursor(testContext *testing.T, noteID NoteID, lastUpdateIDValue int
 	}
 	return cursor
 }
+
+func TestExportUserDataIncludesOnlyRequesterRecords(testContext *testing.T) {
+	service := mustCrdtService(testContext)
+	backgroundContext := context.Background()
+	requesterID := mustUserID(testContext, "user-export-requester")
+	otherID := mustUserID(testContext, "user-export-other")
+	requesterNoteID := mustNoteID(testContext, "note-export-requester")
+	otherNoteID := mustNoteID(testContext, "note-export-other")
+
+	requesterUpdates := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, requesterID, requesterNoteID, baseUpdateB64, baseSnapshotB64, 0),
+		mustCrdtUpdateEnvelope(testContext, requesterID, requesterNoteID, secondUpdateB64, baseSnapshotB64, 0),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, requesterID, requesterUpdates); err != nil {
+		testContext.Fatalf("apply requester updates failed: %v", err)
+	}
+	otherUpdates := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, otherID, otherNoteID, baseUpdateB64, baseSnapshotB64, 0),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, otherID, otherUpdates); err != nil {
+		testContext.Fatalf("apply other updates failed: %v", err)
+	}
+
+	export, err := service.ExportUserData(backgroundContext, requesterID)
+	if err != nil {
+		testContext.Fatalf("export user data failed: %v", err)
+	}
+	if export.UserID != requesterID || export.ExportedAt.Unix() != 1700000000 {
+		testContext.Fatalf("unexpected export header: %v %v", export.UserID, export.ExportedAt)
+	}
+	if len(export.Snapshots) != 1 || export.Snapshots[0].NoteID() != requesterNoteID {
+		testContext.Fatalf("unexpected exported snapshots: %#v", export.Snapshots)
+	}
+	if len(export.Updates) != len(requesterUpdates) {
+		testContext.Fatalf("expected %d exported updates, got %d", len(requesterUpdates), len(export.Updates))
+	}
+	for _, update := range export.Updates {
+		if update.NoteID() != requesterNoteID {
+			testContext.Fatalf("export leaked update from another user or note: %#v", update)
+		}
+	}
+}
+
+func TestUserStatsCountsOnlyRequesterRecords(testContext *testing.T) {
+	service := mustCrdtService(testContext)
+	backgroundContext := context.Background()
+	userID := mustUserID(testContext, "user-stats-owner")
+	otherUserID := mustUserID(testContext, "user-stats-other")
+	firstNoteID := mustNoteID(testContext, "note-stats-first")
+	secondNoteID := mustNoteID(testContext, "note-stats-second")
+
+	ownUpdates := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, firstNoteID, baseUpdateB64, baseSnapshotB64, 0),
+		mustCrdtUpdateEnvelope(testContext, userID, firstNoteID, secondUpdateB64, staleSnapshotB64, 0),
+		mustCrdtUpdateEnvelope(testContext, userID, secondNoteID, baseUpdateB64, "AQIDBA==", 0),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, userID, ownUpdates); err != nil {
+		testContext.Fatalf("apply owner updates failed: %v", err)
+	}
+	otherUpdates := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, otherUserID, firstNoteID, baseUpdateB64, baseSnapshotB64, 0),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, otherUserID, otherUpdates); err != nil {
+		testContext.Fatalf("apply other updates failed: %v", err)
+	}
+
+	stats, err := service.UserStats(backgroundContext, userID)
+	if err != nil {
+		testContext.Fatalf("user stats failed: %v", err)
+	}
+	expected := UserStats{
+		NoteCount:     2,
+		UpdateCount:   3,
+		SnapshotBytes: int64(len(staleSnapshotB64) + len("AQIDBA==")),
+		UpdateBytes:   int64(len(baseUpdateB64) + len(secondUpdateB64) + len(baseUpdateB64)),
+	}
+	if stats != expected {
+		testContext.Fatalf("unexpected stats: got %+v want %+v", stats, expected)
+	}
+
+	empty, err := service.UserStats(backgroundContext, mustUserID(testContext, "user-stats-empty"))
+	if err != nil {
+		testContext.Fatalf("empty user stats failed: %v", err)
+	}
+	if empty != (UserStats{}) {
+		testContext.Fatalf("expected zero stats for empty user, got %+v", empty)
+	}
+}
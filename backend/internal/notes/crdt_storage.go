@@ -3,11 +3,11 @@ package notes
 // CrdtUpdate stores an append-only CRDT update payload.
 type CrdtUpdate struct {
 	UpdateID         int64  `gorm:"column:update_id;primaryKey;autoIncrement"`
-	UserID           string `gorm:"column:user_id;size:190;not null;index:idx_crdt_updates_user_note,priority:1;uniqueIndex:idx_crdt_update_dedupe,priority:1"`
+	UserID           string `gorm:"column:user_id;size:190;not null;index:idx_crdt_updates_user_note,priority:1;uniqueIndex:idx_crdt_update_dedupe,priority:1;index:idx_crdt_updates_user_applied,priority:1"`
 	NoteID           string `gorm:"column:note_id;size:190;not null;index:idx_crdt_updates_user_note,priority:2;uniqueIndex:idx_crdt_update_dedupe,priority:2"`
 	UpdateB64        string `gorm:"column:update_b64;type:text;not null"`
 	UpdateHash       string `gorm:"column:update_hash;size:64;not null;uniqueIndex:idx_crdt_update_dedupe,priority:3"`
-	AppliedAtSeconds int64  `gorm:"column:applied_at_s;not null"`
+	AppliedAtSeconds int64  `gorm:"column:applied_at_s;not null;index:idx_crdt_updates_user_applied,priority:2"`
 }
 
 // TableName provides the explicit table binding for GORM.
@@ -15,15 +15,42 @@ func (CrdtUpdate) TableName() string {
 	return "note_crdt_updates"
 }
 
-// CrdtSnapshot stores a compacted CRDT snapshot per note.
+// CrdtSnapshot stores a compacted CRDT snapshot per note. ChangedAtSeconds is the applied_at_s of the
+// note's newest accepted update; it outlives compaction, which deletes the update rows themselves.
 type CrdtSnapshot struct {
-	UserID           string `gorm:"column:user_id;primaryKey;size:190;not null"`
+	UserID           string `gorm:"column:user_id;primaryKey;size:190;not null;index:idx_crdt_snapshots_user_changed,priority:1"`
 	NoteID           string `gorm:"column:note_id;primaryKey;size:190;not null"`
 	SnapshotB64      string `gorm:"column:snapshot_b64;type:text;not null"`
 	SnapshotUpdateID int64  `gorm:"column:snapshot_update_id;not null;default:0"`
+	ChangedAtSeconds int64  `gorm:"column:changed_at_s;not null;default:0;index:idx_crdt_snapshots_user_changed,priority:2"`
 }
 
 // TableName provides the explicit table binding for GORM.
 func (CrdtSnapshot) TableName() string {
 	return "note_crdt_snapshots"
 }
+
+// NoteTag stores one normalized label attached to a user's note.
+type NoteTag struct {
+	UserID string `gorm:"column:user_id;primaryKey;size:190;not null;index:idx_note_tags_user_tag,priority:1"`
+	NoteID string `gorm:"column:note_id;primaryKey;size:190;not null"`
+	Tag    string `gorm:"column:tag;primaryKey;size:64;not null;index:idx_note_tags_user_tag,priority:2"`
+}
+
+// TableName provides the explicit table binding for GORM.
+func (NoteTag) TableName() string {
+	return "note_tags"
+}
+
+// NoteQuotaLock is a per-user row that a transaction creating a note upserts before counting the user's
+// notes. The upsert takes the row's write lock, so quota checks in other processes sharing the database
+// wait for the transaction to commit instead of counting the same stale total.
+type NoteQuotaLock struct {
+	UserID          string `gorm:"column:user_id;primaryKey;size:190;not null"`
+	LockedAtSeconds int64  `gorm:"column:locked_at_s;not null;default:0"`
+}
+
+// TableName provides the explicit table binding for GORM.
+func (NoteQuotaLock) TableName() string {
+	return "note_quota_locks"
+}
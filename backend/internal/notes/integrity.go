@@ -0,0 +1,85 @@
+package notes
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+const (
+	opVerifyCrdtIntegrity = "notes.verify_crdt_integrity"
+
+	// IntegrityIssueSnapshotAhead marks a snapshot whose snapshot_update_id exceeds every update stored for its note.
+	IntegrityIssueSnapshotAhead = "snapshot_ahead_of_updates"
+	// IntegrityIssueSnapshotUncovered marks a snapshot with zero coverage and no stored update to replay.
+	IntegrityIssueSnapshotUncovered = "snapshot_without_updates"
+)
+
+// IntegrityIssue describes one note whose stored snapshot and updates violate the coverage invariant.
+// MaxUpdateID is zero when the note has no stored updates.
+type IntegrityIssue struct {
+	NoteID           NoteID
+	Kind             string
+	SnapshotUpdateID int64
+	MaxUpdateID      int64
+}
+
+type noteUpdateBounds struct {
+	NoteID      string
+	MaxUpdateID int64
+	UpdateCount int64
+}
+
+// VerifyCrdtIntegrity reports the user's notes whose snapshot coverage disagrees with the stored updates,
+// ordered by note identifier. Compaction deletes covered updates, so a note whose covering snapshot
+// outlived all of its updates is consistent and never reported.
+func (service *Service) VerifyCrdtIntegrity(ctx context.Context, userID UserID) ([]IntegrityIssue, error) {
+	if service.db == nil {
+		service.logError(opVerifyCrdtIntegrity, reasonMissingDatabase, errMissingDatabase)
+		return nil, newServiceError(opVerifyCrdtIntegrity, reasonMissingDatabase, errMissingDatabase)
+	}
+
+	var snapshots []CrdtSnapshot
+	if err := service.db.WithContext(ctx).
+		Select(fieldNoteID, columnSnapshotUpdateID).
+		Where(queryUserID, userID.String()).
+		Order(orderNoteIDAsc).
+		Find(&snapshots).Error; err != nil {
+		service.logError(opVerifyCrdtIntegrity, reasonQueryFailed, err, zap.String(fieldUserID, userID.String()))
+		return nil, newServiceError(opVerifyCrdtIntegrity, reasonQueryFailed, err)
+	}
+
+	var bounds []noteUpdateBounds
+	if err := service.db.WithContext(ctx).Model(&CrdtUpdate{}).
+		Select("note_id, MAX(update_id) AS max_update_id, COUNT(*) AS update_count").
+		Where(queryUserID, userID.String()).
+		Group(fieldNoteID).
+		Scan(&bounds).Error; err != nil {
+		service.logError(opVerifyCrdtIntegrity, reasonQueryFailed, err, zap.String(fieldUserID, userID.String()))
+		return nil, newServiceError(opVerifyCrdtIntegrity, reasonQueryFailed, err)
+	}
+	boundsByNoteID := make(map[string]noteUpdateBounds, len(bounds))
+	for _, bound := range bounds {
+		boundsByNoteID[bound.NoteID] = bound
+	}
+
+	var issues []IntegrityIssue
+	for _, snapshot := range snapshots {
+		bound := boundsByNoteID[snapshot.NoteID]
+		issue := IntegrityIssue{
+			NoteID:           NoteID(snapshot.NoteID),
+			SnapshotUpdateID: snapshot.SnapshotUpdateID,
+			MaxUpdateID:      bound.MaxUpdateID,
+		}
+		switch {
+		case bound.UpdateCount > 0 && snapshot.SnapshotUpdateID > bound.MaxUpdateID:
+			issue.Kind = IntegrityIssueSnapshotAhead
+		case bound.UpdateCount == 0 && snapshot.SnapshotUpdateID == 0:
+			issue.Kind = IntegrityIssueSnapshotUncovered
+		default:
+			continue
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
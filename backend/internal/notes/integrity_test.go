@@ -0,0 +1,56 @@
+package notes
+
+import (
+	"context"
+	"testing"
+)
+
+func TestVerifyCrdtIntegrityReportsInconsistentSnapshots(testContext *testing.T) {
+	service := mustCrdtService(testContext)
+	backgroundContext := context.Background()
+	userID := mustUserID(testContext, "user-integrity")
+	healthyNoteID := mustNoteID(testContext, "note-integrity-healthy")
+	aheadNoteID := mustNoteID(testContext, "note-integrity-ahead")
+	compactedNoteID := mustNoteID(testContext, "note-integrity-compacted")
+	orphanNoteID := mustNoteID(testContext, "note-integrity-orphan")
+
+	updates := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, healthyNoteID, baseUpdateB64, baseSnapshotB64, 0),
+		mustCrdtUpdateEnvelope(testContext, userID, aheadNoteID, baseUpdateB64, baseSnapshotB64, 0),
+		mustCrdtUpdateEnvelope(testContext, userID, compactedNoteID, baseUpdateB64, baseSnapshotB64, 1<<40),
+	}
+	result, err := service.ApplyCrdtUpdates(backgroundContext, userID, updates)
+	if err != nil {
+		testContext.Fatalf("apply failed: %v", err)
+	}
+	if _, err := service.CompactCrdtUpdates(backgroundContext, userID); err != nil {
+		testContext.Fatalf("compact failed: %v", err)
+	}
+
+	aheadUpdateID := result.UpdateOutcomes[1].UpdateID().Int64()
+	if err := service.db.Model(&CrdtSnapshot{}).
+		Where(queryUserNote, userID.String(), aheadNoteID.String()).
+		Update(columnSnapshotUpdateID, aheadUpdateID+100).Error; err != nil {
+		testContext.Fatalf("failed to corrupt snapshot: %v", err)
+	}
+	if err := service.db.Create(&CrdtSnapshot{UserID: userID.String(), NoteID: orphanNoteID.String(), SnapshotB64: baseSnapshotB64}).Error; err != nil {
+		testContext.Fatalf("failed to seed orphan snapshot: %v", err)
+	}
+
+	issues, err := service.VerifyCrdtIntegrity(backgroundContext, userID)
+	if err != nil {
+		testContext.Fatalf("verify failed: %v", err)
+	}
+	expected := []IntegrityIssue{
+		{NoteID: aheadNoteID, Kind: IntegrityIssueSnapshotAhead, SnapshotUpdateID: aheadUpdateID + 100, MaxUpdateID: aheadUpdateID},
+		{NoteID: orphanNoteID, Kind: IntegrityIssueSnapshotUncovered},
+	}
+	if len(issues) != len(expected) {
+		testContext.Fatalf("expected %d issues, got %+v", len(expected), issues)
+	}
+	for index, issue := range issues {
+		if issue != expected[index] {
+			testContext.Fatalf("unexpected issue %d: got %+v want %+v", index, issue, expected[index])
+		}
+	}
+}
@@ -6,13 +6,23 @@ import (
 	"strings"
 )
 
-const maxIdentifierLength = 190
+const (
+	maxIdentifierLength = 190
+	maxTagLength        = 64
+)
+
+// MaxTagsPerNote bounds the number of distinct tags a note may carry.
+const MaxTagsPerNote = 32
 
 var (
 	// ErrInvalidNoteID indicates that a note identifier is empty or exceeds storage bounds.
 	ErrInvalidNoteID = errors.New("notes: invalid note id")
 	// ErrInvalidUserID indicates that a user identifier is empty or exceeds storage bounds.
 	ErrInvalidUserID = errors.New("notes: invalid user id")
+	// ErrInvalidTag indicates that a note tag is empty or exceeds storage bounds.
+	ErrInvalidTag = errors.New("notes: invalid tag")
+	// ErrTooManyTags indicates that a note was given more than MaxTagsPerNote distinct tags.
+	ErrTooManyTags = errors.New("notes: too many tags")
 )
 
 // NoteID represents a validated note identifier.
@@ -54,3 +64,23 @@ func NewUserID(rawInput string) (UserID, error) {
 func (id UserID) String() string {
 	return string(id)
 }
+
+// Tag represents a normalized note label: trimmed and lowercased so tags compare case-insensitively.
+type Tag string
+
+// NewTag validates raw input and returns a normalized Tag.
+func NewTag(rawInput string) (Tag, error) {
+	normalized := strings.ToLower(strings.TrimSpace(rawInput))
+	if normalized == "" {
+		return "", fmt.Errorf("%w: empty", ErrInvalidTag)
+	}
+	if len(normalized) > maxTagLength {
+		return "", fmt.Errorf("%w: exceeds %d characters", ErrInvalidTag, maxTagLength)
+	}
+	return Tag(normalized), nil
+}
+
+// String returns the underlying tag.
+func (tag Tag) String() string {
+	return string(tag)
+}
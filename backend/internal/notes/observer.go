@@ -0,0 +1,22 @@
+package notes
+
+// Observer receives CRDT sync events so callers can record metrics without this package importing a metrics library.
+type Observer interface {
+	// SyncBatch reports the number of updates submitted to one ApplyCrdtUpdates call, including calls that fail.
+	SyncBatch(size int)
+	// UpdateApplied reports one committed update; duplicate is true when the payload was already stored.
+	UpdateApplied(duplicate bool)
+}
+
+type noOpObserver struct{}
+
+func (noOpObserver) SyncBatch(int) {}
+
+func (noOpObserver) UpdateApplied(bool) {}
+
+func (s *Service) observerOrDefault() Observer {
+	if s == nil || s.observer == nil {
+		return noOpObserver{}
+	}
+	return s.observer
+}
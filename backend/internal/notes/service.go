@@ -5,15 +5,32 @@ import (
 	"fmt"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 var (
-	errMissingDatabase = errors.New("database handle is required")
-	noOpLogger         = zap.NewNop()
+	errMissingDatabase   = errors.New("database handle is required")
+	errInvalidMaxBytes   = errors.New("max payload bytes must not be negative")
+	errInvalidMaxNotes   = errors.New("max notes per user must not be negative")
+	errInvalidMaxBatch   = errors.New("max updates per sync must not be negative")
+	errInvalidTimeout    = errors.New("sync timeout must not be negative")
+	errInvalidRetries    = errors.New("busy retries must not be negative")
+	errInvalidCompaction = errors.New("compaction threshold must not be negative")
+	noOpLogger           = zap.NewNop()
 )
 
+// DefaultMaxPayloadBytes bounds the decoded size of a single CRDT update or snapshot when no limit is configured.
+const DefaultMaxPayloadBytes = 256 * 1024
+
+// DefaultMaxUpdatesPerSync bounds how many CRDT updates a single ApplyCrdtUpdates call accepts when no limit is configured.
+const DefaultMaxUpdatesPerSync = 1000
+
+// DefaultCompactionThreshold is how many updates a note may retain beyond its snapshot before sync outcomes
+// recommend compaction when no threshold is configured.
+const DefaultCompactionThreshold = 500
+
 type ServiceError struct {
 	code string
 	err  error
@@ -34,7 +51,15 @@ func (e *ServiceError) Code() string {
 	return e.code
 }
 
-const opServiceNew = "notes.service.new"
+const (
+	opServiceNew                     = "notes.service.new"
+	reasonInvalidMaxPayloadBytes     = "invalid_max_payload_bytes"
+	reasonInvalidMaxNotesPerUser     = "invalid_max_notes_per_user"
+	reasonInvalidMaxUpdatesPerSync   = "invalid_max_updates_per_sync"
+	reasonInvalidSyncTimeout         = "invalid_sync_timeout"
+	reasonInvalidBusyRetries         = "invalid_busy_retries"
+	reasonInvalidCompactionThreshold = "invalid_compaction_threshold"
+)
 
 func newServiceError(operation, reason string, cause error) error {
 	code := fmt.Sprintf("%s.%s", operation, reason)
@@ -42,20 +67,43 @@ func newServiceError(operation, reason string, cause error) error {
 }
 
 type ServiceConfig struct {
-	Database *gorm.DB
-	Clock    func() time.Time
-	Logger   *zap.Logger
+	Database        *gorm.DB
+	Clock           func() time.Time
+	Logger          *zap.Logger
+	MaxPayloadBytes int
+	// MaxNotesPerUser caps how many distinct notes a user may create; zero disables the quota.
+	MaxNotesPerUser int
+	// MaxUpdatesPerSync caps the updates applied in one call; zero selects DefaultMaxUpdatesPerSync.
+	MaxUpdatesPerSync int
+	// SyncTimeout bounds the write transaction of one ApplyCrdtUpdates call; zero leaves it unbounded.
+	SyncTimeout time.Duration
+	// BusyRetries re-runs a sync transaction that failed with SQLite busy or locked; zero disables retries.
+	BusyRetries int
+	// CompactionThreshold flags outcomes for notes retaining more updates beyond their snapshot; zero selects DefaultCompactionThreshold.
+	CompactionThreshold int
+	TracerProvider      trace.TracerProvider
+	// Observer receives sync events for metrics; nil disables observation.
+	Observer Observer
 }
 
 type Service struct {
-	db     *gorm.DB
-	clock  func() time.Time
-	logger *zap.Logger
+	db                  *gorm.DB
+	clock               func() time.Time
+	logger              *zap.Logger
+	maxPayloadBytes     int
+	maxNotesPerUser     int
+	maxUpdatesPerSync   int
+	syncTimeout         time.Duration
+	busyRetries         int
+	compactionThreshold int
+	tracer              trace.Tracer
+	observer            Observer
+	userLocks           *userLockTable
 }
 
 func NewService(cfg ServiceConfig) (*Service, error) {
 	if cfg.Database == nil {
-		return nil, newServiceError(opServiceNew, "missing_database", errMissingDatabase)
+		return nil, newServiceError(opServiceNew, reasonMissingDatabase, errMissingDatabase)
 	}
 
 	clock := cfg.Clock
@@ -68,10 +116,60 @@ func NewService(cfg ServiceConfig) (*Service, error) {
 		logger = noOpLogger
 	}
 
+	if cfg.MaxPayloadBytes < 0 {
+		return nil, newServiceError(opServiceNew, reasonInvalidMaxPayloadBytes, errInvalidMaxBytes)
+	}
+	maxPayloadBytes := cfg.MaxPayloadBytes
+	if maxPayloadBytes == 0 {
+		maxPayloadBytes = DefaultMaxPayloadBytes
+	}
+
+	if cfg.MaxNotesPerUser < 0 {
+		return nil, newServiceError(opServiceNew, reasonInvalidMaxNotesPerUser, errInvalidMaxNotes)
+	}
+
+	if cfg.MaxUpdatesPerSync < 0 {
+		return nil, newServiceError(opServiceNew, reasonInvalidMaxUpdatesPerSync, errInvalidMaxBatch)
+	}
+	maxUpdatesPerSync := cfg.MaxUpdatesPerSync
+	if maxUpdatesPerSync == 0 {
+		maxUpdatesPerSync = DefaultMaxUpdatesPerSync
+	}
+
+	if cfg.SyncTimeout < 0 {
+		return nil, newServiceError(opServiceNew, reasonInvalidSyncTimeout, errInvalidTimeout)
+	}
+
+	if cfg.BusyRetries < 0 {
+		return nil, newServiceError(opServiceNew, reasonInvalidBusyRetries, errInvalidRetries)
+	}
+
+	if cfg.CompactionThreshold < 0 {
+		return nil, newServiceError(opServiceNew, reasonInvalidCompactionThreshold, errInvalidCompaction)
+	}
+	compactionThreshold := cfg.CompactionThreshold
+	if compactionThreshold == 0 {
+		compactionThreshold = DefaultCompactionThreshold
+	}
+
+	tracer := noOpTracer
+	if cfg.TracerProvider != nil {
+		tracer = cfg.TracerProvider.Tracer(tracerName)
+	}
+
 	return &Service{
-		db:     cfg.Database,
-		clock:  clock,
-		logger: logger,
+		db:                  cfg.Database,
+		clock:               clock,
+		logger:              logger,
+		maxPayloadBytes:     maxPayloadBytes,
+		maxNotesPerUser:     cfg.MaxNotesPerUser,
+		maxUpdatesPerSync:   maxUpdatesPerSync,
+		syncTimeout:         cfg.SyncTimeout,
+		busyRetries:         cfg.BusyRetries,
+		compactionThreshold: compactionThreshold,
+		tracer:              tracer,
+		observer:            cfg.Observer,
+		userLocks:           newUserLockTable(),
 	}, nil
 }
 
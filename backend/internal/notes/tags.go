@@ -0,0 +1,121 @@
+package notes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	opSetNoteTags         = "notes.set_note_tags"
+	opListNotesByTag      = "notes.list_notes_by_tag"
+	fieldTag              = "tag"
+	queryUserTag          = fieldUserID + " = ? AND " + fieldTag + " = ?"
+	reasonTagDeleteFailed = "tag_delete_failed"
+	reasonTagInsertFailed = "tag_insert_failed"
+	reasonTooManyTags     = "too_many_tags"
+)
+
+// SetNoteTags replaces the tags of a note the user owns and returns the stored set, de-duplicated and sorted.
+// An empty set clears the note's tags. Notes the user does not own yield ErrNoteNotFound, and more than
+// MaxTagsPerNote distinct tags yield ErrTooManyTags.
+func (service *Service) SetNoteTags(ctx context.Context, userID UserID, noteID NoteID, tags []Tag) ([]Tag, error) {
+	if _, err := service.loadOwnedSnapshot(ctx, opSetNoteTags, userID, noteID); err != nil {
+		return nil, err
+	}
+
+	uniqueTags := uniqueSortedTags(tags)
+	if len(uniqueTags) > MaxTagsPerNote {
+		tagsErr := fmt.Errorf("%w: %d exceeds %d", ErrTooManyTags, len(uniqueTags), MaxTagsPerNote)
+		service.logError(opSetNoteTags, reasonTooManyTags, tagsErr,
+			zap.String(fieldUserID, userID.String()),
+			zap.String(fieldNoteID, noteID.String()))
+		return nil, newServiceError(opSetNoteTags, reasonTooManyTags, tagsErr)
+	}
+	err := service.db.WithContext(ctx).Transaction(func(transaction *gorm.DB) error {
+		if err := transaction.Where(queryUserNote, userID.String(), noteID.String()).Delete(&NoteTag{}).Error; err != nil {
+			service.logError(opSetNoteTags, reasonTagDeleteFailed, err,
+				zap.String(fieldUserID, userID.String()),
+				zap.String(fieldNoteID, noteID.String()))
+			return newServiceError(opSetNoteTags, reasonTagDeleteFailed, err)
+		}
+		if len(uniqueTags) == 0 {
+			return nil
+		}
+		rows := make([]NoteTag, 0, len(uniqueTags))
+		for _, tag := range uniqueTags {
+			rows = append(rows, NoteTag{UserID: userID.String(), NoteID: noteID.String(), Tag: tag.String()})
+		}
+		if err := transaction.Create(&rows).Error; err != nil {
+			service.logError(opSetNoteTags, reasonTagInsertFailed, err,
+				zap.String(fieldUserID, userID.String()),
+				zap.String(fieldNoteID, noteID.String()))
+			return newServiceError(opSetNoteTags, reasonTagInsertFailed, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return uniqueTags, nil
+}
+
+// ListNotesByTag returns the snapshots of the user's notes carrying tag, ordered by note identifier.
+func (service *Service) ListNotesByTag(ctx context.Context, userID UserID, tag Tag) ([]CrdtSnapshotRecord, error) {
+	if service.db == nil {
+		service.logError(opListNotesByTag, reasonMissingDatabase, errMissingDatabase)
+		return nil, newServiceError(opListNotesByTag, reasonMissingDatabase, errMissingDatabase)
+	}
+
+	taggedNoteIDs := service.db.WithContext(ctx).Model(&NoteTag{}).
+		Select(fieldNoteID).
+		Where(queryUserTag, userID.String(), tag.String())
+	var snapshots []CrdtSnapshot
+	if err := service.db.WithContext(ctx).
+		Where(queryUserID, userID.String()).
+		Where(queryNoteIDIn, taggedNoteIDs).
+		Order(orderNoteIDAsc).
+		Find(&snapshots).Error; err != nil {
+		service.logError(opListNotesByTag, reasonQueryFailed, err, zap.String(fieldUserID, userID.String()))
+		return nil, newServiceError(opListNotesByTag, reasonQueryFailed, err)
+	}
+	return service.decodeCrdtSnapshots(opListNotesByTag, snapshots)
+}
+
+// listUserTags returns the user's tags grouped by note, each note's tags sorted.
+func (service *Service) listUserTags(ctx context.Context, operation string, userID UserID) (map[NoteID][]Tag, error) {
+	var rows []NoteTag
+	if err := service.db.WithContext(ctx).
+		Where(queryUserID, userID.String()).
+		Order(orderNoteIDAsc).
+		Order(fieldTag + " ASC").
+		Find(&rows).Error; err != nil {
+		service.logError(operation, reasonQueryFailed, err, zap.String(fieldUserID, userID.String()))
+		return nil, newServiceError(operation, reasonQueryFailed, err)
+	}
+	tagsByNoteID := make(map[NoteID][]Tag)
+	for _, row := range rows {
+		noteID := NoteID(row.NoteID)
+		tagsByNoteID[noteID] = append(tagsByNoteID[noteID], Tag(row.Tag))
+	}
+	return tagsByNoteID, nil
+}
+
+func uniqueSortedTags(tags []Tag) []Tag {
+	seen := make(map[Tag]struct{}, len(tags))
+	unique := make([]Tag, 0, len(tags))
+	for _, tag := range tags {
+		if _, exists := seen[tag]; exists {
+			continue
+		}
+		seen[tag] = struct{}{}
+		unique = append(unique, tag)
+	}
+	sort.Slice(unique, func(left, right int) bool {
+		return unique[left] < unique[right]
+	})
+	return unique
+}
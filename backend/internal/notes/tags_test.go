@@ -0,0 +1,117 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestSetNoteTagsReplacesAndFiltersByTag(testContext *testing.T) {
+	service := mustCrdtService(testContext)
+	backgroundContext := context.Background()
+	userID := mustUserID(testContext, "user-tags")
+	otherUserID := mustUserID(testContext, "user-tags-other")
+	workNoteID := mustNoteID(testContext, "note-tags-work")
+	homeNoteID := mustNoteID(testContext, "note-tags-home")
+
+	updates := []CrdtUpdateEnvelope{
+		mustCrdtUpdateEnvelope(testContext, userID, workNoteID, baseUpdateB64, baseSnapshotB64, 0),
+		mustCrdtUpdateEnvelope(testContext, userID, homeNoteID, baseUpdateB64, baseSnapshotB64, 0),
+	}
+	if _, err := service.ApplyCrdtUpdates(backgroundContext, userID, updates); err != nil {
+		testContext.Fatalf("apply failed: %v", err)
+	}
+
+	stored, err := service.SetNoteTags(backgroundContext, userID, workNoteID, mustTags(testContext, " Work ", "urgent", "WORK"))
+	if err != nil {
+		testContext.Fatalf("set tags failed: %v", err)
+	}
+	if expected := []Tag{"urgent", "work"}; !slices.Equal(stored, expected) {
+		testContext.Fatalf("expected normalized tags %v, got %v", expected, stored)
+	}
+	if _, err := service.SetNoteTags(backgroundContext, userID, homeNoteID, mustTags(testContext, "home")); err != nil {
+		testContext.Fatalf("set tags failed: %v", err)
+	}
+
+	assertTaggedNotes(testContext, service, userID, "work", workNoteID)
+	assertTaggedNotes(testContext, service, userID, "home", homeNoteID)
+
+	if _, err := service.SetNoteTags(backgroundContext, userID, workNoteID, mustTags(testContext, "home")); err != nil {
+		testContext.Fatalf("replace tags failed: %v", err)
+	}
+	assertTaggedNotes(testContext, service, userID, "work")
+	assertTaggedNotes(testContext, service, userID, "urgent")
+	assertTaggedNotes(testContext, service, userID, "home", homeNoteID, workNoteID)
+	assertTaggedNotes(testContext, service, otherUserID, "home")
+
+	export, err := service.ExportUserData(backgroundContext, userID)
+	if err != nil {
+		testContext.Fatalf("export user data failed: %v", err)
+	}
+	if len(export.Tags) != 2 || !slices.Equal(export.Tags[workNoteID], []Tag{"home"}) || !slices.Equal(export.Tags[homeNoteID], []Tag{"home"}) {
+		testContext.Fatalf("expected the export to carry every note's tags, got %v", export.Tags)
+	}
+
+	tooManyTags := make([]string, 0, MaxTagsPerNote+1)
+	for index := 0; index <= MaxTagsPerNote; index++ {
+		tooManyTags = append(tooManyTags, fmt.Sprintf("tag-%d", index))
+	}
+	if _, err := service.SetNoteTags(backgroundContext, userID, workNoteID, mustTags(testContext, tooManyTags...)); !errors.Is(err, ErrTooManyTags) {
+		testContext.Fatalf("expected ErrTooManyTags past MaxTagsPerNote, got %v", err)
+	}
+	assertTaggedNotes(testContext, service, userID, "home", homeNoteID, workNoteID)
+
+	if _, err := service.SetNoteTags(backgroundContext, otherUserID, workNoteID, mustTags(testContext, "stolen")); !errors.Is(err, ErrNoteNotFound) {
+		testContext.Fatalf("expected ErrNoteNotFound for a foreign note, got %v", err)
+	}
+
+	if err := service.DeleteUserData(backgroundContext, userID); err != nil {
+		testContext.Fatalf("delete user data failed: %v", err)
+	}
+	var remaining int64
+	if err := service.db.Model(&NoteTag{}).Where(queryUserID, userID.String()).Count(&remaining).Error; err != nil {
+		testContext.Fatalf("count tags failed: %v", err)
+	}
+	if remaining != 0 {
+		testContext.Fatalf("expected tags to be deleted with the user's notes, found %d", remaining)
+	}
+}
+
+func TestNewTagValidation(testContext *testing.T) {
+	if _, err := NewTag("   "); !errors.Is(err, ErrInvalidTag) {
+		testContext.Fatalf("expected empty tag to be rejected, got %v", err)
+	}
+	if _, err := NewTag(string(make([]byte, maxTagLength+1))); err == nil {
+		testContext.Fatal("expected oversized tag to be rejected")
+	}
+}
+
+func mustTags(testContext *testing.T, rawTags ...string) []Tag {
+	testContext.Helper()
+	tags := make([]Tag, 0, len(rawTags))
+	for _, rawTag := range rawTags {
+		tag, err := NewTag(rawTag)
+		if err != nil {
+			testContext.Fatalf("invalid tag %q: %v", rawTag, err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+func assertTaggedNotes(testContext *testing.T, service *Service, userID UserID, tag Tag, expected ...NoteID) {
+	testContext.Helper()
+	records, err := service.ListNotesByTag(context.Background(), userID, tag)
+	if err != nil {
+		testContext.Fatalf("list by tag %q failed: %v", tag, err)
+	}
+	noteIDs := make([]NoteID, 0, len(records))
+	for _, record := range records {
+		noteIDs = append(noteIDs, record.NoteID())
+	}
+	if !slices.Equal(noteIDs, expected) {
+		testContext.Fatalf("expected notes %v for tag %q, got %v", expected, tag, noteIDs)
+	}
+}
@@ -0,0 +1,45 @@
+package notes
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	tracerName = "github.com/MarcoPoloResearchLab/gravity/backend/internal/notes"
+
+	// Span attribute keys shared by the service span and the HTTP sync span that wraps it.
+	AttributeUserID         = "gravity.user_id"
+	AttributeOperationCount = "gravity.operation_count"
+	AttributeAcceptedCount  = "gravity.accepted_count"
+	AttributeDuplicateCount = "gravity.duplicate_count"
+	AttributeRejectedCount  = "gravity.rejected_count"
+)
+
+var noOpTracer = noop.NewTracerProvider().Tracer(tracerName)
+
+func (s *Service) tracerOrDefault() trace.Tracer {
+	if s == nil || s.tracer == nil {
+		return noOpTracer
+	}
+	return s.tracer
+}
+
+// CrdtOutcomeAttributes summarizes a successful batch as span attributes. Rejection fails the whole batch,
+// so a batch with outcomes always reports zero rejected updates.
+func CrdtOutcomeAttributes(outcomes []CrdtUpdateOutcome) []attribute.KeyValue {
+	accepted, duplicates := 0, 0
+	for _, outcome := range outcomes {
+		if outcome.Duplicate() {
+			duplicates++
+			continue
+		}
+		accepted++
+	}
+	return []attribute.KeyValue{
+		attribute.Int(AttributeAcceptedCount, accepted),
+		attribute.Int(AttributeDuplicateCount, duplicates),
+		attribute.Int(AttributeRejectedCount, 0),
+	}
+}
@@ -0,0 +1,61 @@
+package notes
+
+import (
+	"context"
+	"sync"
+)
+
+// userLockTable serializes write transactions per user while letting different users proceed concurrently.
+// Entries are reference counted and removed when the last holder or waiter releases them, so the table
+// only holds users with a write in flight.
+type userLockTable struct {
+	mu    sync.Mutex
+	locks map[UserID]*userLock
+}
+
+type userLock struct {
+	held       chan struct{}
+	references int
+}
+
+func newUserLockTable() *userLockTable {
+	return &userLockTable{locks: make(map[UserID]*userLock)}
+}
+
+// acquire blocks until the user's lock is held or ctx is done, returning the release function on success.
+func (table *userLockTable) acquire(ctx context.Context, userID UserID) (func(), error) {
+	table.mu.Lock()
+	lock, exists := table.locks[userID]
+	if !exists {
+		lock = &userLock{held: make(chan struct{}, 1)}
+		table.locks[userID] = lock
+	}
+	lock.references++
+	table.mu.Unlock()
+
+	select {
+	case lock.held <- struct{}{}:
+		return func() {
+			<-lock.held
+			table.forget(userID, lock)
+		}, nil
+	case <-ctx.Done():
+		table.forget(userID, lock)
+		return nil, ctx.Err()
+	}
+}
+
+func (table *userLockTable) forget(userID UserID, lock *userLock) {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	lock.references--
+	if lock.references == 0 {
+		delete(table.locks, userID)
+	}
+}
+
+func (table *userLockTable) size() int {
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	return len(table.locks)
+}
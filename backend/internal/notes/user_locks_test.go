@@ -0,0 +1,53 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUserLockTableSerializesPerUserAndEvicts(testContext *testing.T) {
+	table := newUserLockTable()
+	backgroundContext := context.Background()
+
+	releaseFirst, err := table.acquire(backgroundContext, "user-lock-a")
+	if err != nil {
+		testContext.Fatalf("first acquire failed: %v", err)
+	}
+	releaseOther, err := table.acquire(backgroundContext, "user-lock-b")
+	if err != nil {
+		testContext.Fatalf("expected another user to acquire immediately, got %v", err)
+	}
+
+	waitContext, cancel := context.WithTimeout(backgroundContext, 20*time.Millisecond)
+	defer cancel()
+	if _, err := table.acquire(waitContext, "user-lock-a"); !errors.Is(err, context.DeadlineExceeded) {
+		testContext.Fatalf("expected a second acquire for the same user to wait, got %v", err)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		release, acquireErr := table.acquire(backgroundContext, "user-lock-a")
+		if acquireErr != nil {
+			close(acquired)
+			return
+		}
+		acquired <- release
+	}()
+	releaseFirst()
+	select {
+	case releaseSecond, ok := <-acquired:
+		if !ok {
+			testContext.Fatal("waiting acquire failed")
+		}
+		releaseSecond()
+	case <-time.After(time.Second):
+		testContext.Fatal("expected the waiting acquire to proceed after release")
+	}
+	releaseOther()
+
+	if size := table.size(); size != 0 {
+		testContext.Fatalf("expected idle entries to be evicted, %d remain", size)
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAccessLogMiddlewareLevelsAndSkips(testContext *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testCases := []struct {
+		name          string
+		path          string
+		status        int
+		expectEntries int
+		expectLevel   zapcore.Level
+	}{
+		{name: "success-logs-info", path: "/ok", status: http.StatusOK, expectEntries: 1, expectLevel: zap.InfoLevel},
+		{name: "client-error-logs-info", path: "/ok", status: http.StatusBadRequest, expectEntries: 1, expectLevel: zap.InfoLevel},
+		{name: "server-error-logs-error", path: "/ok", status: http.StatusInternalServerError, expectEntries: 1, expectLevel: zap.ErrorLevel},
+		{name: "stream-is-skipped", path: notesStreamPath, status: http.StatusOK, expectEntries: 0},
+	}
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(testContext *testing.T) {
+			core, logs := observer.New(zap.InfoLevel)
+			router := gin.New()
+			router.Use(requestIDMiddleware(zap.New(core)))
+			router.Use(accessLogMiddleware(zap.New(core)))
+			router.GET(testCase.path, func(c *gin.Context) {
+				c.Status(testCase.status)
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, testCase.path+"?access_token=secret", http.NoBody))
+
+			entries := logs.FilterMessage(accessLogMessage).All()
+			if len(entries) != testCase.expectEntries {
+				testContext.Fatalf("expected %d access log entries, got %d", testCase.expectEntries, len(entries))
+			}
+			if testCase.expectEntries == 0 {
+				return
+			}
+			entry := entries[0]
+			if entry.Level != testCase.expectLevel {
+				testContext.Fatalf("expected level %s, got %s", testCase.expectLevel, entry.Level)
+			}
+			fields := entry.ContextMap()
+			if fields["path"] != testCase.path || fields["status"] != int64(testCase.status) {
+				testContext.Fatalf("unexpected access log fields: %#v", fields)
+			}
+		})
+	}
+}
+
+func TestAccessLogRecordsCompletedSync(testContext *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	server := newIntegrationTestServerWithDependencies(testContext, Dependencies{
+		Realtime: NewRealtimeDispatcher(),
+		Logger:   zap.New(core),
+	})
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	var syncPayload crdtSyncResponsePayload
+	mustPostCrdtJSON(testContext, server.URL+"/notes/sync", sessionToken, map[string]any{
+		"protocol": crdtProtocolVersion,
+		"updates": []map[string]any{
+			{"note_id": sessionNoteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0},
+		},
+		"cursors": []map[string]any{
+			{"note_id": sessionNoteID, "last_update_id": 0},
+		},
+	}, &syncPayload)
+
+	deadline := time.Now().Add(time.Second)
+	for logs.FilterMessage(accessLogMessage).Len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	entries := logs.FilterMessage(accessLogMessage).All()
+	if len(entries) != 1 {
+		testContext.Fatalf("expected one access log entry, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["method"] != http.MethodPost || fields["path"] != "/notes/sync" || fields["status"] != int64(http.StatusOK) {
+		testContext.Fatalf("unexpected access log fields: %#v", fields)
+	}
+	if fields["user_id"] != sessionUserID {
+		testContext.Fatalf("expected user id %q, got %#v", sessionUserID, fields["user_id"])
+	}
+	if requestID, ok := fields[requestIDField].(string); !ok || requestID == "" {
+		testContext.Fatalf("expected request id field, got %#v", fields[requestIDField])
+	}
+	if _, ok := fields["latency"]; !ok {
+		testContext.Fatal("expected latency field")
+	}
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultMaxRequestBodyBytes bounds request bodies when no limit is configured. It is independent of the
+// per-payload CRDT limit because one sync batch carries many payloads.
+const DefaultMaxRequestBodyBytes int64 = 16 * 1024 * 1024
+
+// requestBodyLimitMiddleware caps how much of a request body handlers can read.
+// Bodies that declare an oversized Content-Length are rejected before any handler runs.
+func requestBodyLimitMiddleware(maxBytes int64) gin.HandlerFunc {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxRequestBodyBytes
+	}
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > maxBytes {
+			abortWithError(c, http.StatusRequestEntityTooLarge, "request_too_large")
+			return
+		}
+		if c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		}
+		c.Next()
+	}
+}
+
+// bindJSON decodes the request body into target, answering 413 when the body limit was hit and 400 otherwise.
+func bindJSON(c *gin.Context, target any) bool {
+	err := c.ShouldBindJSON(target)
+	if err == nil {
+		return true
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondError(c, http.StatusRequestEntityTooLarge, "request_too_large", nil)
+		return false
+	}
+	respondError(c, http.StatusBadRequest, "invalid_request", nil)
+	return false
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestBodyLimitRejectsOversizedBodies(t *testing.T) {
+	const maxRequestBodyBytes = 256
+	server := newIntegrationTestServerWithDependencies(t, Dependencies{MaxRequestBodyBytes: maxRequestBodyBytes})
+	sessionToken := mustMintSessionToken(t, sessionSigningSecret, sessionUserID, time.Now())
+
+	smallBody, err := json.Marshal(map[string]any{
+		"protocol": crdtProtocolVersion,
+		"updates": []map[string]any{
+			{"note_id": sessionNoteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode request: %v", err)
+	}
+	oversizedBody := []byte(`{"protocol":"` + crdtProtocolVersion + `","updates":[],"padding":"` + strings.Repeat("x", maxRequestBodyBytes) + `"}`)
+
+	testCases := []struct {
+		name           string
+		path           string
+		body           io.Reader
+		expectedStatus int
+	}{
+		{name: "within-limit", path: "/notes/crdt/push", body: bytes.NewReader(smallBody), expectedStatus: http.StatusOK},
+		{name: "declared-length-over-limit", path: "/notes/sync", body: bytes.NewReader(oversizedBody), expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "streamed-body-over-limit", path: "/notes/crdt/push", body: io.MultiReader(bytes.NewReader(oversizedBody)), expectedStatus: http.StatusRequestEntityTooLarge},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodPost, server.URL+testCase.path, testCase.body)
+			if err != nil {
+				t.Fatalf("failed to construct request: %v", err)
+			}
+			request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+			request.Header.Set("Content-Type", jsonContentType)
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer response.Body.Close()
+			if response.StatusCode != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatus, response.StatusCode)
+			}
+			if testCase.expectedStatus != http.StatusRequestEntityTooLarge {
+				return
+			}
+			var payload map[string]any
+			if err := json.NewDecoder(response.Body).Decode(&payload); err != nil {
+				t.Fatalf("failed to decode error response: %v", err)
+			}
+			if payload["error"] != "request_too_large" {
+				t.Fatalf("unexpected error payload: %#v", payload)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	acceptEncodingHeader  = "Accept-Encoding"
+	contentEncodingHeader = "Content-Encoding"
+	contentLengthHeader   = "Content-Length"
+	varyHeader            = "Vary"
+	gzipEncoding          = "gzip"
+
+	defaultGzipMinSize = 1024
+)
+
+// bufferedResponseWriter holds the response body so compression can be decided once its size is known.
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedResponseWriter) WriteString(data string) (int, error) {
+	return w.body.WriteString(data)
+}
+
+// gzipMiddleware compresses buffered responses of at least minSize bytes for clients accepting gzip.
+// It must not wrap streaming routes, since the whole body is held in memory.
+func gzipMiddleware(minSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add(varyHeader, acceptEncodingHeader)
+		if !acceptsGzip(c.GetHeader(acceptEncodingHeader)) {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		buffered := &bufferedResponseWriter{ResponseWriter: original}
+		c.Writer = buffered
+		c.Next()
+		c.Writer = original
+
+		body := buffered.body.Bytes()
+		if len(body) < minSize || original.Header().Get(contentEncodingHeader) != "" {
+			_, _ = original.Write(body)
+			return
+		}
+
+		var compressed bytes.Buffer
+		gzipWriter := gzip.NewWriter(&compressed)
+		if _, err := gzipWriter.Write(body); err != nil {
+			_, _ = original.Write(body)
+			return
+		}
+		if err := gzipWriter.Close(); err != nil {
+			_, _ = original.Write(body)
+			return
+		}
+		original.Header().Set(contentEncodingHeader, gzipEncoding)
+		original.Header().Del(contentLengthHeader)
+		_, _ = original.Write(compressed.Bytes())
+	}
+}
+
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), gzipEncoding) {
+			continue
+		}
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListNotesCompressesOnlyWhenRequested(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	updates := make([]map[string]any, 0, 40)
+	for index := 0; index < 40; index++ {
+		updates = append(updates, map[string]any{
+			"note_id":            fmt.Sprintf("note-gzip-%02d", index),
+			"update_b64":         crdtPushUpdateB64,
+			"snapshot_b64":       crdtPushSnapshotB64,
+			"snapshot_update_id": 0,
+		})
+	}
+	var pushPayload crdtPushResponsePayload
+	mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/push", sessionToken, map[string]any{
+		"protocol": crdtProtocolVersion,
+		"updates":  updates,
+	}, &pushPayload)
+
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	testCases := []struct {
+		name           string
+		acceptEncoding string
+		expectGzip     bool
+	}{
+		{name: "identity", acceptEncoding: ""},
+		{name: "gzip", acceptEncoding: "gzip, deflate", expectGzip: true},
+		{name: "gzip-refused", acceptEncoding: "gzip;q=0"},
+	}
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(testContext *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, server.URL+"/notes", http.NoBody)
+			if err != nil {
+				testContext.Fatalf("failed to construct request: %v", err)
+			}
+			request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+			if testCase.acceptEncoding != "" {
+				request.Header.Set(acceptEncodingHeader, testCase.acceptEncoding)
+			}
+			response, err := client.Do(request)
+			if err != nil {
+				testContext.Fatalf("request failed: %v", err)
+			}
+			defer response.Body.Close()
+
+			if !strings.Contains(strings.Join(response.Header.Values(varyHeader), ","), acceptEncodingHeader) {
+				testContext.Fatalf("expected Vary to include %s, got %v", acceptEncodingHeader, response.Header.Values(varyHeader))
+			}
+			encoding := response.Header.Get(contentEncodingHeader)
+			if testCase.expectGzip != (encoding == gzipEncoding) {
+				testContext.Fatalf("unexpected content encoding %q", encoding)
+			}
+
+			body := response.Body
+			if testCase.expectGzip {
+				gzipReader, err := gzip.NewReader(response.Body)
+				if err != nil {
+					testContext.Fatalf("failed to open gzip body: %v", err)
+				}
+				defer gzipReader.Close()
+				body = gzipReader
+			}
+			var payload crdtSnapshotResponsePayload
+			if err := json.NewDecoder(body).Decode(&payload); err != nil {
+				testContext.Fatalf("failed to decode payload: %v", err)
+			}
+			if len(payload.Notes) != len(updates) {
+				testContext.Fatalf("expected %d notes, got %d", len(updates), len(payload.Notes))
+			}
+		})
+	}
+}
+
+func TestGzipMiddlewareSkipsSmallResponses(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/notes", http.NoBody)
+	if err != nil {
+		testContext.Fatalf("failed to construct request: %v", err)
+	}
+	request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+	request.Header.Set(acceptEncodingHeader, gzipEncoding)
+	response, err := client.Do(request)
+	if err != nil {
+		testContext.Fatalf("request failed: %v", err)
+	}
+	defer response.Body.Close()
+	if encoding := response.Header.Get(contentEncodingHeader); encoding != "" {
+		testContext.Fatalf("expected small response to stay uncompressed, got %q", encoding)
+	}
+}
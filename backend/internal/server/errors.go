@@ -0,0 +1,153 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/notes"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// errorMessages lists every stable error code the API returns together with its human-readable message.
+// Clients should branch on the code; messages may be reworded.
+var errorMessages = map[string]string{
+	"unauthorized":               "authorization token missing or invalid",
+	"forbidden":                  "the session lacks the role this route requires",
+	"rate_limited":               "too many requests; retry after the Retry-After delay",
+	"unsupported_encoding":       "request Content-Encoding must be gzip, deflate or identity",
+	"request_too_large":          "request body exceeds the configured size limit",
+	"invalid_request":            "request body is missing or malformed",
+	"invalid_protocol":           "unsupported sync protocol version",
+	"invalid_note_id":            "note id is missing or invalid",
+	"invalid_user_id":            "user id is missing or invalid",
+	"invalid_update":             "CRDT update is missing or invalid",
+	"invalid_snapshot":           "CRDT snapshot is missing or invalid",
+	"invalid_snapshot_update_id": "snapshot update id is invalid",
+	"invalid_cursor":             "cursor is invalid",
+	"missing_cursor":             "a cursor is required for every updated note",
+	"invalid_limit":              "limit must be between 0 and the maximum page size",
+	"invalid_since":              "since must be a non-negative unix timestamp",
+	"invalid_tag":                "tag is empty or too long",
+	"too_many_tags":              "too many tags on one note",
+	"too_many_note_ids":          "too many note ids in one request",
+	"too_many_operations":        "too many updates in one sync request",
+	"note_not_found":             "no such note for this account",
+	"payload_too_large":          "a CRDT update or snapshot exceeds the payload size limit",
+	"note_quota_exceeded":        "the note quota for this account is exhausted",
+	"sync_timeout":               "sync did not complete within the transaction timeout",
+	"sync_failed":                "sync could not be completed",
+	"list_failed":                "notes could not be listed",
+	"tags_failed":                "note tags could not be saved",
+	"integrity_check_failed":     "note integrity could not be verified",
+	"stats_failed":               "account statistics could not be computed",
+	"export_failed":              "account data could not be exported",
+	"delete_failed":              "account data could not be deleted",
+	"logout_failed":              "session could not be revoked",
+	"stream_unavailable":         "realtime stream is unavailable",
+	"database_unreachable":       "the database did not answer the readiness check",
+}
+
+// errorResponse is the body of every error returned by the API. Error carries the stable code for the
+// failure class; Code repeats it or, for storage failures, carries the more specific notes service code.
+type errorResponse struct {
+	Error     string `json:"error"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	// OperationIndex and NoteID locate the rejected update in a sync or push batch.
+	OperationIndex *int   `json:"operation_index,omitempty"`
+	NoteID         string `json:"note_id,omitempty"`
+}
+
+func newErrorResponse(c *gin.Context, errorCode string, cause error) errorResponse {
+	response := buildErrorResponse(errorCode, cause)
+	response.RequestID = c.GetString(requestIDContextKey)
+	return response
+}
+
+func buildErrorResponse(errorCode string, cause error) errorResponse {
+	response := errorResponse{
+		Error:   errorCode,
+		Code:    errorCode,
+		Message: errorMessages[errorCode],
+	}
+	var serviceErr *notes.ServiceError
+	if errors.As(cause, &serviceErr) {
+		response.Code = serviceErr.Code()
+	}
+	return response
+}
+
+// serviceErrorStatuses lists the notes sentinel errors that surface as client-visible failures.
+var serviceErrorStatuses = []struct {
+	target    error
+	status    int
+	errorCode string
+}{
+	{target: notes.ErrPayloadTooLarge, status: http.StatusRequestEntityTooLarge, errorCode: "payload_too_large"},
+	{target: notes.ErrTooManyUpdates, status: http.StatusBadRequest, errorCode: "too_many_operations"},
+	{target: notes.ErrTooManyNoteIDs, status: http.StatusBadRequest, errorCode: "too_many_note_ids"},
+	{target: notes.ErrTooManyTags, status: http.StatusBadRequest, errorCode: "too_many_tags"},
+	{target: notes.ErrInvalidListLimit, status: http.StatusBadRequest, errorCode: "invalid_limit"},
+	{target: notes.ErrInvalidListCursor, status: http.StatusBadRequest, errorCode: "invalid_cursor"},
+	{target: notes.ErrNoteQuotaExceeded, status: http.StatusForbidden, errorCode: "note_quota_exceeded"},
+	{target: notes.ErrNoteNotFound, status: http.StatusNotFound, errorCode: "note_not_found"},
+	{target: notes.ErrSyncTimeout, status: http.StatusGatewayTimeout, errorCode: "sync_timeout"},
+}
+
+// statusForServiceError maps a notes service failure to its HTTP status and error body. Failures outside
+// serviceErrorStatuses, such as missing_database or a query error, are 500s reported as fallbackCode.
+// The body's Code still carries the *notes.ServiceError code; the caller sets RequestID.
+func statusForServiceError(err error, fallbackCode string) (int, errorResponse) {
+	for _, known := range serviceErrorStatuses {
+		if errors.Is(err, known.target) {
+			return known.status, buildErrorResponse(known.errorCode, err)
+		}
+	}
+	return http.StatusInternalServerError, buildErrorResponse(fallbackCode, err)
+}
+
+// respondServiceError logs a notes service failure, as a warning when the client caused it, and writes
+// the body chosen by statusForServiceError.
+func (h *httpHandler) respondServiceError(c *gin.Context, err error, fallbackCode string, logMessage string) {
+	status, response := statusForServiceError(err, fallbackCode)
+	response.RequestID = c.GetString(requestIDContextKey)
+	fields := []zap.Field{zap.Error(err)}
+	var serviceErr *notes.ServiceError
+	if errors.As(err, &serviceErr) {
+		fields = append([]zap.Field{zap.String("error_code", serviceErr.Code())}, fields...)
+	}
+	if status >= http.StatusInternalServerError {
+		h.loggerFor(c).Error(logMessage, fields...)
+	} else {
+		h.loggerFor(c).Warn(logMessage, fields...)
+	}
+	c.JSON(status, response)
+}
+
+// respondError writes the unified error body; cause may be nil for validation failures.
+func respondError(c *gin.Context, status int, errorCode string, cause error) {
+	c.JSON(status, newErrorResponse(c, errorCode, cause))
+}
+
+// abortWithError writes the unified error body and stops the handler chain.
+func abortWithError(c *gin.Context, status int, errorCode string) {
+	c.AbortWithStatusJSON(status, newErrorResponse(c, errorCode, nil))
+}
+
+// operationError reports which update of a sync batch failed validation.
+type operationError struct {
+	code   string
+	index  int
+	noteID notes.NoteID
+}
+
+// respondOperationError writes a 400 unified error body that also locates the rejected update.
+func respondOperationError(c *gin.Context, failure *operationError) {
+	response := newErrorResponse(c, failure.code, nil)
+	index := failure.index
+	response.OperationIndex = &index
+	response.NoteID = failure.noteID.String()
+	c.JSON(http.StatusBadRequest, response)
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/notes"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	metricsNamespace = "gravity"
+
+	syncOutcomeAccepted  = "accepted"
+	syncOutcomeDuplicate = "duplicate"
+	syncOutcomeRejected  = "rejected"
+
+	authResultSuccess = "success"
+	authResultFailure = "failure"
+
+	authReasonNone               = "none"
+	authReasonMissingToken       = "missing_token"
+	authReasonExpiredToken       = "expired_token"
+	authReasonInvalidToken       = "invalid_token"
+	authReasonIdentityResolution = "identity_resolution"
+	authReasonEmptyUserID        = "empty_user_id"
+)
+
+// Metrics holds the Prometheus collectors exposed on /metrics. A nil *Metrics disables instrumentation.
+type Metrics struct {
+	registry       *prometheus.Registry
+	syncOperations *prometheus.CounterVec
+	syncBatchSize  prometheus.Histogram
+	authAttempts   *prometheus.CounterVec
+}
+
+// NewMetrics builds an isolated registry with sync and authentication counters.
+func NewMetrics() *Metrics {
+	registry := prometheus.NewRegistry()
+	syncOperations := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "sync_operations_total",
+		Help:      "CRDT updates received by sync endpoints, by outcome.",
+	}, []string{"outcome"})
+	syncBatchSize := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "sync_batch_size",
+		Help:      "CRDT updates submitted per sync call.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 6),
+	})
+	authAttempts := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "auth_attempts_total",
+		Help:      "Session authorization attempts, by result and reason.",
+	}, []string{"result", "reason"})
+	registry.MustRegister(syncOperations, syncBatchSize, authAttempts)
+	return &Metrics{
+		registry:       registry,
+		syncOperations: syncOperations,
+		syncBatchSize:  syncBatchSize,
+		authAttempts:   authAttempts,
+	}
+}
+
+func (m *Metrics) registerRealtime(dispatcher *RealtimeDispatcher) error {
+	if m == nil || dispatcher == nil {
+		return nil
+	}
+	return m.registry.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "realtime_subscribers",
+		Help:      "Active realtime stream subscribers.",
+	}, func() float64 {
+		return float64(dispatcher.SubscriberCount())
+	}))
+}
+
+func (m *Metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// NotesObserver returns m as a notes.Observer, or nil when metrics are disabled.
+func (m *Metrics) NotesObserver() notes.Observer {
+	if m == nil {
+		return nil
+	}
+	return m
+}
+
+// SyncBatch records the size of one sync call.
+func (m *Metrics) SyncBatch(size int) {
+	if m == nil {
+		return
+	}
+	m.syncBatchSize.Observe(float64(size))
+}
+
+// UpdateApplied counts one committed CRDT update as accepted or duplicate.
+func (m *Metrics) UpdateApplied(duplicate bool) {
+	if m == nil {
+		return
+	}
+	if duplicate {
+		m.syncOperations.WithLabelValues(syncOutcomeDuplicate).Inc()
+		return
+	}
+	m.syncOperations.WithLabelValues(syncOutcomeAccepted).Inc()
+}
+
+func (m *Metrics) observeSyncRejected(count int) {
+	if m == nil {
+		return
+	}
+	m.syncOperations.WithLabelValues(syncOutcomeRejected).Add(float64(count))
+}
+
+func (m *Metrics) observeAuth(result, reason string) {
+	if m == nil {
+		return
+	}
+	m.authAttempts.WithLabelValues(result, reason).Inc()
+}
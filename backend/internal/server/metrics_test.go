@@ -0,0 +1,61 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsEndpointCountsAcceptedSyncOperations(testContext *testing.T) {
+	server := newIntegrationTestServerWithDependencies(testContext, Dependencies{Metrics: NewMetrics()})
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	pushBody := map[string]any{
+		"protocol": crdtProtocolVersion,
+		"updates": []map[string]any{
+			{"note_id": sessionNoteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0},
+		},
+	}
+	var pushPayload crdtPushResponsePayload
+	mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/push", sessionToken, pushBody, &pushPayload)
+
+	response, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		testContext.Fatalf("metrics request failed: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		testContext.Fatalf("unexpected metrics status: %d", response.StatusCode)
+	}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		testContext.Fatalf("failed to read metrics: %v", err)
+	}
+	exposition := string(body)
+
+	expectedSeries := []string{
+		`gravity_sync_operations_total{outcome="accepted"} 1`,
+		`gravity_auth_attempts_total{reason="none",result="success"} 1`,
+		`gravity_realtime_subscribers 0`,
+	}
+	for _, series := range expectedSeries {
+		if !strings.Contains(exposition, series) {
+			testContext.Fatalf("expected metrics to contain %q, got:\n%s", series, exposition)
+		}
+	}
+}
+
+func TestMetricsEndpointDisabledByDefault(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+
+	response, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		testContext.Fatalf("metrics request failed: %v", err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusNotFound {
+		testContext.Fatalf("expected metrics to be absent without configuration, got %d", response.StatusCode)
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/notes"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	formatQueryParameter = "format"
+	ndjsonFormat         = "ndjson"
+	ndjsonContentType    = "application/x-ndjson"
+
+	// ndjsonFlushInterval is how many lines are written between flushes of a streamed listing.
+	ndjsonFlushInterval = 100
+)
+
+// wantsNDJSON reports whether the request asks for a newline-delimited JSON listing.
+func wantsNDJSON(c *gin.Context) bool {
+	return strings.EqualFold(strings.TrimSpace(c.Query(formatQueryParameter)), ndjsonFormat)
+}
+
+// unlessNDJSON skips middleware for NDJSON listings, which are streamed and must not be buffered.
+func unlessNDJSON(middleware gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if wantsNDJSON(c) {
+			c.Next()
+			return
+		}
+		middleware(c)
+	}
+}
+
+// streamNotesNDJSON writes one snapshot object per line as batches are read, so memory stays flat for large
+// accounts. Failures after the first line cannot change the status code; they end the stream early and
+// are logged, and clients detect truncation by the missing trailing newline or a short read.
+func (h *httpHandler) streamNotesNDJSON(c *gin.Context, userID notes.UserID) {
+	for _, parameter := range []string{"limit", "cursor", "since", "tag"} {
+		if strings.TrimSpace(c.Query(parameter)) != "" {
+			respondError(c, http.StatusBadRequest, "invalid_request", nil)
+			return
+		}
+	}
+
+	encoder := json.NewEncoder(c.Writer)
+	written := 0
+	var writeErr error
+	err := h.notesService.StreamCrdtSnapshots(c.Request.Context(), userID, func(snapshot notes.CrdtSnapshotRecord) error {
+		if written == 0 {
+			c.Header("Content-Type", ndjsonContentType)
+			c.Status(http.StatusOK)
+		}
+		snapshotValue := snapshot.SnapshotB64().String()
+		snapshotUpdateID := snapshot.SnapshotUpdateID().Int64()
+		if writeErr = encoder.Encode(crdtSnapshotNotePayload{
+			NoteID:           snapshot.NoteID().String(),
+			SnapshotB64:      &snapshotValue,
+			SnapshotUpdateID: &snapshotUpdateID,
+		}); writeErr != nil {
+			return writeErr
+		}
+		written++
+		if written%ndjsonFlushInterval == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+
+	switch {
+	case err == nil:
+		if written == 0 {
+			c.Header("Content-Type", ndjsonContentType)
+			c.Status(http.StatusOK)
+			c.Writer.WriteHeaderNow()
+		}
+		c.Writer.Flush()
+	case writeErr != nil:
+		h.loggerFor(c).Info("NDJSON listing ended by client", zap.Int("written", written), zap.Error(err))
+	case written > 0:
+		h.loggerFor(c).Error("NDJSON listing failed mid-stream", zap.Int("written", written), zap.Error(err))
+	default:
+		h.respondServiceError(c, err, "list_failed", "failed to stream CRDT snapshots")
+	}
+}
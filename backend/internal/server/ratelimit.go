@@ -0,0 +1,119 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	retryAfterHeader          = "Retry-After"
+	defaultRateLimiterIdleTTL = 10 * time.Minute
+)
+
+// RateLimitConfig configures the per-user token bucket applied to authenticated routes.
+// A non-positive RequestsPerSecond disables rate limiting.
+type RateLimitConfig struct {
+	RequestsPerSecond float64
+	Burst             int
+}
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+type userRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	rate      float64
+	burst     float64
+	idleTTL   time.Duration
+	lastSweep time.Time
+	clock     func() time.Time
+}
+
+func newUserRateLimiter(config RateLimitConfig, clock func() time.Time) *userRateLimiter {
+	if config.RequestsPerSecond <= 0 {
+		return nil
+	}
+	burst := config.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	if clock == nil {
+		clock = time.Now
+	}
+	return &userRateLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		rate:      config.RequestsPerSecond,
+		burst:     float64(burst),
+		idleTTL:   defaultRateLimiterIdleTTL,
+		lastSweep: clock(),
+		clock:     clock,
+	}
+}
+
+// allow consumes a token for the user and reports how long to wait when none is available.
+func (l *userRateLimiter) allow(userID string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock()
+	l.evictIdle(now)
+
+	bucket, ok := l.buckets[userID]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, lastSeen: now}
+		l.buckets[userID] = bucket
+	}
+	elapsed := now.Sub(bucket.lastSeen).Seconds()
+	if elapsed > 0 {
+		bucket.tokens = math.Min(l.burst, bucket.tokens+elapsed*l.rate)
+	}
+	bucket.lastSeen = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+func (l *userRateLimiter) evictIdle(now time.Time) {
+	if now.Sub(l.lastSweep) < l.idleTTL {
+		return
+	}
+	for userID, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) >= l.idleTTL {
+			delete(l.buckets, userID)
+		}
+	}
+	l.lastSweep = now
+}
+
+func rateLimitMiddleware(limiter *userRateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString(userIDContextKey)
+		if userID == "" {
+			c.Next()
+			return
+		}
+		allowed, wait := limiter.allow(userID)
+		if !allowed {
+			retryAfterSeconds := int(math.Ceil(wait.Seconds()))
+			if retryAfterSeconds < 1 {
+				retryAfterSeconds = 1
+			}
+			c.Header(retryAfterHeader, strconv.Itoa(retryAfterSeconds))
+			abortWithError(c, http.StatusTooManyRequests, "rate_limited")
+			return
+		}
+		c.Next()
+	}
+}
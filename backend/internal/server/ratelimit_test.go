@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestUserRateLimiterRefillsAndEvicts(testContext *testing.T) {
+	currentTime := time.Unix(1700000000, 0)
+	limiter := newUserRateLimiter(RateLimitConfig{RequestsPerSecond: 2, Burst: 2}, func() time.Time {
+		return currentTime
+	})
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if allowed, _ := limiter.allow("user-a"); !allowed {
+			testContext.Fatalf("expected burst request %d to be allowed", attempt)
+		}
+	}
+	allowed, wait := limiter.allow("user-a")
+	if allowed {
+		testContext.Fatal("expected request beyond burst to be rejected")
+	}
+	if wait != 500*time.Millisecond {
+		testContext.Fatalf("expected 500ms wait, got %s", wait)
+	}
+	if allowed, _ := limiter.allow("user-b"); !allowed {
+		testContext.Fatal("expected other users to keep their own bucket")
+	}
+
+	currentTime = currentTime.Add(500 * time.Millisecond)
+	if allowed, _ := limiter.allow("user-a"); !allowed {
+		testContext.Fatal("expected bucket to refill over time")
+	}
+
+	currentTime = currentTime.Add(defaultRateLimiterIdleTTL)
+	limiter.allow("user-c")
+	if _, ok := limiter.buckets["user-a"]; ok {
+		testContext.Fatal("expected idle user bucket to be evicted")
+	}
+	if len(limiter.buckets) != 1 {
+		testContext.Fatalf("expected only the active bucket to remain, got %d", len(limiter.buckets))
+	}
+}
+
+func TestRateLimitMiddlewareReturnsTooManyRequests(testContext *testing.T) {
+	const burst = 3
+	server := newIntegrationTestServerWithDependencies(testContext, Dependencies{
+		RateLimit: RateLimitConfig{RequestsPerSecond: 0.5, Burst: burst},
+	})
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	statuses := make([]int, 0, burst+2)
+	var retryAfter string
+	for attempt := 0; attempt < burst+2; attempt++ {
+		request, err := http.NewRequest(http.MethodGet, server.URL+"/notes", http.NoBody)
+		if err != nil {
+			testContext.Fatalf("failed to construct request: %v", err)
+		}
+		request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			testContext.Fatalf("request failed: %v", err)
+		}
+		_ = response.Body.Close()
+		statuses = append(statuses, response.StatusCode)
+		if response.StatusCode == http.StatusTooManyRequests {
+			retryAfter = response.Header.Get(retryAfterHeader)
+		}
+	}
+
+	for index, status := range statuses {
+		expected := http.StatusOK
+		if index >= burst {
+			expected = http.StatusTooManyRequests
+		}
+		if status != expected {
+			testContext.Fatalf("request %d: expected %d, got %d (all: %v)", index, expected, status, statuses)
+		}
+	}
+	if retryAfter != "2" {
+		testContext.Fatalf("expected Retry-After of 2 seconds, got %q", retryAfter)
+	}
+}
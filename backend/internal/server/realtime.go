@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -10,31 +11,101 @@ const (
 	RealtimeEventNoteChanged = "note-change"
 	realtimeEventHeartbeat   = "heartbeat"
 	realtimeSourceBackend    = "gravity-backend"
+
+	defaultRealtimeBufferSize = 16
+	defaultRealtimeReplaySize = 64
+	// defaultRealtimeReplayBytes caps the inline payload bytes retained per user, since each message may
+	// carry CRDT updates far larger than its metadata.
+	defaultRealtimeReplayBytes = 4 << 20
+	// defaultRealtimeReplayTTL is how long a user's replay ring outlives their last subscriber, long
+	// enough for a reconnect to resume from its Last-Event-ID.
+	defaultRealtimeReplayTTL = 5 * time.Minute
+	// realtimeDeliveryTimeout bounds how long PublishContext waits on a single full subscriber buffer.
+	realtimeDeliveryTimeout = 100 * time.Millisecond
 )
 
 type RealtimeMessage struct {
+	ID        int64
 	UserID    string
 	EventType string
 	NoteIDs   []string
+	Updates   []RealtimeCrdtUpdate
+	Resync    bool
 	Timestamp time.Time
 }
 
+// RealtimeCrdtUpdate carries a stored CRDT update inline so subscribers can apply it without pulling.
+type RealtimeCrdtUpdate struct {
+	NoteID    string `json:"noteId"`
+	UpdateID  int64  `json:"updateId"`
+	UpdateB64 string `json:"updateB64"`
+}
+
 type RealtimeDispatcher struct {
-	mu          sync.RWMutex
-	subscribers map[string]map[int64]*realtimeSubscriber
-	nextID      int64
-	bufferSize  int
+	mu            sync.RWMutex
+	subscribers   map[string]map[int64]*realtimeSubscriber
+	recent        map[string]*replayRing
+	nextID        int64
+	nextMessageID int64
+	bufferSize    int
+	replaySize    int
+	replayBytes   int
+	replayTTL     time.Duration
+	lastSweep     time.Time
+	clock         func() time.Time
+	closed        bool
+}
+
+// replayRing holds a user's most recent messages. lastActive is refreshed on every publish and when the
+// user's last subscriber leaves, and the ring is evicted once it has been idle for the replay TTL.
+// droppedID is the highest message identifier trimmed from the ring or never retained in it.
+type replayRing struct {
+	messages   []RealtimeMessage
+	bytes      int
+	droppedID  int64
+	lastActive time.Time
 }
 
 type realtimeSubscriber struct {
-	id     int64
-	stream chan RealtimeMessage
+	id      int64
+	stream  chan RealtimeMessage
+	dropped atomic.Int64
+	mu      sync.Mutex
+	closed  bool
+	// done is closed with the subscriber so blocked senders give up; senders counts the sends still in
+	// flight, which close waits out before closing stream.
+	done    chan struct{}
+	senders sync.WaitGroup
 }
 
 func NewRealtimeDispatcher() *RealtimeDispatcher {
+	return NewRealtimeDispatcherWithOptions(defaultRealtimeBufferSize)
+}
+
+// NewRealtimeDispatcherWithOptions builds a dispatcher whose subscribers buffer up to bufferSize messages.
+// Non-positive sizes fall back to the default buffer.
+func NewRealtimeDispatcherWithOptions(bufferSize int) *RealtimeDispatcher {
+	return NewRealtimeDispatcherWithReplay(bufferSize, defaultRealtimeReplaySize)
+}
+
+// NewRealtimeDispatcherWithReplay additionally retains the last replaySize messages per user so
+// reconnecting streams can resume from a Last-Event-ID. Non-positive sizes fall back to the defaults.
+func NewRealtimeDispatcherWithReplay(bufferSize int, replaySize int) *RealtimeDispatcher {
+	if bufferSize <= 0 {
+		bufferSize = defaultRealtimeBufferSize
+	}
+	if replaySize <= 0 {
+		replaySize = defaultRealtimeReplaySize
+	}
 	return &RealtimeDispatcher{
 		subscribers: make(map[string]map[int64]*realtimeSubscriber),
-		bufferSize:  16,
+		recent:      make(map[string]*replayRing),
+		bufferSize:  bufferSize,
+		replaySize:  replaySize,
+		replayBytes: defaultRealtimeReplayBytes,
+		replayTTL:   defaultRealtimeReplayTTL,
+		lastSweep:   time.Now(),
+		clock:       time.Now,
 	}
 }
 
@@ -47,8 +118,12 @@ func (d *RealtimeDispatcher) Subscribe(ctx context.Context, userID string) (<-ch
 	subscriber := &realtimeSubscriber{
 		id:     d.nextSequence(),
 		stream: make(chan RealtimeMessage, d.bufferSize),
+		done:   make(chan struct{}),
+	}
+	if !d.registerSubscriber(userID, subscriber) {
+		subscriber.close()
+		return subscriber.stream, func() {}
 	}
-	d.registerSubscriber(userID, subscriber)
 	cleanup := func() {
 		d.unregisterSubscriber(userID, subscriber.id)
 	}
@@ -60,28 +135,266 @@ func (d *RealtimeDispatcher) Subscribe(ctx context.Context, userID string) (<-ch
 }
 
 func (d *RealtimeDispatcher) Publish(message RealtimeMessage) {
-	if message.UserID == "" || message.EventType == "" {
+	message, subscribers, ok := d.prepare(message)
+	if !ok {
 		return
 	}
-	d.mu.RLock()
-	subscribers := d.subscribers[message.UserID]
-	if len(subscribers) == 0 {
-		d.mu.RUnlock()
-		return
+	for _, subscriber := range subscribers {
+		subscriber.deliver(message)
+	}
+}
+
+// PublishContext delivers like Publish but waits up to realtimeDeliveryTimeout, or until ctx is done,
+// for space in each full subscriber buffer. It returns how many subscribers received the message.
+func (d *RealtimeDispatcher) PublishContext(ctx context.Context, message RealtimeMessage) int {
+	message, subscribers, ok := d.prepare(message)
+	if !ok {
+		return 0
+	}
+	deliveryCtx, cancel := context.WithTimeout(ctx, realtimeDeliveryTimeout)
+	defer cancel()
+
+	var delivered atomic.Int64
+	var waitGroup sync.WaitGroup
+	for _, subscriber := range subscribers {
+		waitGroup.Add(1)
+		go func(subscriber *realtimeSubscriber) {
+			defer waitGroup.Done()
+			if subscriber.deliverUntil(deliveryCtx.Done(), message) {
+				delivered.Add(1)
+			}
+		}(subscriber)
 	}
+	waitGroup.Wait()
+	return int(delivered.Load())
+}
+
+// prepare assigns the message identifier, retains it for replay and snapshots the user's subscribers.
+func (d *RealtimeDispatcher) prepare(message RealtimeMessage) (RealtimeMessage, []*realtimeSubscriber, bool) {
+	if message.UserID == "" || message.EventType == "" {
+		return message, nil, false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nextMessageID++
+	message.ID = d.nextMessageID
+	now := d.now()
+	d.evictIdleReplay(now)
+	d.remember(message, now)
+	subscribers := d.subscribers[message.UserID]
 	copies := make([]*realtimeSubscriber, 0, len(subscribers))
 	for _, subscriber := range subscribers {
 		copies = append(copies, subscriber)
 	}
-	d.mu.RUnlock()
-	for _, subscriber := range copies {
-		select {
-		case subscriber.stream <- message:
-		default:
+	return message, copies, true
+}
+
+// Replay returns the retained messages for a user with identifiers greater than afterID, oldest first.
+// When a positive afterID predates what the ring still holds, because messages were trimmed, the ring was
+// evicted or a restart reset the identifiers, the first replayed message is flagged for resync; if nothing
+// is left to replay a bare resync message is returned instead, so the client knows to perform a full pull.
+func (d *RealtimeDispatcher) Replay(userID string, afterID int64) []RealtimeMessage {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if afterID > d.nextMessageID {
+		// The identifier was issued before a restart reset the sequence.
+		return []RealtimeMessage{d.resyncMessage(userID)}
+	}
+	ring := d.recent[userID]
+	if ring == nil {
+		if afterID > 0 {
+			return []RealtimeMessage{d.resyncMessage(userID)}
 		}
+		return []RealtimeMessage{}
+	}
+	replay := make([]RealtimeMessage, 0, len(ring.messages))
+	for _, message := range ring.messages {
+		if message.ID > afterID {
+			replay = append(replay, message)
+		}
+	}
+	if afterID > 0 && afterID < ring.droppedID {
+		if len(replay) == 0 {
+			return []RealtimeMessage{d.resyncMessage(userID)}
+		}
+		replay[0].Resync = true
+	}
+	return replay
+}
+
+// resyncMessage tells a replaying client it missed messages. It carries the latest assigned identifier
+// so the client's Last-Event-ID moves past everything it was told to pull.
+func (d *RealtimeDispatcher) resyncMessage(userID string) RealtimeMessage {
+	return RealtimeMessage{
+		ID:        d.nextMessageID,
+		UserID:    userID,
+		EventType: RealtimeEventNoteChanged,
+		NoteIDs:   []string{},
+		Resync:    true,
+		Timestamp: d.now().UTC(),
 	}
 }
 
+// remember appends the message to its user's ring and drops the oldest messages until the ring fits both
+// the count and the byte cap. A single message larger than the byte cap is not retained.
+func (d *RealtimeDispatcher) remember(message RealtimeMessage, now time.Time) {
+	if d.recent == nil {
+		d.recent = make(map[string]*replayRing)
+	}
+	ring := d.recent[message.UserID]
+	if ring == nil {
+		// Earlier messages for the user may have lived in a ring evicted after the TTL, so a new ring
+		// treats everything before its first message as dropped.
+		ring = &replayRing{droppedID: message.ID - 1}
+		d.recent[message.UserID] = ring
+	}
+	ring.lastActive = now
+	ring.messages = append(ring.messages, message)
+	ring.bytes += realtimeMessageBytes(message)
+
+	replayBytes := d.replayBytes
+	if replayBytes <= 0 {
+		replayBytes = defaultRealtimeReplayBytes
+	}
+	overflow := 0
+	for overflow < len(ring.messages) && (len(ring.messages)-overflow > d.replaySize || ring.bytes > replayBytes) {
+		ring.bytes -= realtimeMessageBytes(ring.messages[overflow])
+		ring.droppedID = ring.messages[overflow].ID
+		overflow++
+	}
+	if overflow > 0 {
+		ring.messages = append([]RealtimeMessage(nil), ring.messages[overflow:]...)
+	}
+}
+
+// evictIdleReplay drops the rings of users without subscribers that have been idle for the replay TTL.
+// Like the rate limiter it sweeps at most once per TTL, so publishing stays cheap.
+func (d *RealtimeDispatcher) evictIdleReplay(now time.Time) {
+	replayTTL := d.replayTTL
+	if replayTTL <= 0 {
+		replayTTL = defaultRealtimeReplayTTL
+	}
+	if now.Sub(d.lastSweep) < replayTTL {
+		return
+	}
+	for userID, ring := range d.recent {
+		if len(d.subscribers[userID]) == 0 && now.Sub(ring.lastActive) >= replayTTL {
+			delete(d.recent, userID)
+		}
+	}
+	d.lastSweep = now
+}
+
+func (d *RealtimeDispatcher) now() time.Time {
+	if d.clock == nil {
+		return time.Now()
+	}
+	return d.clock()
+}
+
+// realtimeMessageBytes approximates a retained message's size by its variable-length payloads.
+func realtimeMessageBytes(message RealtimeMessage) int {
+	size := 0
+	for _, noteID := range message.NoteIDs {
+		size += len(noteID)
+	}
+	for _, update := range message.Updates {
+		size += len(update.NoteID) + len(update.UpdateB64)
+	}
+	return size
+}
+
+// CloseAll closes every subscriber stream so streaming handlers return and the server can shut down.
+// Later subscriptions receive an already closed stream.
+func (d *RealtimeDispatcher) CloseAll() {
+	d.mu.Lock()
+	d.closed = true
+	subscribers := d.subscribers
+	d.subscribers = make(map[string]map[int64]*realtimeSubscriber)
+	d.mu.Unlock()
+	for _, userSubscribers := range subscribers {
+		for _, subscriber := range userSubscribers {
+			subscriber.close()
+		}
+	}
+}
+
+// deliver enqueues a message without blocking. Dropped messages are counted and the next delivered
+// message is flagged for resync so the client knows to perform a full pull.
+func (s *realtimeSubscriber) deliver(message RealtimeMessage) bool {
+	return s.deliverUntil(nil, message)
+}
+
+// deliverUntil behaves like deliver but, when the buffer is full, waits for space until done is closed.
+// A nil done channel never waits. The wait happens outside s.mu so a stalled reader cannot hold up close
+// or other publishers; closing the subscriber releases the wait.
+func (s *realtimeSubscriber) deliverUntil(done <-chan struct{}, message RealtimeMessage) bool {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return false
+	}
+	dropped := s.dropped.Swap(0)
+	if dropped > 0 {
+		message.Resync = true
+	}
+	select {
+	case s.stream <- message:
+		s.mu.Unlock()
+		return true
+	default:
+	}
+	if done == nil {
+		s.dropped.Add(dropped + 1)
+		s.mu.Unlock()
+		return false
+	}
+	s.senders.Add(1)
+	s.mu.Unlock()
+	defer s.senders.Done()
+
+	select {
+	case s.stream <- message:
+		return true
+	case <-done:
+	case <-s.done:
+	}
+	s.dropped.Add(dropped + 1)
+	return false
+}
+
+// close marks the subscriber closed, releases blocked senders and closes the stream once they return.
+func (s *realtimeSubscriber) close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.done)
+	s.mu.Unlock()
+	s.senders.Wait()
+	close(s.stream)
+}
+
+// SubscriberCount returns the number of open subscriber streams across all users.
+func (d *RealtimeDispatcher) SubscriberCount() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	total := 0
+	for _, subscribers := range d.subscribers {
+		total += len(subscribers)
+	}
+	return total
+}
+
+// SubscriberCountForUser returns the number of open subscriber streams for one user.
+func (d *RealtimeDispatcher) SubscriberCountForUser(userID string) int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(d.subscribers[userID])
+}
+
 func (d *RealtimeDispatcher) nextSequence() int64 {
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -89,13 +402,17 @@ func (d *RealtimeDispatcher) nextSequence() int64 {
 	return d.nextID
 }
 
-func (d *RealtimeDispatcher) registerSubscriber(userID string, subscriber *realtimeSubscriber) {
+func (d *RealtimeDispatcher) registerSubscriber(userID string, subscriber *realtimeSubscriber) bool {
 	d.mu.Lock()
 	defer d.mu.Unlock()
+	if d.closed {
+		return false
+	}
 	if _, ok := d.subscribers[userID]; !ok {
 		d.subscribers[userID] = make(map[int64]*realtimeSubscriber)
 	}
 	d.subscribers[userID][subscriber.id] = subscriber
+	return true
 }
 
 func (d *RealtimeDispatcher) unregisterSubscriber(userID string, subscriberID int64) {
@@ -105,6 +422,11 @@ func (d *RealtimeDispatcher) unregisterSubscriber(userID string, subscriberID in
 		delete(subscribers, subscriberID)
 		if len(subscribers) == 0 {
 			delete(d.subscribers, userID)
+			now := d.now()
+			if ring := d.recent[userID]; ring != nil {
+				ring.lastActive = now
+			}
+			d.evictIdleReplay(now)
 		}
 	}
 	d.mu.Unlock()
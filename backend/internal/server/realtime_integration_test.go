@@ -4,8 +4,11 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -32,7 +35,7 @@ func TestRealtimeStreamEmitsNoteChangeEvents(testContext *testing.T) {
 	if err != nil {
 		testContext.Fatalf("failed to open in-memory database: %v", err)
 	}
-	if err := db.AutoMigrate(&notes.CrdtUpdate{}, &notes.CrdtSnapshot{}); err != nil {
+	if err := db.AutoMigrate(&notes.CrdtUpdate{}, &notes.CrdtSnapshot{}, &notes.NoteTag{}, &notes.NoteQuotaLock{}); err != nil {
 		testContext.Fatalf("failed to migrate schema: %v", err)
 	}
 
@@ -164,6 +167,374 @@ func TestRealtimeStreamEmitsNoteChangeEvents(testContext *testing.T) {
 	}
 }
 
+func TestRealtimeStreamClosesAtSessionExpiry(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+
+	expiresAt := time.Now().Add(2 * time.Second)
+	sessionToken := mustMintSessionTokenExpiringAt(testContext, sessionSigningSecret, sessionUserID, expiresAt)
+
+	streamRequest, err := http.NewRequest(http.MethodGet, server.URL+"/notes/stream?access_token="+sessionToken, http.NoBody)
+	if err != nil {
+		testContext.Fatalf("failed to construct stream request: %v", err)
+	}
+	streamResp, err := http.DefaultClient.Do(streamRequest)
+	if err != nil {
+		testContext.Fatalf("failed to open stream: %v", err)
+	}
+	testContext.Cleanup(func() {
+		_ = streamResp.Body.Close()
+	})
+	if streamResp.StatusCode != http.StatusOK {
+		testContext.Fatalf("unexpected stream status: %d", streamResp.StatusCode)
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.Discard, streamResp.Body)
+		closed <- err
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			testContext.Fatalf("stream closed with error: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		testContext.Fatal("expected stream to close once the session expired")
+	}
+}
+
+func TestRealtimeStreamSessionExpiryFollowsHandlerClock(testContext *testing.T) {
+	dispatcher := NewRealtimeDispatcher()
+	fakeNow := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	server := newIntegrationTestServerWithDependencies(testContext, Dependencies{
+		Realtime: dispatcher,
+		Clock:    func() time.Time { return fakeNow },
+	})
+
+	const sessionLifetime = time.Second
+	sessionToken := mustMintSessionTokenExpiringAt(testContext, sessionSigningSecret, sessionUserID, fakeNow.Add(sessionLifetime))
+
+	streamRequest, err := http.NewRequest(http.MethodGet, server.URL+"/notes/stream?ping=true&access_token="+sessionToken, http.NoBody)
+	if err != nil {
+		testContext.Fatalf("failed to construct stream request: %v", err)
+	}
+	openedAt := time.Now()
+	streamResp, err := http.DefaultClient.Do(streamRequest)
+	if err != nil {
+		testContext.Fatalf("failed to open stream: %v", err)
+	}
+	testContext.Cleanup(func() {
+		_ = streamResp.Body.Close()
+	})
+	if streamResp.StatusCode != http.StatusOK {
+		testContext.Fatalf("unexpected stream status: %d", streamResp.StatusCode)
+	}
+
+	streamReader := bufio.NewReader(streamResp.Body)
+	mustReadRealtimeEvent(testContext, streamReader, realtimeEventHeartbeat)
+	closed := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(io.Discard, streamReader)
+		closed <- err
+	}()
+	select {
+	case err := <-closed:
+		if err != nil {
+			testContext.Fatalf("stream closed with error: %v", err)
+		}
+		if elapsed := time.Since(openedAt); elapsed < sessionLifetime/2 {
+			testContext.Fatalf("expected the stream to stay open until the session expired on the handler clock, closed after %s", elapsed)
+		}
+	case <-time.After(10 * time.Second):
+		testContext.Fatal("expected stream to close once the session expired on the handler clock")
+	}
+}
+
+func TestRealtimeStreamPingHandshakeEmitsImmediateHeartbeat(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	streamRequest, err := http.NewRequest(http.MethodGet, server.URL+"/notes/stream?ping=true&access_token="+sessionToken, http.NoBody)
+	if err != nil {
+		testContext.Fatalf("failed to construct stream request: %v", err)
+	}
+	streamResp, err := http.DefaultClient.Do(streamRequest)
+	if err != nil {
+		testContext.Fatalf("failed to open stream: %v", err)
+	}
+	testContext.Cleanup(func() {
+		_ = streamResp.Body.Close()
+	})
+	if streamResp.StatusCode != http.StatusOK {
+		testContext.Fatalf("unexpected stream status: %d", streamResp.StatusCode)
+	}
+
+	// The regular heartbeat ticks every 25s; mustReadRealtimeEvent gives up after 5s.
+	var heartbeat struct {
+		Source string `json:"source"`
+	}
+	dataJSON := mustReadRealtimeEvent(testContext, bufio.NewReader(streamResp.Body), realtimeEventHeartbeat)
+	if err := json.Unmarshal([]byte(dataJSON), &heartbeat); err != nil {
+		testContext.Fatalf("failed to decode heartbeat payload: %v", err)
+	}
+	if heartbeat.Source != realtimeSourceBackend {
+		testContext.Fatalf("unexpected heartbeat source: %q", heartbeat.Source)
+	}
+}
+
+func TestRealtimeStreamCarriesPushedCrdtUpdatesInline(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	streamRequest, err := http.NewRequest(http.MethodGet, server.URL+"/notes/stream?access_token="+sessionToken, http.NoBody)
+	if err != nil {
+		testContext.Fatalf("failed to construct stream request: %v", err)
+	}
+	streamResp, err := http.DefaultClient.Do(streamRequest)
+	if err != nil {
+		testContext.Fatalf("failed to open stream: %v", err)
+	}
+	testContext.Cleanup(func() {
+		_ = streamResp.Body.Close()
+	})
+	if streamResp.StatusCode != http.StatusOK {
+		testContext.Fatalf("unexpected stream status: %d", streamResp.StatusCode)
+	}
+	streamReader := bufio.NewReader(streamResp.Body)
+
+	pushBody := map[string]any{
+		"protocol": crdtProtocolVersion,
+		"updates": []map[string]any{
+			{"note_id": sessionNoteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0},
+		},
+	}
+	var pushPayload struct {
+		Results []struct {
+			UpdateID int64 `json:"update_id"`
+		} `json:"results"`
+	}
+	mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/push", sessionToken, pushBody, &pushPayload)
+	if len(pushPayload.Results) != 1 {
+		testContext.Fatalf("unexpected push response: %#v", pushPayload)
+	}
+
+	var event struct {
+		NoteIDs []string             `json:"noteIds"`
+		Updates []RealtimeCrdtUpdate `json:"updates"`
+	}
+	dataJSON := mustReadRealtimeEvent(testContext, streamReader, RealtimeEventNoteChanged)
+	if err := json.Unmarshal([]byte(dataJSON), &event); err != nil {
+		testContext.Fatalf("failed to decode event payload: %v", err)
+	}
+	if len(event.NoteIDs) != 1 || event.NoteIDs[0] != sessionNoteID {
+		testContext.Fatalf("unexpected note identifiers: %#v", event.NoteIDs)
+	}
+	expected := RealtimeCrdtUpdate{NoteID: sessionNoteID, UpdateID: pushPayload.Results[0].UpdateID, UpdateB64: crdtPushUpdateB64}
+	if len(event.Updates) != 1 || event.Updates[0] != expected {
+		testContext.Fatalf("expected inline update %#v, got %#v", expected, event.Updates)
+	}
+}
+
+func TestRealtimeStreamReplaysMissedEventsAfterLastEventID(testContext *testing.T) {
+	dispatcher := NewRealtimeDispatcher()
+	server := newIntegrationTestServer(testContext, dispatcher)
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	publish := func(noteID string) {
+		dispatcher.Publish(RealtimeMessage{
+			UserID:    sessionUserID,
+			EventType: RealtimeEventNoteChanged,
+			NoteIDs:   []string{noteID},
+			Timestamp: time.Now().UTC(),
+		})
+	}
+	publish("note-seen")
+	seenID := dispatcher.Replay(sessionUserID, 0)[0].ID
+	publish("note-missed")
+
+	streamRequest, err := http.NewRequest(http.MethodGet, server.URL+"/notes/stream?access_token="+sessionToken, http.NoBody)
+	if err != nil {
+		testContext.Fatalf("failed to construct stream request: %v", err)
+	}
+	streamRequest.Header.Set(lastEventIDHeader, strconv.FormatInt(seenID, 10))
+	streamResp, err := http.DefaultClient.Do(streamRequest)
+	if err != nil {
+		testContext.Fatalf("failed to open stream: %v", err)
+	}
+	testContext.Cleanup(func() {
+		_ = streamResp.Body.Close()
+	})
+	if streamResp.StatusCode != http.StatusOK {
+		testContext.Fatalf("unexpected stream status: %d", streamResp.StatusCode)
+	}
+
+	var event struct {
+		NoteIDs []string `json:"noteIds"`
+	}
+	dataJSON := mustReadRealtimeEvent(testContext, bufio.NewReader(streamResp.Body), RealtimeEventNoteChanged)
+	if err := json.Unmarshal([]byte(dataJSON), &event); err != nil {
+		testContext.Fatalf("failed to decode event payload: %v", err)
+	}
+	if len(event.NoteIDs) != 1 || event.NoteIDs[0] != "note-missed" {
+		testContext.Fatalf("expected missed event to be replayed first, got %#v", event.NoteIDs)
+	}
+}
+
+func TestRealtimeStreamDeliversOutOfOrderLiveEvents(testContext *testing.T) {
+	dispatcher := NewRealtimeDispatcher()
+	server := newIntegrationTestServer(testContext, dispatcher)
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	streamRequest, err := http.NewRequest(http.MethodGet, server.URL+"/notes/stream?ping=true&access_token="+sessionToken, http.NoBody)
+	if err != nil {
+		testContext.Fatalf("failed to construct stream request: %v", err)
+	}
+	streamResp, err := http.DefaultClient.Do(streamRequest)
+	if err != nil {
+		testContext.Fatalf("failed to open stream: %v", err)
+	}
+	testContext.Cleanup(func() {
+		_ = streamResp.Body.Close()
+	})
+	if streamResp.StatusCode != http.StatusOK {
+		testContext.Fatalf("unexpected stream status: %d", streamResp.StatusCode)
+	}
+	streamReader := bufio.NewReader(streamResp.Body)
+	mustReadRealtimeEvent(testContext, streamReader, realtimeEventHeartbeat)
+
+	// Concurrent publishes are numbered under the dispatcher lock but delivered after it, so a subscriber
+	// can receive a newer identifier before an older one.
+	dispatcher.mu.RLock()
+	var subscriber *realtimeSubscriber
+	for _, candidate := range dispatcher.subscribers[sessionUserID] {
+		subscriber = candidate
+	}
+	dispatcher.mu.RUnlock()
+	if subscriber == nil {
+		testContext.Fatal("expected the stream to be subscribed")
+	}
+	for _, messageID := range []int64{5, 4} {
+		subscriber.deliver(RealtimeMessage{
+			ID:        messageID,
+			UserID:    sessionUserID,
+			EventType: RealtimeEventNoteChanged,
+			NoteIDs:   []string{"note-" + strconv.FormatInt(messageID, 10)},
+			Timestamp: time.Now().UTC(),
+		})
+	}
+
+	for _, expectedNoteID := range []string{"note-5", "note-4"} {
+		var event struct {
+			NoteIDs []string `json:"noteIds"`
+		}
+		dataJSON := mustReadRealtimeEvent(testContext, streamReader, RealtimeEventNoteChanged)
+		if err := json.Unmarshal([]byte(dataJSON), &event); err != nil {
+			testContext.Fatalf("failed to decode event payload: %v", err)
+		}
+		if len(event.NoteIDs) != 1 || event.NoteIDs[0] != expectedNoteID {
+			testContext.Fatalf("expected %s to be delivered, got %#v", expectedNoteID, event.NoteIDs)
+		}
+	}
+}
+
+func mustReadRealtimeEvent(testContext *testing.T, streamReader *bufio.Reader, eventType string) string {
+	testContext.Helper()
+	type readResult struct {
+		line string
+		err  error
+	}
+	currentEventType := ""
+	deadline := time.After(5 * time.Second)
+	for {
+		resultCh := make(chan readResult, 1)
+		go func() {
+			line, err := streamReader.ReadString('\n')
+			resultCh <- readResult{line: line, err: err}
+		}()
+		select {
+		case <-deadline:
+			testContext.Fatalf("timed out waiting for %s event", eventType)
+		case res := <-resultCh:
+			if res.err != nil {
+				testContext.Fatalf("failed to read stream: %v", res.err)
+			}
+			line := strings.TrimSpace(res.line)
+			if strings.HasPrefix(line, "event:") {
+				currentEventType = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+				continue
+			}
+			if strings.HasPrefix(line, "data:") && currentEventType == eventType {
+				return strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			}
+		}
+	}
+}
+
+func newIntegrationTestServer(testContext *testing.T, dispatcher *RealtimeDispatcher) *httptest.Server {
+	testContext.Helper()
+	return newIntegrationTestServerWithDependencies(testContext, Dependencies{Realtime: dispatcher})
+}
+
+// newIntegrationTestServerWithDependencies fills in storage, session validation, and logging around the provided dependencies.
+func newIntegrationTestServerWithDependencies(testContext *testing.T, deps Dependencies) *httptest.Server {
+	testContext.Helper()
+	db, err := gorm.Open(githubsqlite.Open(filepath.Join(testContext.TempDir(), "integration.db")), &gorm.Config{})
+	if err != nil {
+		testContext.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&notes.CrdtUpdate{}, &notes.CrdtSnapshot{}, &notes.NoteTag{}, &notes.NoteQuotaLock{}); err != nil {
+		testContext.Fatalf("failed to migrate schema: %v", err)
+	}
+	noteService, err := notes.NewService(notes.ServiceConfig{
+		Database:       db,
+		Logger:         zap.NewNop(),
+		TracerProvider: deps.TracerProvider,
+		Observer:       deps.Metrics.NotesObserver(),
+	})
+	if err != nil {
+		testContext.Fatalf("failed to construct notes service: %v", err)
+	}
+	sessionValidator, err := auth.NewSessionValidator(auth.SessionValidatorConfig{
+		SigningSecret: []byte(sessionSigningSecret),
+		CookieName:    sessionCookieName,
+		Clock:         deps.Clock,
+	})
+	if err != nil {
+		testContext.Fatalf("failed to construct session validator: %v", err)
+	}
+	deps.SessionValidator = sessionValidator
+	deps.SessionCookie = sessionCookieName
+	deps.NotesService = noteService
+	if deps.Logger == nil {
+		deps.Logger = zap.NewNop()
+	}
+	handler, err := NewHTTPHandler(deps)
+	if err != nil {
+		testContext.Fatalf("failed to construct http handler: %v", err)
+	}
+	server := httptest.NewServer(handler)
+	testContext.Cleanup(server.Close)
+	return server
+}
+
+func mustMintSessionTokenExpiringAt(testContext *testing.T, signingSecret, userID string, expiresAt time.Time) string {
+	testContext.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.SessionClaims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    sessionIssuer,
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+	signed, err := token.SignedString([]byte(signingSecret))
+	if err != nil {
+		testContext.Fatalf("failed to sign session token: %v", err)
+	}
+	return signed
+}
+
 func mustMintSessionToken(testContext *testing.T, signingSecret, userID string, now time.Time) string {
 	testContext.Helper()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.SessionClaims{
@@ -71,3 +71,419 @@ func TestRealtimeDispatcherIsolatedByUser(t *testing.T) {
 		t.Fatal("expected realtime message for subscribed user")
 	}
 }
+
+func TestRealtimeDispatcherFlagsResyncAfterDrops(t *testing.T) {
+	const bufferSize = 2
+	dispatcher := NewRealtimeDispatcherWithOptions(bufferSize)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, cleanup := dispatcher.Subscribe(ctx, "user-overflow")
+	defer cleanup()
+
+	for index := 0; index < bufferSize+3; index++ {
+		dispatcher.Publish(RealtimeMessage{
+			UserID:    "user-overflow",
+			EventType: RealtimeEventNoteChanged,
+			NoteIDs:   []string{"note-overflow"},
+			Timestamp: time.Now().UTC(),
+		})
+	}
+
+	for index := 0; index < bufferSize; index++ {
+		received := <-stream
+		if received.Resync {
+			t.Fatalf("did not expect buffered message %d to carry a resync hint", index)
+		}
+	}
+
+	dispatcher.Publish(RealtimeMessage{
+		UserID:    "user-overflow",
+		EventType: RealtimeEventNoteChanged,
+		NoteIDs:   []string{"note-after-drop"},
+		Timestamp: time.Now().UTC(),
+	})
+
+	select {
+	case received := <-stream:
+		if !received.Resync {
+			t.Fatal("expected the first message after drops to carry a resync hint")
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("expected realtime message after drain")
+	}
+
+	dispatcher.Publish(RealtimeMessage{
+		UserID:    "user-overflow",
+		EventType: RealtimeEventNoteChanged,
+		NoteIDs:   []string{"note-steady"},
+		Timestamp: time.Now().UTC(),
+	})
+	select {
+	case received := <-stream:
+		if received.Resync {
+			t.Fatal("expected resync hint to clear once delivered")
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("expected steady realtime message")
+	}
+}
+
+func TestRealtimeDispatcherReplayIsBoundedAndOrdered(t *testing.T) {
+	const replaySize = 3
+	dispatcher := NewRealtimeDispatcherWithReplay(defaultRealtimeBufferSize, replaySize)
+
+	for index := 0; index < replaySize+2; index++ {
+		dispatcher.Publish(RealtimeMessage{
+			UserID:    "user-replay",
+			EventType: RealtimeEventNoteChanged,
+			NoteIDs:   []string{"note-replay"},
+			Timestamp: time.Now().UTC(),
+		})
+	}
+
+	replayed := dispatcher.Replay("user-replay", 0)
+	if len(replayed) != replaySize {
+		t.Fatalf("expected %d retained messages, got %d", replaySize, len(replayed))
+	}
+	for index := 1; index < len(replayed); index++ {
+		if replayed[index].ID <= replayed[index-1].ID {
+			t.Fatalf("expected increasing message ids, got %d after %d", replayed[index].ID, replayed[index-1].ID)
+		}
+	}
+
+	newest := replayed[len(replayed)-1].ID
+	if remaining := dispatcher.Replay("user-replay", newest-1); len(remaining) != 1 || remaining[0].ID != newest {
+		t.Fatalf("expected only the newest message after id %d, got %#v", newest-1, remaining)
+	}
+	if other := dispatcher.Replay("user-other", 0); len(other) != 0 {
+		t.Fatalf("expected no replay for unrelated user, got %d", len(other))
+	}
+}
+
+func TestRealtimeDispatcherReplayFlagsResyncWhenRingOverflowed(t *testing.T) {
+	const replaySize = 3
+	dispatcher := NewRealtimeDispatcherWithReplay(defaultRealtimeBufferSize, replaySize)
+	publish := func(userID string, updateB64 string) {
+		dispatcher.Publish(RealtimeMessage{
+			UserID:    userID,
+			EventType: RealtimeEventNoteChanged,
+			Updates:   []RealtimeCrdtUpdate{{NoteID: "note-overflow", UpdateB64: updateB64}},
+			Timestamp: time.Now().UTC(),
+		})
+	}
+
+	publish("user-overflow", "AQ==")
+	seenID := dispatcher.Replay("user-overflow", 0)[0].ID
+	for index := 0; index < replaySize+1; index++ {
+		publish("user-overflow", "AQ==")
+	}
+
+	replayed := dispatcher.Replay("user-overflow", seenID)
+	if len(replayed) != replaySize {
+		t.Fatalf("expected %d retained messages, got %d", replaySize, len(replayed))
+	}
+	if !replayed[0].Resync {
+		t.Fatalf("expected the first replayed message to flag resync after the ring overflowed")
+	}
+	for _, message := range replayed[1:] {
+		if message.Resync {
+			t.Fatalf("expected only the first replayed message to flag resync, got %#v", message)
+		}
+	}
+	if current := dispatcher.Replay("user-overflow", replayed[0].ID-1); len(current) != replaySize || current[0].Resync {
+		t.Fatalf("expected no resync when nothing after the last event id was dropped, got %#v", current)
+	}
+
+	dispatcher.replayBytes = 8
+	publish("user-overflow", "AQIDBAUGBwgJCgsM")
+	newest := replayed[len(replayed)-1].ID
+	resync := dispatcher.Replay("user-overflow", newest)
+	if len(resync) != 1 || !resync[0].Resync || resync[0].ID <= newest {
+		t.Fatalf("expected a bare resync message past the skipped message, got %#v", resync)
+	}
+
+	if missing := dispatcher.Replay("user-unknown", seenID); len(missing) != 1 || !missing[0].Resync {
+		t.Fatalf("expected a resync message when the ring is gone, got %#v", missing)
+	}
+	if fresh := dispatcher.Replay("user-unknown", 0); len(fresh) != 0 {
+		t.Fatalf("expected no replay for a stream without a last event id, got %#v", fresh)
+	}
+}
+
+func TestRealtimeDispatcherReplayFlagsResyncAfterEvictionAndRestart(t *testing.T) {
+	dispatcher := NewRealtimeDispatcher()
+	currentTime := time.Unix(1700000000, 0)
+	dispatcher.clock = func() time.Time { return currentTime }
+	dispatcher.lastSweep = currentTime
+	publish := func(dispatcher *RealtimeDispatcher, userID string) {
+		dispatcher.Publish(RealtimeMessage{UserID: userID, EventType: RealtimeEventNoteChanged, NoteIDs: []string{"note-evicted"}, Timestamp: currentTime})
+	}
+
+	publish(dispatcher, "user-evicted")
+	seenID := dispatcher.Replay("user-evicted", 0)[0].ID
+	publish(dispatcher, "user-evicted")
+	currentTime = currentTime.Add(defaultRealtimeReplayTTL)
+	publish(dispatcher, "user-trigger")
+	publish(dispatcher, "user-evicted")
+
+	replayed := dispatcher.Replay("user-evicted", seenID)
+	if len(replayed) != 1 || !replayed[0].Resync {
+		t.Fatalf("expected the message after an evicted ring to flag resync, got %#v", replayed)
+	}
+	if current := dispatcher.Replay("user-evicted", replayed[0].ID-1); len(current) != 1 || current[0].Resync {
+		t.Fatalf("expected no resync when the client saw everything before the new ring, got %#v", current)
+	}
+
+	restarted := NewRealtimeDispatcher()
+	if resync := restarted.Replay("user-evicted", seenID); len(resync) != 1 || !resync[0].Resync {
+		t.Fatalf("expected a resync message for an identifier issued before the restart, got %#v", resync)
+	}
+	publish(restarted, "user-evicted")
+	lastBeforeRestart := replayed[0].ID
+	resync := restarted.Replay("user-evicted", lastBeforeRestart)
+	if len(resync) != 1 || !resync[0].Resync || resync[0].ID >= lastBeforeRestart {
+		t.Fatalf("expected a resync message carrying the restarted sequence, got %#v", resync)
+	}
+}
+
+func TestRealtimeDispatcherReplayIsBoundedByBytes(t *testing.T) {
+	dispatcher := NewRealtimeDispatcher()
+	dispatcher.replayBytes = 10
+	payload := RealtimeCrdtUpdate{NoteID: "n", UpdateB64: "AQID"}
+
+	for index := 0; index < 4; index++ {
+		dispatcher.Publish(RealtimeMessage{
+			UserID:    "user-replay-bytes",
+			EventType: RealtimeEventNoteChanged,
+			Updates:   []RealtimeCrdtUpdate{payload},
+			Timestamp: time.Now().UTC(),
+		})
+	}
+	if replayed := dispatcher.Replay("user-replay-bytes", 0); len(replayed) != 2 || replayed[1].ID != 4 {
+		t.Fatalf("expected the two newest messages within the byte cap, got %#v", replayed)
+	}
+
+	dispatcher.Publish(RealtimeMessage{
+		UserID:    "user-replay-bytes",
+		EventType: RealtimeEventNoteChanged,
+		Updates:   []RealtimeCrdtUpdate{{NoteID: "n", UpdateB64: "AQIDBAUGBwgJCgsM"}},
+		Timestamp: time.Now().UTC(),
+	})
+	if replayed := dispatcher.Replay("user-replay-bytes", 0); len(replayed) != 0 {
+		t.Fatalf("expected an oversized message to empty the ring, got %d messages", len(replayed))
+	}
+}
+
+func TestRealtimeDispatcherEvictsIdleReplayRings(t *testing.T) {
+	dispatcher := NewRealtimeDispatcher()
+	currentTime := time.Unix(1700000000, 0)
+	dispatcher.clock = func() time.Time { return currentTime }
+	dispatcher.lastSweep = currentTime
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	publish := func(userID string) {
+		dispatcher.Publish(RealtimeMessage{UserID: userID, EventType: RealtimeEventNoteChanged, NoteIDs: []string{"note-idle"}, Timestamp: currentTime})
+	}
+	_, cleanup := dispatcher.Subscribe(ctx, "user-subscribed")
+	publish("user-subscribed")
+	_, cleanupLeaving := dispatcher.Subscribe(ctx, "user-leaving")
+	publish("user-leaving")
+	publish("user-offline")
+
+	currentTime = currentTime.Add(defaultRealtimeReplayTTL / 2)
+	cleanupLeaving()
+
+	currentTime = currentTime.Add(defaultRealtimeReplayTTL / 2)
+	publish("user-trigger")
+	if replayed := dispatcher.Replay("user-offline", 0); len(replayed) != 0 {
+		t.Fatalf("expected the idle ring without subscribers to be evicted, got %d messages", len(replayed))
+	}
+	if replayed := dispatcher.Replay("user-leaving", 0); len(replayed) != 1 {
+		t.Fatalf("expected the ring to outlive its last subscriber for the TTL, got %d messages", len(replayed))
+	}
+	if replayed := dispatcher.Replay("user-subscribed", 0); len(replayed) != 1 {
+		t.Fatalf("expected a subscribed user's ring to be kept, got %d messages", len(replayed))
+	}
+
+	currentTime = currentTime.Add(defaultRealtimeReplayTTL)
+	publish("user-trigger")
+	if replayed := dispatcher.Replay("user-leaving", 0); len(replayed) != 0 {
+		t.Fatalf("expected the ring to be evicted a TTL after its last subscriber left, got %d messages", len(replayed))
+	}
+	if replayed := dispatcher.Replay("user-subscribed", 0); len(replayed) != 1 {
+		t.Fatalf("expected a subscribed user's ring to survive the sweep, got %d messages", len(replayed))
+	}
+	cleanup()
+}
+
+func TestRealtimeDispatcherPublishContextCountsDeliveries(t *testing.T) {
+	const bufferSize = 1
+	dispatcher := NewRealtimeDispatcherWithOptions(bufferSize)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fullStream, cleanupFull := dispatcher.Subscribe(ctx, "user-publish-context")
+	defer cleanupFull()
+	dispatcher.Publish(RealtimeMessage{
+		UserID:    "user-publish-context",
+		EventType: RealtimeEventNoteChanged,
+		NoteIDs:   []string{"note-fill"},
+		Timestamp: time.Now().UTC(),
+	})
+	emptyStream, cleanupEmpty := dispatcher.Subscribe(ctx, "user-publish-context")
+	defer cleanupEmpty()
+
+	publishCtx, publishCancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer publishCancel()
+	delivered := dispatcher.PublishContext(publishCtx, RealtimeMessage{
+		UserID:    "user-publish-context",
+		EventType: RealtimeEventNoteChanged,
+		NoteIDs:   []string{"note-context"},
+		Timestamp: time.Now().UTC(),
+	})
+	if delivered != 1 {
+		t.Fatalf("expected delivery to only the empty subscriber, got %d", delivered)
+	}
+
+	select {
+	case received := <-emptyStream:
+		if len(received.NoteIDs) != 1 || received.NoteIDs[0] != "note-context" {
+			t.Fatalf("unexpected message for empty subscriber: %#v", received)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("expected message for empty subscriber")
+	}
+	if received := <-fullStream; received.NoteIDs[0] != "note-fill" {
+		t.Fatalf("expected full subscriber to keep only the earlier message, got %#v", received)
+	}
+
+	if delivered := dispatcher.PublishContext(context.Background(), RealtimeMessage{
+		UserID:    "user-publish-context",
+		EventType: RealtimeEventNoteChanged,
+		NoteIDs:   []string{"note-after-drain"},
+		Timestamp: time.Now().UTC(),
+	}); delivered != 2 {
+		t.Fatalf("expected delivery to both drained subscribers, got %d", delivered)
+	}
+	if received := <-fullStream; !received.Resync {
+		t.Fatal("expected the subscriber that missed a message to receive a resync hint")
+	}
+}
+
+func TestRealtimeDispatcherCloseReleasesBlockedPublish(t *testing.T) {
+	const bufferSize = 1
+	dispatcher := NewRealtimeDispatcherWithOptions(bufferSize)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, cleanup := dispatcher.Subscribe(ctx, "user-blocked")
+	defer cleanup()
+	dispatcher.Publish(RealtimeMessage{UserID: "user-blocked", EventType: RealtimeEventNoteChanged, NoteIDs: []string{"note-fill"}})
+
+	started := time.Now()
+	result := make(chan int, 1)
+	go func() {
+		result <- dispatcher.PublishContext(context.Background(), RealtimeMessage{
+			UserID:    "user-blocked",
+			EventType: RealtimeEventNoteChanged,
+			NoteIDs:   []string{"note-blocked"},
+		})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	dispatcher.Publish(RealtimeMessage{UserID: "user-blocked", EventType: RealtimeEventNoteChanged, NoteIDs: []string{"note-dropped"}})
+	dispatcher.CloseAll()
+
+	select {
+	case delivered := <-result:
+		if delivered != 0 {
+			t.Fatalf("expected no delivery to the closed subscriber, got %d", delivered)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the blocked publish to return")
+	}
+	if elapsed := time.Since(started); elapsed >= realtimeDeliveryTimeout {
+		t.Fatalf("expected closing the subscriber to release the blocked publish early, took %s", elapsed)
+	}
+	if received := <-stream; received.NoteIDs[0] != "note-fill" {
+		t.Fatalf("unexpected buffered message: %#v", received)
+	}
+	if _, ok := <-stream; ok {
+		t.Fatal("expected the subscriber stream to be closed")
+	}
+}
+
+func TestRealtimeDispatcherSubscriberCounts(t *testing.T) {
+	dispatcher := NewRealtimeDispatcher()
+	firstCtx, firstCancel := context.WithCancel(context.Background())
+	defer firstCancel()
+	secondCtx, secondCancel := context.WithCancel(context.Background())
+	defer secondCancel()
+	otherCtx, otherCancel := context.WithCancel(context.Background())
+	defer otherCancel()
+
+	_, cleanupFirst := dispatcher.Subscribe(firstCtx, "user-count")
+	defer cleanupFirst()
+	_, cleanupSecond := dispatcher.Subscribe(secondCtx, "user-count")
+	defer cleanupSecond()
+	_, cleanupOther := dispatcher.Subscribe(otherCtx, "user-count-other")
+	defer cleanupOther()
+
+	if count := dispatcher.SubscriberCount(); count != 3 {
+		t.Fatalf("expected 3 subscribers, got %d", count)
+	}
+	if count := dispatcher.SubscriberCountForUser("user-count"); count != 2 {
+		t.Fatalf("expected 2 subscribers for user-count, got %d", count)
+	}
+	if count := dispatcher.SubscriberCountForUser("user-unknown"); count != 0 {
+		t.Fatalf("expected no subscribers for unknown user, got %d", count)
+	}
+
+	firstCancel()
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for dispatcher.SubscriberCountForUser("user-count") != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected cancelled subscription to be removed, still %d", dispatcher.SubscriberCountForUser("user-count"))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if count := dispatcher.SubscriberCount(); count != 2 {
+		t.Fatalf("expected 2 subscribers after cancel, got %d", count)
+	}
+}
+
+func TestRealtimeDispatcherCloseAllClosesSubscriberStreams(t *testing.T) {
+	dispatcher := NewRealtimeDispatcher()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first, cleanupFirst := dispatcher.Subscribe(ctx, "user-1")
+	defer cleanupFirst()
+	second, cleanupSecond := dispatcher.Subscribe(ctx, "user-2")
+	defer cleanupSecond()
+
+	dispatcher.CloseAll()
+
+	for _, stream := range []<-chan RealtimeMessage{first, second} {
+		select {
+		case _, ok := <-stream:
+			if ok {
+				t.Fatal("expected subscriber stream to be closed")
+			}
+		case <-time.After(500 * time.Millisecond):
+			t.Fatal("expected subscriber stream to close within deadline")
+		}
+	}
+	if count := dispatcher.SubscriberCount(); count != 0 {
+		t.Fatalf("expected no active subscribers after CloseAll, got %d", count)
+	}
+
+	dispatcher.Publish(RealtimeMessage{UserID: "user-1", EventType: RealtimeEventNoteChanged})
+
+	late, cleanupLate := dispatcher.Subscribe(ctx, "user-1")
+	defer cleanupLate()
+	if _, ok := <-late; ok {
+		t.Fatal("expected subscriptions after CloseAll to be closed immediately")
+	}
+}
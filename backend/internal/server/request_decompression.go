@@ -0,0 +1,56 @@
+package server
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	deflateEncoding  = "deflate"
+	identityEncoding = "identity"
+)
+
+// decompressRequestMiddleware decodes gzip and deflate request bodies before handlers bind them.
+// The compressed body stays under the global body limit, and the decoded body gets the same limit so a
+// small archive cannot expand without bound; bindJSON answers 413 when either is exceeded.
+// Other encodings are refused with 415.
+func decompressRequestMiddleware(maxBytes int64) gin.HandlerFunc {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxRequestBodyBytes
+	}
+	return func(c *gin.Context) {
+		encoding := strings.ToLower(strings.TrimSpace(c.GetHeader(contentEncodingHeader)))
+		if encoding == "" || encoding == identityEncoding || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+
+		var decoded io.ReadCloser
+		var err error
+		switch encoding {
+		case gzipEncoding:
+			decoded, err = gzip.NewReader(c.Request.Body)
+		case deflateEncoding:
+			decoded, err = zlib.NewReader(c.Request.Body)
+		default:
+			abortWithError(c, http.StatusUnsupportedMediaType, "unsupported_encoding")
+			return
+		}
+		if err != nil {
+			abortWithError(c, http.StatusBadRequest, "invalid_request")
+			return
+		}
+		defer decoded.Close()
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, decoded, maxBytes)
+		c.Request.Header.Del(contentEncodingHeader)
+		c.Request.Header.Del(contentLengthHeader)
+		c.Request.ContentLength = -1
+		c.Next()
+	}
+}
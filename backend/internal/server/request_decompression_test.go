@@ -0,0 +1,143 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecompressRequestMiddlewareDecodesSyncBodies(t *testing.T) {
+	body, err := json.Marshal(map[string]any{
+		"protocol": crdtProtocolVersion,
+		"updates": []map[string]any{
+			{"note_id": sessionNoteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0},
+		},
+		"cursors": []map[string]any{{"note_id": sessionNoteID, "last_update_id": 0}},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode request: %v", err)
+	}
+
+	var gzipBody bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipBody)
+	if _, err := gzipWriter.Write(body); err != nil {
+		t.Fatalf("failed to gzip request: %v", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatalf("failed to gzip request: %v", err)
+	}
+	var deflateBody bytes.Buffer
+	deflateWriter := zlib.NewWriter(&deflateBody)
+	if _, err := deflateWriter.Write(body); err != nil {
+		t.Fatalf("failed to deflate request: %v", err)
+	}
+	if err := deflateWriter.Close(); err != nil {
+		t.Fatalf("failed to deflate request: %v", err)
+	}
+
+	postSync := func(t *testing.T, encoding string, payload []byte) (int, []byte) {
+		t.Helper()
+		server := newIntegrationTestServer(t, NewRealtimeDispatcher())
+		sessionToken := mustMintSessionToken(t, sessionSigningSecret, sessionUserID, time.Now())
+		request, err := http.NewRequest(http.MethodPost, server.URL+"/notes/sync", bytes.NewReader(payload))
+		if err != nil {
+			t.Fatalf("failed to construct request: %v", err)
+		}
+		request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+		request.Header.Set("Content-Type", jsonContentType)
+		if encoding != "" {
+			request.Header.Set(contentEncodingHeader, encoding)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer response.Body.Close()
+		responseBody, err := io.ReadAll(response.Body)
+		if err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		return response.StatusCode, responseBody
+	}
+
+	plainStatus, plainBody := postSync(t, "", body)
+	if plainStatus != http.StatusOK {
+		t.Fatalf("expected uncompressed sync to succeed, got %d: %s", plainStatus, plainBody)
+	}
+
+	testCases := []struct {
+		name           string
+		encoding       string
+		payload        []byte
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "gzip", encoding: gzipEncoding, payload: gzipBody.Bytes(), expectedStatus: http.StatusOK},
+		{name: "deflate", encoding: deflateEncoding, payload: deflateBody.Bytes(), expectedStatus: http.StatusOK},
+		{name: "identity", encoding: identityEncoding, payload: body, expectedStatus: http.StatusOK},
+		{name: "corrupt-gzip", encoding: gzipEncoding, payload: body, expectedStatus: http.StatusBadRequest, expectedError: "invalid_request"},
+		{name: "unknown-encoding", encoding: "br", payload: body, expectedStatus: http.StatusUnsupportedMediaType, expectedError: "unsupported_encoding"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			status, responseBody := postSync(t, testCase.encoding, testCase.payload)
+			if status != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", testCase.expectedStatus, status, responseBody)
+			}
+			if testCase.expectedError == "" {
+				if !bytes.Equal(responseBody, plainBody) {
+					t.Fatalf("expected response to match uncompressed sync:\n got %s\nwant %s", responseBody, plainBody)
+				}
+				return
+			}
+			var payload map[string]any
+			if err := json.Unmarshal(responseBody, &payload); err != nil {
+				t.Fatalf("failed to decode error response: %v", err)
+			}
+			if payload["error"] != testCase.expectedError {
+				t.Fatalf("unexpected error payload: %#v", payload)
+			}
+		})
+	}
+}
+
+func TestDecompressRequestMiddlewareBoundsDecodedSize(t *testing.T) {
+	const maxRequestBodyBytes = 512
+	server := newIntegrationTestServerWithDependencies(t, Dependencies{MaxRequestBodyBytes: maxRequestBodyBytes})
+	sessionToken := mustMintSessionToken(t, sessionSigningSecret, sessionUserID, time.Now())
+
+	expanding := []byte(`{"protocol":"` + crdtProtocolVersion + `","updates":[],"padding":"` + strings.Repeat("x", 64*maxRequestBodyBytes) + `"}`)
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(expanding); err != nil {
+		t.Fatalf("failed to gzip request: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to gzip request: %v", err)
+	}
+	if compressed.Len() >= maxRequestBodyBytes {
+		t.Fatalf("expected compressed body under the limit, got %d bytes", compressed.Len())
+	}
+
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/notes/crdt/push", &compressed)
+	if err != nil {
+		t.Fatalf("failed to construct request: %v", err)
+	}
+	request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+	request.Header.Set("Content-Type", jsonContentType)
+	request.Header.Set(contentEncodingHeader, gzipEncoding)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status %d, got %d", http.StatusRequestEntityTooLarge, response.StatusCode)
+	}
+}
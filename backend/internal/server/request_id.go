@@ -0,0 +1,54 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	requestIDHeader         = "X-Request-ID"
+	requestIDContextKey     = "gravity_request_id"
+	requestLoggerContextKey = "gravity_request_logger"
+	requestIDField          = "request_id"
+	maxRequestIDLength      = 128
+)
+
+// requestIDMiddleware propagates or generates a request id, echoes it in the response, and stores a request-scoped logger.
+func requestIDMiddleware(logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := sanitizeRequestID(c.GetHeader(requestIDHeader))
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+		c.Set(requestIDContextKey, requestID)
+		c.Set(requestLoggerContextKey, logger.With(zap.String(requestIDField, requestID)))
+		c.Header(requestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+func sanitizeRequestID(raw string) string {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" || len(trimmed) > maxRequestIDLength {
+		return ""
+	}
+	for _, character := range trimmed {
+		if character < '!' || character > '~' {
+			return ""
+		}
+	}
+	return trimmed
+}
+
+// loggerFor returns the request-scoped logger, falling back to the handler logger outside the middleware.
+func (h *httpHandler) loggerFor(c *gin.Context) *zap.Logger {
+	if value, ok := c.Get(requestLoggerContextKey); ok {
+		if logger, ok := value.(*zap.Logger); ok {
+			return logger
+		}
+	}
+	return h.logger
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/notes"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestIDMiddleware(testContext *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testCases := []struct {
+		name            string
+		incomingID      string
+		expectGenerated bool
+	}{
+		{name: "propagates-incoming", incomingID: "client-trace-42"},
+		{name: "generates-when-missing", expectGenerated: true},
+		{name: "replaces-invalid", incomingID: "has spaces", expectGenerated: true},
+	}
+
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(testContext *testing.T) {
+			core, logs := observer.New(zap.InfoLevel)
+			handler, err := NewHTTPHandler(Dependencies{
+				SessionValidator: stubSessionValidator{},
+				NotesService:     &notes.Service{},
+				Logger:           zap.New(core),
+			})
+			if err != nil {
+				testContext.Fatalf("failed to build handler: %v", err)
+			}
+
+			request := httptest.NewRequest(http.MethodGet, "/notes?limit=abc", http.NoBody)
+			request.Header.Set("Authorization", "Bearer token")
+			if testCase.incomingID != "" {
+				request.Header.Set(requestIDHeader, testCase.incomingID)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			requestID := recorder.Header().Get(requestIDHeader)
+			if testCase.expectGenerated {
+				if _, err := uuid.Parse(requestID); err != nil {
+					testContext.Fatalf("expected generated uuid request id, got %q", requestID)
+				}
+			} else if requestID != testCase.incomingID {
+				testContext.Fatalf("expected request id %q to round-trip, got %q", testCase.incomingID, requestID)
+			}
+
+			var payload map[string]any
+			if err := json.Unmarshal(recorder.Body.Bytes(), &payload); err != nil {
+				testContext.Fatalf("failed to decode payload: %v", err)
+			}
+			if payload[requestIDField] != requestID {
+				testContext.Fatalf("expected error body to carry request id %q, got %v", requestID, payload[requestIDField])
+			}
+
+			if logs.Len() == 0 {
+				testContext.Fatal("expected the rejected request to be logged")
+			}
+			for _, entry := range logs.All() {
+				if entry.ContextMap()[requestIDField] != requestID {
+					testContext.Fatalf("expected log %q to carry request id %q, got %v", entry.Message, requestID, entry.ContextMap())
+				}
+			}
+		})
+	}
+}
@@ -1,29 +1,46 @@
 package server
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/MarcoPoloResearchLab/gravity/backend/internal/auth"
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/buildinfo"
 	"github.com/MarcoPoloResearchLab/gravity/backend/internal/notes"
 	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 const (
-	userIDContextKey    = "gravity_user_id"
-	crdtProtocolVersion = "crdt-v1"
+	userIDContextKey        = "gravity_user_id"
+	sessionExpiryContextKey = "gravity_session_expiry"
+	userRolesContextKey     = "gravity_user_roles"
+	adminRole               = "admin"
+	crdtProtocolVersion     = "crdt-v1"
+	apiVersionPrefix        = "/v1"
+	deprecationHeader       = "Deprecation"
+	lastEventIDHeader       = "Last-Event-ID"
+	etagHeader              = "ETag"
+	ifNoneMatchHeader       = "If-None-Match"
 )
 
 var (
-	errMissingSessionValidator = errors.New("session validator dependency required")
-	errMissingNotesService     = errors.New("notes service dependency required")
-	errInvalidAuthorization    = errors.New("authorization token missing or invalid")
+	errMissingSessionValidator  = errors.New("session validator dependency required")
+	errMissingNotesService      = errors.New("notes service dependency required")
+	errInvalidCORSMaxAge        = errors.New("cors max age must not be negative")
+	errInvalidStreamIdleTimeout = errors.New("stream idle timeout must not be negative")
 )
 
 type SessionValidator interface {
@@ -34,13 +51,46 @@ type IdentityResolver interface {
 	ResolveCanonicalUserID(claims auth.SessionClaims) (string, error)
 }
 
+// IdentityRemover deletes the provider identities mapped to a canonical user id.
+type IdentityRemover interface {
+	DeleteUser(userID string) error
+}
+
+// Pinger reports whether a backing store is reachable; *sql.DB satisfies it.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// SessionRevoker invalidates a session token server-side when the user logs out.
+type SessionRevoker interface {
+	RevokeSession(token string) error
+}
+
 type Dependencies struct {
 	SessionValidator SessionValidator
 	SessionCookie    string
+	SessionRevoker   SessionRevoker
 	NotesService     *notes.Service
 	Logger           *zap.Logger
 	Realtime         *RealtimeDispatcher
 	UserIdentities   IdentityResolver
+	IdentityRemover  IdentityRemover
+	Metrics          *Metrics
+	Pinger           Pinger
+	RateLimit        RateLimitConfig
+	CORS             CORSConfig
+	TracerProvider   trace.TracerProvider
+	// DisableUnversionedRoutes serves the API only under /v1, ending the deprecation window for bare paths.
+	DisableUnversionedRoutes bool
+	// MaxRequestBodyBytes caps request bodies; zero selects DefaultMaxRequestBodyBytes.
+	MaxRequestBodyBytes int64
+	// StreamIdleTimeout closes a realtime stream whose next event cannot be flushed in time; zero selects DefaultStreamIdleTimeout.
+	StreamIdleTimeout time.Duration
+	// Clock supplies the current time for rate limiting, event timestamps, stream flush bookkeeping and the
+	// session-expiry timer; nil selects time.Now. It should match the session validator's clock, which checks
+	// the token expiry. Socket write deadlines stay on the wall clock because the connection is measured
+	// against it.
+	Clock func() time.Time
 }
 
 func NewHTTPHandler(deps Dependencies) (http.Handler, error) {
@@ -50,20 +100,46 @@ func NewHTTPHandler(deps Dependencies) (http.Handler, error) {
 	if deps.NotesService == nil {
 		return nil, errMissingNotesService
 	}
+	if deps.CORS.MaxAge < 0 {
+		return nil, errInvalidCORSMaxAge
+	}
+	if deps.StreamIdleTimeout < 0 {
+		return nil, errInvalidStreamIdleTimeout
+	}
+	streamIdleTimeout := deps.StreamIdleTimeout
+	if streamIdleTimeout == 0 {
+		streamIdleTimeout = DefaultStreamIdleTimeout
+	}
 
 	logger := deps.Logger
 	if logger == nil {
 		logger = zap.NewNop()
 	}
 
+	clock := deps.Clock
+	if clock == nil {
+		clock = time.Now
+	}
+
 	realtime := deps.Realtime
 	if realtime == nil {
 		realtime = NewRealtimeDispatcher()
 	}
 
+	if err := deps.Metrics.registerRealtime(realtime); err != nil {
+		return nil, err
+	}
+
 	router := gin.New()
 	router.Use(gin.Recovery())
-	router.Use(corsMiddleware())
+	router.Use(requestIDMiddleware(logger))
+	router.GET("/healthz", handleHealthz)
+	router.GET("/readyz", newReadyzHandler(deps.Pinger, logger))
+	router.GET("/version", handleVersion)
+	router.Use(accessLogMiddleware(logger))
+	router.Use(tracePropagationMiddleware())
+	router.Use(corsMiddleware(deps.CORS))
+	router.Use(requestBodyLimitMiddleware(deps.MaxRequestBodyBytes))
 
 	sessionCookie := strings.TrimSpace(deps.SessionCookie)
 	if sessionCookie == "" {
@@ -71,27 +147,118 @@ func NewHTTPHandler(deps Dependencies) (http.Handler, error) {
 	}
 
 	handler := &httpHandler{
-		sessions:       deps.SessionValidator,
-		sessionCookie:  sessionCookie,
-		notesService:   deps.NotesService,
-		logger:         logger,
-		realtime:       realtime,
-		userIdentities: deps.UserIdentities,
+		sessions:            deps.SessionValidator,
+		sessionCookie:       sessionCookie,
+		sessionRevoker:      deps.SessionRevoker,
+		notesService:        deps.NotesService,
+		logger:              logger,
+		realtime:            realtime,
+		userIdentities:      deps.UserIdentities,
+		identities:          deps.IdentityRemover,
+		metrics:             deps.Metrics,
+		tracer:              newTracer(deps.TracerProvider),
+		streamIdleTimeout:   streamIdleTimeout,
+		maxRequestBodyBytes: deps.MaxRequestBodyBytes,
+		clock:               clock,
+	}
+
+	if deps.Metrics != nil {
+		router.GET("/metrics", gin.WrapH(deps.Metrics.handler()))
 	}
 
-	protected := router.Group("/")
-	protected.Use(handler.authorizeRequest)
-	protected.POST("/notes/sync", handler.handleNotesSync)
-	protected.GET("/notes", handler.handleListNotes)
-	protected.GET("/notes/stream", handler.handleNotesStream)
+	limiter := newUserRateLimiter(deps.RateLimit, clock)
+	handler.registerAPIRoutes(router.Group(apiVersionPrefix), limiter)
+	if !deps.DisableUnversionedRoutes {
+		legacy := router.Group("/")
+		legacy.Use(deprecationMiddleware())
+		handler.registerAPIRoutes(legacy, limiter)
+	}
 
 	return router, nil
 }
 
-func corsMiddleware() gin.HandlerFunc {
-	const allowMethods = "GET,POST,OPTIONS"
+// registerAPIRoutes mounts the session and notes API on group; the same limiter is shared across groups
+// so versioned and legacy paths draw from one bucket per user.
+func (h *httpHandler) registerAPIRoutes(group *gin.RouterGroup, limiter *userRateLimiter) {
+	group.POST("/auth/logout", h.handleLogout)
+
+	protected := group.Group("/")
+	protected.Use(h.authorizeRequest)
+	if limiter != nil {
+		protected.Use(rateLimitMiddleware(limiter))
+	}
+	decompressRequest := decompressRequestMiddleware(h.maxRequestBodyBytes)
+	protected.POST("/notes/sync", decompressRequest, h.handleNotesSync)
+	protected.POST("/notes/crdt/push", decompressRequest, h.handleCrdtPush)
+	protected.POST("/notes/crdt/pull", h.handleCrdtPull)
+	protected.POST("/notes/batch-get", gzipMiddleware(defaultGzipMinSize), h.handleBatchGetNotes)
+	protected.PUT("/notes/:noteId", decompressRequest, h.handlePutNote)
+	protected.POST("/notes/:noteId/tags", h.handleSetNoteTags)
+	protected.GET("/notes/:noteId/crdt/snapshot", gzipMiddleware(defaultGzipMinSize), h.handleGetCrdtSnapshot)
+	protected.GET("/notes/crdt/snapshots", unlessNDJSON(gzipMiddleware(defaultGzipMinSize)), h.handleListNotes)
+	protected.GET("/notes", unlessNDJSON(gzipMiddleware(defaultGzipMinSize)), h.handleListNotes)
+	protected.GET(notesStreamPath, h.handleNotesStream)
+	protected.GET("/account/export", gzipMiddleware(defaultGzipMinSize), h.handleAccountExport)
+	protected.GET("/account/stats", h.handleAccountStats)
+	protected.DELETE("/account", h.handleAccountDelete)
+	protected.GET("/admin/users/:userId/notes", requireRole(adminRole), gzipMiddleware(defaultGzipMinSize), h.handleAdminListUserNotes)
+	protected.GET("/admin/users/:userId/integrity", requireRole(adminRole), h.handleAdminVerifyIntegrity)
+}
+
+// deprecationMiddleware marks responses from unversioned paths so clients can migrate to the /v1 prefix.
+func deprecationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header(deprecationHeader, "true")
+		c.Next()
+	}
+}
+
+func handleHealthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func handleVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, buildinfo.Current())
+}
+
+func newReadyzHandler(pinger Pinger, logger *zap.Logger) gin.HandlerFunc {
+	const readinessTimeout = 2 * time.Second
+	return func(c *gin.Context) {
+		if pinger != nil {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+			defer cancel()
+			if err := pinger.PingContext(ctx); err != nil {
+				logger.Warn("readiness check failed", zap.Error(err))
+				respondError(c, http.StatusServiceUnavailable, "database_unreachable", nil)
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready"})
+	}
+}
+
+// DefaultCORSAllowedMethods lists the methods the API routes use; preflights for other methods are not granted.
+var DefaultCORSAllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions}
+
+// CORSConfig tunes the preflight response. Empty AllowedMethods selects DefaultCORSAllowedMethods;
+// a zero MaxAge omits Access-Control-Max-Age so browsers apply their own short default.
+type CORSConfig struct {
+	AllowedMethods []string
+	MaxAge         time.Duration
+}
+
+func corsMiddleware(cfg CORSConfig) gin.HandlerFunc {
 	const allowCredentials = "true"
-	const allowHeaders = "Authorization, Content-Type, X-Requested-With, X-Client, X-TAuth-Tenant"
+	const allowHeaders = "Authorization, Content-Type, X-Requested-With, X-Client, X-TAuth-Tenant, Last-Event-ID, X-Request-ID"
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = DefaultCORSAllowedMethods
+	}
+	allowMethods := strings.ToUpper(strings.Join(methods, ","))
+	maxAge := ""
+	if cfg.MaxAge > 0 {
+		maxAge = strconv.FormatInt(int64(cfg.MaxAge/time.Second), 10)
+	}
 	return func(c *gin.Context) {
 		origin := strings.TrimSpace(c.GetHeader("Origin"))
 		if origin != "" {
@@ -100,6 +267,10 @@ func corsMiddleware() gin.HandlerFunc {
 			c.Header("Access-Control-Allow-Credentials", allowCredentials)
 			c.Header("Access-Control-Allow-Methods", allowMethods)
 			c.Header("Access-Control-Allow-Headers", allowHeaders)
+			c.Header("Access-Control-Expose-Headers", requestIDHeader)
+			if maxAge != "" {
+				c.Header("Access-Control-Max-Age", maxAge)
+			}
 		}
 		if c.Request.Method == http.MethodOptions {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -112,16 +283,27 @@ func corsMiddleware() gin.HandlerFunc {
 type httpHandler struct {
 	sessions       SessionValidator
 	sessionCookie  string
+	sessionRevoker SessionRevoker
 	notesService   *notes.Service
 	logger         *zap.Logger
 	realtime       *RealtimeDispatcher
 	userIdentities IdentityResolver
+	identities     IdentityRemover
+	metrics        *Metrics
+	tracer         trace.Tracer
+	// streamIdleTimeout bounds how long one realtime event may take to flush before the stream is closed.
+	streamIdleTimeout time.Duration
+	// maxRequestBodyBytes also bounds decompressed sync bodies.
+	maxRequestBodyBytes int64
+	clock               func() time.Time
 }
 
 type crdtSyncRequestPayload struct {
 	Protocol string                  `json:"protocol"`
 	Updates  []crdtSyncUpdatePayload `json:"updates"`
 	Cursors  []crdtSyncCursorPayload `json:"cursors"`
+	// Limit caps the updates a pull returns; zero returns every remaining update. Other endpoints ignore it.
+	Limit int `json:"limit,omitempty"`
 }
 
 type crdtSyncUpdatePayload struct {
@@ -142,11 +324,39 @@ type crdtSyncResponsePayload struct {
 	Updates  []crdtSyncUpdateResponsePayload `json:"updates"`
 }
 
+type crdtPushResponsePayload struct {
+	Protocol string                  `json:"protocol"`
+	Results  []crdtSyncResultPayload `json:"results"`
+}
+
+type crdtPutNoteRequestPayload struct {
+	Protocol         string `json:"protocol"`
+	UpdateB64        string `json:"update_b64"`
+	SnapshotB64      string `json:"snapshot_b64"`
+	SnapshotUpdateID int64  `json:"snapshot_update_id"`
+	// LastUpdateID is the note's cursor; without it the snapshot is stored as covering no updates.
+	LastUpdateID *int64 `json:"last_update_id,omitempty"`
+}
+
+type crdtPutNoteResponsePayload struct {
+	Protocol string                `json:"protocol"`
+	Result   crdtSyncResultPayload `json:"result"`
+}
+
+type crdtPullResponsePayload struct {
+	Protocol string                          `json:"protocol"`
+	Updates  []crdtSyncUpdateResponsePayload `json:"updates"`
+	// HasMore reports a truncated page; sending NextCursors back resumes after its last update.
+	HasMore     bool                    `json:"has_more"`
+	NextCursors []crdtSyncCursorPayload `json:"next_cursors"`
+}
+
 type crdtSyncResultPayload struct {
-	NoteID    string `json:"note_id"`
-	Accepted  bool   `json:"accepted"`
-	UpdateID  int64  `json:"update_id"`
-	Duplicate bool   `json:"duplicate"`
+	NoteID                string `json:"note_id"`
+	Accepted              bool   `json:"accepted"`
+	UpdateID              int64  `json:"update_id"`
+	Duplicate             bool   `json:"duplicate"`
+	CompactionRecommended bool   `json:"compaction_recommended"`
 }
 
 type crdtSyncUpdateResponsePayload struct {
@@ -156,8 +366,53 @@ type crdtSyncUpdateResponsePayload struct {
 }
 
 type crdtSnapshotResponsePayload struct {
-	Protocol string                    `json:"protocol"`
-	Notes    []crdtSnapshotNotePayload `json:"notes"`
+	Protocol   string                    `json:"protocol"`
+	Notes      []crdtSnapshotNotePayload `json:"notes"`
+	NextCursor string                    `json:"next_cursor,omitempty"`
+}
+
+type integrityReportResponsePayload struct {
+	UserID string                          `json:"user_id"`
+	Issues []integrityIssueResponsePayload `json:"issues"`
+}
+
+type integrityIssueResponsePayload struct {
+	NoteID           string `json:"note_id"`
+	Kind             string `json:"kind"`
+	SnapshotUpdateID int64  `json:"snapshot_update_id"`
+	MaxUpdateID      int64  `json:"max_update_id"`
+}
+
+type accountExportResponsePayload struct {
+	Protocol   string                          `json:"protocol"`
+	UserID     string                          `json:"user_id"`
+	ExportedAt int64                           `json:"exported_at_s"`
+	Notes      []crdtSnapshotNotePayload       `json:"notes"`
+	Updates    []crdtSyncUpdateResponsePayload `json:"updates"`
+	Tags       map[string][]string             `json:"tags"`
+}
+
+type crdtSnapshotDocumentResponsePayload struct {
+	Protocol         string `json:"protocol"`
+	NoteID           string `json:"note_id"`
+	SnapshotB64      string `json:"snapshot_b64"`
+	SnapshotUpdateID int64  `json:"snapshot_update_id"`
+}
+
+type noteTagsRequestPayload struct {
+	Tags []string `json:"tags"`
+}
+
+type noteTagsResponsePayload struct {
+	NoteID string   `json:"note_id"`
+	Tags   []string `json:"tags"`
+}
+
+type accountStatsResponsePayload struct {
+	NoteCount     int64 `json:"note_count"`
+	UpdateCount   int64 `json:"update_count"`
+	SnapshotBytes int64 `json:"snapshot_bytes"`
+	UpdateBytes   int64 `json:"update_bytes"`
 }
 
 type crdtSnapshotNotePayload struct {
@@ -167,89 +422,302 @@ type crdtSnapshotNotePayload struct {
 }
 
 func (h *httpHandler) handleNotesSync(c *gin.Context) {
-	userIDValue := c.GetString(userIDContextKey)
-	if userIDValue == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	span := h.startSpan(c, spanNotesSync)
+	defer span.End()
+
+	userID, ok := h.requestUserID(c, "sync_failed")
+	if !ok {
 		return
 	}
+	span.SetAttributes(attribute.String(notes.AttributeUserID, userID.String()))
 
-	userID, err := notes.NewUserID(userIDValue)
-	if err != nil {
-		h.logger.Error("invalid user identifier in context", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "sync_failed"})
+	var request crdtSyncRequestPayload
+	if !bindJSON(c, &request) {
+		return
+	}
+	if strings.TrimSpace(request.Protocol) != crdtProtocolVersion {
+		respondError(c, http.StatusBadRequest, "invalid_protocol", nil)
+		return
+	}
+	if len(request.Updates) == 0 && len(request.Cursors) == 0 {
+		respondError(c, http.StatusBadRequest, "invalid_request", nil)
+		return
+	}
+
+	cursors, cursorByNoteID, errorCode := parseCrdtSyncCursors(request.Cursors)
+	if errorCode != "" {
+		respondError(c, http.StatusBadRequest, errorCode, nil)
+		return
+	}
+	updates, validationErr := parseCrdtSyncUpdates(userID, request.Updates, cursorByNoteID, true)
+	if validationErr != nil {
+		respondOperationError(c, validationErr)
+		return
+	}
+	span.SetAttributes(attribute.Int(notes.AttributeOperationCount, len(updates)))
+
+	result, ok := h.applyCrdtUpdates(c, userID, updates)
+	if !ok {
+		return
+	}
+	updatesFromServer, ok := h.listCrdtUpdates(c, userID, cursors)
+	if !ok {
+		return
+	}
+
+	response := crdtSyncResponsePayload{
+		Protocol: crdtProtocolVersion,
+		Results:  newCrdtSyncResultPayloads(result.UpdateOutcomes),
+		Updates:  newCrdtSyncUpdateResponsePayloads(updatesFromServer),
+	}
+
+	h.broadcastCrdtNoteChanges(userID.String(), updates, result.UpdateOutcomes)
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *httpHandler) handleCrdtPush(c *gin.Context) {
+	userID, ok := h.requestUserID(c, "sync_failed")
+	if !ok {
 		return
 	}
 
 	var request crdtSyncRequestPayload
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+	if !bindJSON(c, &request) {
 		return
 	}
 	if strings.TrimSpace(request.Protocol) != crdtProtocolVersion {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_protocol"})
+		respondError(c, http.StatusBadRequest, "invalid_protocol", nil)
 		return
 	}
-	if len(request.Updates) == 0 && len(request.Cursors) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request"})
+	if len(request.Updates) == 0 {
+		respondError(c, http.StatusBadRequest, "invalid_request", nil)
 		return
 	}
 
-	cursorByNoteID := make(map[string]int64, len(request.Cursors))
-	cursors := make([]notes.CrdtCursor, 0, len(request.Cursors))
-	for _, cursor := range request.Cursors {
-		noteID, err := notes.NewNoteID(cursor.NoteID)
+	_, cursorByNoteID, errorCode := parseCrdtSyncCursors(request.Cursors)
+	if errorCode != "" {
+		respondError(c, http.StatusBadRequest, errorCode, nil)
+		return
+	}
+	updates, validationErr := parseCrdtSyncUpdates(userID, request.Updates, cursorByNoteID, false)
+	if validationErr != nil {
+		respondOperationError(c, validationErr)
+		return
+	}
+
+	result, ok := h.applyCrdtUpdates(c, userID, updates)
+	if !ok {
+		return
+	}
+
+	h.broadcastCrdtNoteChanges(userID.String(), updates, result.UpdateOutcomes)
+	c.JSON(http.StatusOK, crdtPushResponsePayload{
+		Protocol: crdtProtocolVersion,
+		Results:  newCrdtSyncResultPayloads(result.UpdateOutcomes),
+	})
+}
+
+// handlePutNote pushes one CRDT update for the note named in the path. It is a one-element
+// POST /notes/crdt/push, so validation and storage errors carry the same codes.
+func (h *httpHandler) handlePutNote(c *gin.Context) {
+	userID, ok := h.requestUserID(c, "sync_failed")
+	if !ok {
+		return
+	}
+
+	var request crdtPutNoteRequestPayload
+	if !bindJSON(c, &request) {
+		return
+	}
+	if strings.TrimSpace(request.Protocol) != crdtProtocolVersion {
+		respondError(c, http.StatusBadRequest, "invalid_protocol", nil)
+		return
+	}
+
+	var cursors []crdtSyncCursorPayload
+	if request.LastUpdateID != nil {
+		cursors = []crdtSyncCursorPayload{{NoteID: c.Param("noteId"), LastUpdateID: *request.LastUpdateID}}
+	}
+	_, cursorByNoteID, errorCode := parseCrdtSyncCursors(cursors)
+	if errorCode != "" {
+		respondError(c, http.StatusBadRequest, errorCode, nil)
+		return
+	}
+	updates, validationErr := parseCrdtSyncUpdates(userID, []crdtSyncUpdatePayload{{
+		NoteID:           c.Param("noteId"),
+		UpdateB64:        request.UpdateB64,
+		SnapshotB64:      request.SnapshotB64,
+		SnapshotUpdateID: request.SnapshotUpdateID,
+	}}, cursorByNoteID, false)
+	if validationErr != nil {
+		respondOperationError(c, validationErr)
+		return
+	}
+
+	result, ok := h.applyCrdtUpdates(c, userID, updates)
+	if !ok {
+		return
+	}
+
+	if len(result.UpdateOutcomes) != 1 {
+		h.loggerFor(c).Error("unexpected CRDT outcome count for a single note update",
+			zap.Int("outcome_count", len(result.UpdateOutcomes)))
+		respondError(c, http.StatusInternalServerError, "sync_failed", nil)
+		return
+	}
+
+	h.broadcastCrdtNoteChanges(userID.String(), updates, result.UpdateOutcomes)
+	c.JSON(http.StatusOK, crdtPutNoteResponsePayload{
+		Protocol: crdtProtocolVersion,
+		Result:   newCrdtSyncResultPayloads(result.UpdateOutcomes)[0],
+	})
+}
+
+func (h *httpHandler) handleCrdtPull(c *gin.Context) {
+	userID, ok := h.requestUserID(c, "sync_failed")
+	if !ok {
+		return
+	}
+
+	var request crdtSyncRequestPayload
+	if !bindJSON(c, &request) {
+		return
+	}
+	if strings.TrimSpace(request.Protocol) != crdtProtocolVersion {
+		respondError(c, http.StatusBadRequest, "invalid_protocol", nil)
+		return
+	}
+	if len(request.Cursors) == 0 {
+		respondError(c, http.StatusBadRequest, "invalid_request", nil)
+		return
+	}
+
+	cursors, _, errorCode := parseCrdtSyncCursors(request.Cursors)
+	if errorCode != "" {
+		respondError(c, http.StatusBadRequest, errorCode, nil)
+		return
+	}
+
+	page, err := h.notesService.ListCrdtUpdatesPage(c.Request.Context(), userID, cursors, request.Limit)
+	if err != nil {
+		h.respondServiceError(c, err, "sync_failed", "failed to list CRDT updates")
+		return
+	}
+
+	c.JSON(http.StatusOK, crdtPullResponsePayload{
+		Protocol:    crdtProtocolVersion,
+		Updates:     newCrdtSyncUpdateResponsePayloads(page.Updates),
+		HasMore:     page.HasMore,
+		NextCursors: newCrdtSyncCursorPayloads(page.NextCursors),
+	})
+}
+
+// handleBatchGetNotes returns snapshots for a JSON array of note identifiers.
+// Identifiers the user does not own are omitted from the response.
+func (h *httpHandler) handleBatchGetNotes(c *gin.Context) {
+	userID, ok := h.requestUserID(c, "list_failed")
+	if !ok {
+		return
+	}
+
+	var rawNoteIDs []string
+	if !bindJSON(c, &rawNoteIDs) {
+		return
+	}
+	if len(rawNoteIDs) > notes.MaxBatchNoteIDs {
+		respondError(c, http.StatusBadRequest, "too_many_note_ids", nil)
+		return
+	}
+	noteIDs := make([]notes.NoteID, 0, len(rawNoteIDs))
+	for _, rawNoteID := range rawNoteIDs {
+		noteID, err := notes.NewNoteID(rawNoteID)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_note_id"})
+			respondError(c, http.StatusBadRequest, "invalid_note_id", nil)
 			return
 		}
+		noteIDs = append(noteIDs, noteID)
+	}
+
+	snapshots, err := h.notesService.GetCrdtSnapshotsByNoteIDs(c.Request.Context(), userID, noteIDs)
+	if err != nil {
+		h.respondServiceError(c, err, "list_failed", "failed to get CRDT snapshots")
+		return
+	}
+
+	c.JSON(http.StatusOK, newCrdtSnapshotResponsePayload(snapshots, ""))
+}
+
+func (h *httpHandler) requestUserID(c *gin.Context, failureCode string) (notes.UserID, bool) {
+	userIDValue := c.GetString(userIDContextKey)
+	if userIDValue == "" {
+		respondError(c, http.StatusUnauthorized, "unauthorized", nil)
+		return "", false
+	}
+
+	userID, err := notes.NewUserID(userIDValue)
+	if err != nil {
+		h.loggerFor(c).Error("invalid user identifier in context", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, failureCode, nil)
+		return "", false
+	}
+	return userID, true
+}
+
+func parseCrdtSyncCursors(payloads []crdtSyncCursorPayload) ([]notes.CrdtCursor, map[string]int64, string) {
+	cursorByNoteID := make(map[string]int64, len(payloads))
+	cursors := make([]notes.CrdtCursor, 0, len(payloads))
+	for _, cursor := range payloads {
+		noteID, err := notes.NewNoteID(cursor.NoteID)
+		if err != nil {
+			return nil, nil, "invalid_note_id"
+		}
 		lastUpdateID, err := notes.NewCrdtUpdateID(cursor.LastUpdateID)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_cursor"})
-			return
+			return nil, nil, "invalid_cursor"
 		}
 		parsedCursor, err := notes.NewCrdtCursor(notes.CrdtCursorConfig{
 			NoteID:       noteID,
 			LastUpdateID: lastUpdateID,
 		})
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_cursor"})
-			return
+			return nil, nil, "invalid_cursor"
 		}
-		noteIDValue := noteID.String()
-		cursorByNoteID[noteIDValue] = lastUpdateID.Int64()
+		cursorByNoteID[noteID.String()] = lastUpdateID.Int64()
 		cursors = append(cursors, parsedCursor)
 	}
+	return cursors, cursorByNoteID, ""
+}
 
-	updates := make([]notes.CrdtUpdateEnvelope, 0, len(request.Updates))
-	for _, update := range request.Updates {
+// parseCrdtSyncUpdates clamps each snapshot id to the note's cursor so a snapshot never claims to cover
+// updates the client has not seen; compaction would otherwise delete them. With requireCursors a note
+// without a cursor is rejected, otherwise its snapshot is stored as covering no updates.
+// A rejection names the zero-based index of the first invalid update and, once it parsed, its note id.
+func parseCrdtSyncUpdates(userID notes.UserID, payloads []crdtSyncUpdatePayload, cursorByNoteID map[string]int64, requireCursors bool) ([]notes.CrdtUpdateEnvelope, *operationError) {
+	updates := make([]notes.CrdtUpdateEnvelope, 0, len(payloads))
+	for index, update := range payloads {
 		noteID, err := notes.NewNoteID(update.NoteID)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_note_id"})
-			return
+			return nil, &operationError{code: "invalid_note_id", index: index}
 		}
 		updateB64, err := notes.NewCrdtUpdateBase64(update.UpdateB64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_update"})
-			return
+			return nil, &operationError{code: "invalid_update", index: index, noteID: noteID}
 		}
 		snapshotB64, err := notes.NewCrdtSnapshotBase64(update.SnapshotB64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_snapshot"})
-			return
+			return nil, &operationError{code: "invalid_snapshot", index: index, noteID: noteID}
 		}
+		snapshotUpdateIDValue := update.SnapshotUpdateID
 		cursorLastUpdateID, ok := cursorByNoteID[noteID.String()]
-		if !ok {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "missing_cursor"})
-			return
+		if !ok && requireCursors {
+			return nil, &operationError{code: "missing_cursor", index: index, noteID: noteID}
 		}
-		snapshotUpdateIDValue := update.SnapshotUpdateID
 		if snapshotUpdateIDValue > cursorLastUpdateID {
 			snapshotUpdateIDValue = cursorLastUpdateID
 		}
 		snapshotUpdateID, err := notes.NewCrdtUpdateID(snapshotUpdateIDValue)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_snapshot_update_id"})
-			return
+			return nil, &operationError{code: "invalid_snapshot_update_id", index: index, noteID: noteID}
 		}
 		envelope, err := notes.NewCrdtUpdateEnvelope(notes.CrdtUpdateEnvelopeConfig{
 			UserID:           userID,
@@ -259,64 +727,85 @@ func (h *httpHandler) handleNotesSync(c *gin.Context) {
 			SnapshotUpdateID: snapshotUpdateID,
 		})
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_update"})
-			return
+			return nil, &operationError{code: "invalid_update", index: index, noteID: noteID}
 		}
 		updates = append(updates, envelope)
 	}
+	return updates, nil
+}
 
+func (h *httpHandler) applyCrdtUpdates(c *gin.Context, userID notes.UserID, updates []notes.CrdtUpdateEnvelope) (notes.CrdtSyncResult, bool) {
 	result, err := h.notesService.ApplyCrdtUpdates(c.Request.Context(), userID, updates)
+	span := trace.SpanFromContext(c.Request.Context())
 	if err != nil {
-		var serviceErr *notes.ServiceError
-		if errors.As(err, &serviceErr) {
-			h.logger.Error("failed to apply CRDT updates", zap.String("error_code", serviceErr.Code()), zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "sync_failed", "code": serviceErr.Code()})
-		} else {
-			h.logger.Error("failed to apply CRDT updates", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "sync_failed"})
-		}
-		return
+		h.metrics.observeSyncRejected(len(updates))
+		recordSyncRejected(span, len(updates), err)
+		h.respondServiceError(c, err, "sync_failed", "failed to apply CRDT updates")
+		return notes.CrdtSyncResult{}, false
 	}
+	recordSyncOutcomes(span, result.UpdateOutcomes)
+	return result, true
+}
 
+func (h *httpHandler) listCrdtUpdates(c *gin.Context, userID notes.UserID, cursors []notes.CrdtCursor) ([]notes.CrdtUpdateRecord, bool) {
 	updatesFromServer, err := h.notesService.ListCrdtUpdates(c.Request.Context(), userID, cursors)
 	if err != nil {
-		var serviceErr *notes.ServiceError
-		if errors.As(err, &serviceErr) {
-			h.logger.Error("failed to list CRDT updates", zap.String("error_code", serviceErr.Code()), zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "sync_failed", "code": serviceErr.Code()})
-		} else {
-			h.logger.Error("failed to list CRDT updates", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "sync_failed"})
-		}
-		return
+		h.respondServiceError(c, err, "sync_failed", "failed to list CRDT updates")
+		return nil, false
 	}
+	return updatesFromServer, true
+}
 
-	response := crdtSyncResponsePayload{
-		Protocol: crdtProtocolVersion,
-		Results:  make([]crdtSyncResultPayload, 0, len(result.UpdateOutcomes)),
-		Updates:  make([]crdtSyncUpdateResponsePayload, 0, len(updatesFromServer)),
-	}
-	for _, outcome := range result.UpdateOutcomes {
-		response.Results = append(response.Results, crdtSyncResultPayload{
-			NoteID:    outcome.NoteID().String(),
-			Accepted:  true,
-			UpdateID:  outcome.UpdateID().Int64(),
-			Duplicate: outcome.Duplicate(),
+func newCrdtSyncResultPayloads(outcomes []notes.CrdtUpdateOutcome) []crdtSyncResultPayload {
+	results := make([]crdtSyncResultPayload, 0, len(outcomes))
+	for _, outcome := range outcomes {
+		results = append(results, crdtSyncResultPayload{
+			NoteID:                outcome.NoteID().String(),
+			Accepted:              true,
+			UpdateID:              outcome.UpdateID().Int64(),
+			Duplicate:             outcome.Duplicate(),
+			CompactionRecommended: outcome.CompactionRecommended(),
 		})
 	}
-	for _, update := range updatesFromServer {
-		response.Updates = append(response.Updates, crdtSyncUpdateResponsePayload{
+	return results
+}
+
+func newAccountExportTagsPayload(tagsByNoteID map[notes.NoteID][]notes.Tag) map[string][]string {
+	tags := make(map[string][]string, len(tagsByNoteID))
+	for noteID, noteTags := range tagsByNoteID {
+		values := make([]string, 0, len(noteTags))
+		for _, tag := range noteTags {
+			values = append(values, tag.String())
+		}
+		tags[noteID.String()] = values
+	}
+	return tags
+}
+
+func newCrdtSyncUpdateResponsePayloads(records []notes.CrdtUpdateRecord) []crdtSyncUpdateResponsePayload {
+	updates := make([]crdtSyncUpdateResponsePayload, 0, len(records))
+	for _, update := range records {
+		updates = append(updates, crdtSyncUpdateResponsePayload{
 			NoteID:    update.NoteID().String(),
 			UpdateID:  update.UpdateID().Int64(),
 			UpdateB64: update.UpdateB64().String(),
 		})
 	}
+	return updates
+}
 
-	h.broadcastCrdtNoteChanges(userID.String(), result.UpdateOutcomes)
-	c.JSON(http.StatusOK, response)
+func newCrdtSyncCursorPayloads(cursors []notes.CrdtCursor) []crdtSyncCursorPayload {
+	payloads := make([]crdtSyncCursorPayload, 0, len(cursors))
+	for _, cursor := range cursors {
+		payloads = append(payloads, crdtSyncCursorPayload{
+			NoteID:       cursor.NoteID().String(),
+			LastUpdateID: cursor.LastUpdateID().Int64(),
+		})
+	}
+	return payloads
 }
 
-func (h *httpHandler) broadcastCrdtNoteChanges(userID string, outcomes []notes.CrdtUpdateOutcome) {
+func (h *httpHandler) broadcastCrdtNoteChanges(userID string, updates []notes.CrdtUpdateEnvelope, outcomes []notes.CrdtUpdateOutcome) {
 	if h.realtime == nil {
 		return
 	}
@@ -332,45 +821,342 @@ func (h *httpHandler) broadcastCrdtNoteChanges(userID string, outcomes []notes.C
 		return
 	}
 	h.logger.Info("broadcasting realtime note change", zap.String("user_id", userID), zap.Strings("note_ids", noteIDs))
-	timestamp := time.Now().UTC()
+	timestamp := h.clock().UTC()
 	h.realtime.Publish(RealtimeMessage{
 		UserID:    userID,
 		EventType: RealtimeEventNoteChanged,
 		NoteIDs:   noteIDs,
+		Updates:   collectRealtimeCrdtUpdates(updates, outcomes),
 		Timestamp: timestamp,
 	})
 }
 
 func (h *httpHandler) handleListNotes(c *gin.Context) {
-	userIDValue := c.GetString(userIDContextKey)
-	if userIDValue == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	span := h.startSpan(c, spanNotesList)
+	defer span.End()
+
+	userID, ok := h.requestUserID(c, "list_failed")
+	if !ok {
 		return
 	}
 
-	userID, err := notes.NewUserID(userIDValue)
-	if err != nil {
-		h.logger.Error("invalid user identifier in context", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "list_failed"})
+	span.SetAttributes(attribute.String(notes.AttributeUserID, userID.String()))
+
+	if wantsNDJSON(c) {
+		h.streamNotesNDJSON(c, userID)
+		return
+	}
+	if rawSince := strings.TrimSpace(c.Query("since")); rawSince != "" {
+		h.listNotesSince(c, userID, rawSince)
+		return
+	}
+	if rawTag, hasTag := c.GetQuery("tag"); hasTag {
+		h.listNotesByTag(c, userID, rawTag)
 		return
 	}
 
-	snapshots, err := h.notesService.ListCrdtSnapshots(c.Request.Context(), userID)
+	limit := 0
+	if rawLimit := strings.TrimSpace(c.Query("limit")); rawLimit != "" {
+		parsedLimit, parseErr := strconv.Atoi(rawLimit)
+		if parseErr != nil {
+			respondError(c, http.StatusBadRequest, "invalid_limit", nil)
+			return
+		}
+		limit = parsedLimit
+	}
+	listOptions, err := notes.NewCrdtSnapshotListOptions(limit, c.Query("cursor"))
 	if err != nil {
-		var serviceErr *notes.ServiceError
-		if errors.As(err, &serviceErr) {
-			h.logger.Error("failed to list CRDT snapshots", zap.String("error_code", serviceErr.Code()), zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "list_failed", "code": serviceErr.Code()})
+		if errors.Is(err, notes.ErrInvalidListLimit) {
+			respondError(c, http.StatusBadRequest, "invalid_limit", nil)
 		} else {
-			h.logger.Error("failed to list CRDT snapshots", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "list_failed"})
+			respondError(c, http.StatusBadRequest, "invalid_cursor", nil)
+		}
+		return
+	}
+
+	if h.respondNotModified(c, userID) {
+		return
+	}
+
+	page, err := h.notesService.ListCrdtSnapshotsPage(c.Request.Context(), userID, listOptions)
+	if err != nil {
+		h.respondServiceError(c, err, "list_failed", "failed to list CRDT snapshots")
+		return
+	}
+
+	c.JSON(http.StatusOK, newCrdtSnapshotResponsePayload(page.Snapshots, page.NextCursor))
+}
+
+// handleAdminListUserNotes gives support staff read-only access to another user's snapshots.
+// Only sessions carrying the admin role may call it, and every access is logged with both user ids.
+func (h *httpHandler) handleAdminListUserNotes(c *gin.Context) {
+	targetUserID, err := notes.NewUserID(c.Param("userId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_user_id", nil)
+		return
+	}
+	h.loggerFor(c).Info("admin listed user notes",
+		zap.String("admin_user_id", c.GetString(userIDContextKey)),
+		zap.String("target_user_id", targetUserID.String()))
+
+	snapshots, err := h.notesService.ListCrdtSnapshots(c.Request.Context(), targetUserID)
+	if err != nil {
+		h.respondServiceError(c, err, "list_failed", "failed to list CRDT snapshots for admin")
+		return
+	}
+
+	c.JSON(http.StatusOK, newCrdtSnapshotResponsePayload(snapshots, ""))
+}
+
+// handleAdminVerifyIntegrity reports another user's notes whose snapshot coverage disagrees with the stored
+// updates. It only reads; repairs stay with migrations.
+func (h *httpHandler) handleAdminVerifyIntegrity(c *gin.Context) {
+	targetUserID, err := notes.NewUserID(c.Param("userId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_user_id", nil)
+		return
+	}
+	h.loggerFor(c).Info("admin verified user CRDT integrity",
+		zap.String("admin_user_id", c.GetString(userIDContextKey)),
+		zap.String("target_user_id", targetUserID.String()))
+
+	issues, err := h.notesService.VerifyCrdtIntegrity(c.Request.Context(), targetUserID)
+	if err != nil {
+		h.respondServiceError(c, err, "integrity_check_failed", "failed to verify CRDT integrity")
+		return
+	}
+
+	payload := integrityReportResponsePayload{
+		UserID: targetUserID.String(),
+		Issues: make([]integrityIssueResponsePayload, 0, len(issues)),
+	}
+	if len(issues) > 0 {
+		h.loggerFor(c).Warn("CRDT integrity issues found",
+			zap.String("target_user_id", targetUserID.String()),
+			zap.Int("issue_count", len(issues)))
+	}
+	for _, issue := range issues {
+		payload.Issues = append(payload.Issues, integrityIssueResponsePayload{
+			NoteID:           issue.NoteID.String(),
+			Kind:             issue.Kind,
+			SnapshotUpdateID: issue.SnapshotUpdateID,
+			MaxUpdateID:      issue.MaxUpdateID,
+		})
+	}
+	c.JSON(http.StatusOK, payload)
+}
+
+func (h *httpHandler) handleAccountExport(c *gin.Context) {
+	userID, ok := h.requestUserID(c, "export_failed")
+	if !ok {
+		return
+	}
+
+	export, err := h.notesService.ExportUserData(c.Request.Context(), userID)
+	if err != nil {
+		h.respondServiceError(c, err, "export_failed", "failed to export user data")
+		return
+	}
+
+	c.JSON(http.StatusOK, accountExportResponsePayload{
+		Protocol:   crdtProtocolVersion,
+		UserID:     export.UserID.String(),
+		ExportedAt: export.ExportedAt.Unix(),
+		Notes:      newCrdtSnapshotResponsePayload(export.Snapshots, "").Notes,
+		Updates:    newCrdtSyncUpdateResponsePayloads(export.Updates),
+		Tags:       newAccountExportTagsPayload(export.Tags),
+	})
+}
+
+func (h *httpHandler) handleAccountStats(c *gin.Context) {
+	userID, ok := h.requestUserID(c, "stats_failed")
+	if !ok {
+		return
+	}
+
+	stats, err := h.notesService.UserStats(c.Request.Context(), userID)
+	if err != nil {
+		h.respondServiceError(c, err, "stats_failed", "failed to compute user stats")
+		return
+	}
+
+	c.JSON(http.StatusOK, accountStatsResponsePayload{
+		NoteCount:     stats.NoteCount,
+		UpdateCount:   stats.UpdateCount,
+		SnapshotBytes: stats.SnapshotBytes,
+		UpdateBytes:   stats.UpdateBytes,
+	})
+}
+
+// handleAccountDelete removes the user's notes data and then their identity mappings. The two stores are
+// not updated atomically: identities are deleted only after the notes delete succeeds, and a failure there
+// leaves the notes already removed, so a retried request finishes the job.
+func (h *httpHandler) handleAccountDelete(c *gin.Context) {
+	userID, ok := h.requestUserID(c, "delete_failed")
+	if !ok {
+		return
+	}
+
+	if err := h.notesService.DeleteUserData(c.Request.Context(), userID); err != nil {
+		h.respondServiceError(c, err, "delete_failed", "failed to delete user data")
+		return
+	}
+
+	if h.identities != nil {
+		if err := h.identities.DeleteUser(userID.String()); err != nil {
+			h.loggerFor(c).Error("failed to delete user identities", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "delete_failed", nil)
+			return
 		}
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *httpHandler) listNotesByTag(c *gin.Context, userID notes.UserID, rawTag string) {
+	if strings.TrimSpace(c.Query("limit")) != "" || strings.TrimSpace(c.Query("cursor")) != "" {
+		respondError(c, http.StatusBadRequest, "invalid_request", nil)
+		return
+	}
+	tag, err := notes.NewTag(rawTag)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_tag", nil)
+		return
+	}
+
+	snapshots, err := h.notesService.ListNotesByTag(c.Request.Context(), userID, tag)
+	if err != nil {
+		h.respondServiceError(c, err, "list_failed", "failed to list tagged CRDT snapshots")
+		return
+	}
+
+	c.JSON(http.StatusOK, newCrdtSnapshotResponsePayload(snapshots, ""))
+}
+
+// handleGetCrdtSnapshot lets a client bootstrap one note from its snapshot instead of replaying every update.
+func (h *httpHandler) handleGetCrdtSnapshot(c *gin.Context) {
+	userID, ok := h.requestUserID(c, "list_failed")
+	if !ok {
+		return
+	}
+	noteID, err := notes.NewNoteID(c.Param("noteId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_note_id", nil)
 		return
 	}
 
+	snapshot, err := h.notesService.GetCrdtSnapshot(c.Request.Context(), userID, noteID)
+	if err != nil {
+		h.respondServiceError(c, err, "list_failed", "failed to get CRDT snapshot")
+		return
+	}
+
+	c.JSON(http.StatusOK, crdtSnapshotDocumentResponsePayload{
+		Protocol:         crdtProtocolVersion,
+		NoteID:           snapshot.NoteID().String(),
+		SnapshotB64:      snapshot.SnapshotB64().String(),
+		SnapshotUpdateID: snapshot.SnapshotUpdateID().Int64(),
+	})
+}
+
+func (h *httpHandler) handleSetNoteTags(c *gin.Context) {
+	userID, ok := h.requestUserID(c, "tags_failed")
+	if !ok {
+		return
+	}
+	noteID, err := notes.NewNoteID(c.Param("noteId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_note_id", nil)
+		return
+	}
+
+	var payload noteTagsRequestPayload
+	if !bindJSON(c, &payload) {
+		return
+	}
+	tags := make([]notes.Tag, 0, len(payload.Tags))
+	for _, rawTag := range payload.Tags {
+		tag, tagErr := notes.NewTag(rawTag)
+		if tagErr != nil {
+			respondError(c, http.StatusBadRequest, "invalid_tag", nil)
+			return
+		}
+		tags = append(tags, tag)
+	}
+
+	stored, err := h.notesService.SetNoteTags(c.Request.Context(), userID, noteID, tags)
+	if err != nil {
+		h.respondServiceError(c, err, "tags_failed", "failed to set note tags")
+		return
+	}
+
+	response := noteTagsResponsePayload{NoteID: noteID.String(), Tags: make([]string, 0, len(stored))}
+	for _, tag := range stored {
+		response.Tags = append(response.Tags, tag.String())
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *httpHandler) listNotesSince(c *gin.Context, userID notes.UserID, rawSince string) {
+	if strings.TrimSpace(c.Query("limit")) != "" || strings.TrimSpace(c.Query("cursor")) != "" {
+		respondError(c, http.StatusBadRequest, "invalid_request", nil)
+		return
+	}
+	sinceSeconds, err := strconv.ParseInt(rawSince, 10, 64)
+	if err != nil || sinceSeconds < 0 {
+		respondError(c, http.StatusBadRequest, "invalid_since", nil)
+		return
+	}
+
+	if h.respondNotModified(c, userID) {
+		return
+	}
+
+	snapshots, err := h.notesService.ListCrdtSnapshotsSince(c.Request.Context(), userID, sinceSeconds)
+	if err != nil {
+		h.respondServiceError(c, err, "list_failed", "failed to list changed CRDT snapshots")
+		return
+	}
+
+	c.JSON(http.StatusOK, newCrdtSnapshotResponsePayload(snapshots, ""))
+}
+
+// respondNotModified sets a weak ETag for the listing and answers 304 when the client already holds it.
+func (h *httpHandler) respondNotModified(c *gin.Context, userID notes.UserID) bool {
+	version, err := h.notesService.GetCrdtListingVersion(c.Request.Context(), userID)
+	if err != nil {
+		h.loggerFor(c).Warn("failed to compute listing etag", zap.Error(err))
+		return false
+	}
+	etag := crdtListingETag(version, c.Request.URL.RawQuery)
+	c.Header(etagHeader, etag)
+	if !etagMatches(c.GetHeader(ifNoneMatchHeader), etag) {
+		return false
+	}
+	c.Status(http.StatusNotModified)
+	return true
+}
+
+func crdtListingETag(version notes.CrdtListingVersion, rawQuery string) string {
+	digest := sha256.Sum256([]byte(fmt.Sprintf("%d:%d:%d:%s", version.SnapshotCount, version.SnapshotCoverage, version.MaxUpdateID, rawQuery)))
+	return `W/"` + hex.EncodeToString(digest[:8]) + `"`
+}
+
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func newCrdtSnapshotResponsePayload(snapshots []notes.CrdtSnapshotRecord, nextCursor string) crdtSnapshotResponsePayload {
 	response := crdtSnapshotResponsePayload{
-		Protocol: crdtProtocolVersion,
-		Notes:    make([]crdtSnapshotNotePayload, 0, len(snapshots)),
+		Protocol:   crdtProtocolVersion,
+		Notes:      make([]crdtSnapshotNotePayload, 0, len(snapshots)),
+		NextCursor: nextCursor,
 	}
 
 	for _, snapshot := range snapshots {
@@ -384,34 +1170,43 @@ func (h *httpHandler) handleListNotes(c *gin.Context) {
 		})
 	}
 
-	c.JSON(http.StatusOK, response)
+	return response
 }
 
 func (h *httpHandler) handleNotesStream(c *gin.Context) {
 	if h.realtime == nil {
-		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "stream_unavailable"})
+		abortWithError(c, http.StatusServiceUnavailable, "stream_unavailable")
 		return
 	}
 	userID := c.GetString(userIDContextKey)
 	if userID == "" {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		abortWithError(c, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 	ctx := c.Request.Context()
 	stream, dispose := h.realtime.Subscribe(ctx, userID)
 	defer dispose()
-	h.logger.Info("realtime stream subscribed", zap.String("user_id", userID))
+	h.loggerFor(c).Info("realtime stream subscribed", zap.String("user_id", userID))
 
 	writer := c.Writer
 	writer.Header().Set("Content-Type", "text/event-stream")
 	writer.Header().Set("Cache-Control", "no-cache")
 	writer.Header().Set("Connection", "keep-alive")
-	flusher, _ := writer.(http.Flusher)
+	streamWriter := newStreamWriteController(writer, h.streamIdleTimeout, h.clock)
 
 	const heartbeatInterval = 25 * time.Second
 	heartbeat := time.NewTimer(heartbeatInterval)
 	defer heartbeat.Stop()
 
+	var sessionExpired <-chan time.Time
+	if expiry, ok := c.Get(sessionExpiryContextKey); ok {
+		if expiresAt, ok := expiry.(time.Time); ok && !expiresAt.IsZero() {
+			expiryTimer := time.NewTimer(expiresAt.Sub(h.clock()))
+			defer expiryTimer.Stop()
+			sessionExpired = expiryTimer.C
+		}
+	}
+
 	resetHeartbeat := func() {
 		if !heartbeat.Stop() {
 			select {
@@ -422,39 +1217,83 @@ func (h *httpHandler) handleNotesStream(c *gin.Context) {
 		heartbeat.Reset(heartbeatInterval)
 	}
 
+	writeEvent := func(event sse.Event) bool {
+		streamWriter.beginWrite()
+		c.Render(-1, event)
+		flushErr := streamWriter.flush()
+		if c.IsAborted() || flushErr != nil {
+			h.loggerFor(c).Warn("realtime stream closed after a stalled write",
+				zap.String("user_id", userID),
+				zap.Duration("since_last_flush", h.clock().Sub(streamWriter.lastFlushed)),
+				zap.Error(flushErr))
+			return false
+		}
+		resetHeartbeat()
+		return true
+	}
+
 	sendHeartbeat := func() bool {
-		c.Render(-1, sse.Event{
+		return writeEvent(sse.Event{
 			Event: realtimeEventHeartbeat,
 			Data: gin.H{
-				"timestamp": time.Now().UTC().Format(time.RFC3339Nano),
+				"timestamp": h.clock().UTC().Format(time.RFC3339Nano),
 				"source":    realtimeSourceBackend,
 			},
 		})
-		if flusher != nil {
-			flusher.Flush()
-		}
-		resetHeartbeat()
-		return true
 	}
 
 	sendMessage := func(message RealtimeMessage) bool {
 		timestamp := message.Timestamp
 		if timestamp.IsZero() {
-			timestamp = time.Now().UTC()
+			timestamp = h.clock().UTC()
+		}
+		data := gin.H{
+			"noteIds":   append([]string(nil), message.NoteIDs...),
+			"timestamp": timestamp.UTC().Format(time.RFC3339Nano),
+			"source":    realtimeSourceBackend,
 		}
-		c.Render(-1, sse.Event{
+		if len(message.Updates) > 0 {
+			data["updates"] = append([]RealtimeCrdtUpdate(nil), message.Updates...)
+		}
+		if message.Resync {
+			data["resync"] = true
+		}
+		event := sse.Event{
 			Event: message.EventType,
-			Data: gin.H{
-				"noteIds":   append([]string(nil), message.NoteIDs...),
-				"timestamp": timestamp.UTC().Format(time.RFC3339Nano),
-				"source":    realtimeSourceBackend,
-			},
-		})
-		if flusher != nil {
-			flusher.Flush()
+			Data:  data,
+		}
+		if message.ID != 0 {
+			event.Id = strconv.FormatInt(message.ID, 10)
+		}
+		return writeEvent(event)
+	}
+
+	// replayedThroughID is the newest identifier sent during replay. Live messages at or below it were
+	// already replayed; newer ones are always sent, since concurrent publishes may arrive out of order.
+	var replayedThroughID int64
+	sendLiveMessage := func(message RealtimeMessage) bool {
+		if message.ID != 0 && message.ID <= replayedThroughID {
+			return true
+		}
+		return sendMessage(message)
+	}
+
+	// ?ping=true asks for a heartbeat right away so clients can confirm the stream is live before the first tick.
+	if ping, err := strconv.ParseBool(strings.TrimSpace(c.Query("ping"))); err == nil && ping {
+		if !sendHeartbeat() {
+			return
+		}
+	}
+
+	if lastEventID, err := strconv.ParseInt(strings.TrimSpace(c.GetHeader(lastEventIDHeader)), 10, 64); err == nil && lastEventID >= 0 {
+		for _, message := range h.realtime.Replay(userID, lastEventID) {
+			if !sendMessage(message) {
+				return
+			}
+			if message.ID > replayedThroughID {
+				replayedThroughID = message.ID
+			}
 		}
-		resetHeartbeat()
-		return true
 	}
 
 	c.Stream(func(w io.Writer) bool {
@@ -469,25 +1308,28 @@ func (h *httpHandler) handleNotesStream(c *gin.Context) {
 			if !ok {
 				return false
 			}
-			return sendMessage(message)
+			return sendLiveMessage(message)
 		default:
 		}
 
 		select {
 		case <-ctx.Done():
 			return false
+		case <-sessionExpired:
+			h.loggerFor(c).Info("realtime stream closed at session expiry", zap.String("user_id", userID))
+			return false
 		case message, ok := <-stream:
 			if !ok {
 				return false
 			}
-			return sendMessage(message)
+			return sendLiveMessage(message)
 		case <-heartbeat.C:
 			select {
 			case message, ok := <-stream:
 				if !ok {
 					return false
 				}
-				return sendMessage(message)
+				return sendLiveMessage(message)
 			default:
 			}
 			return sendHeartbeat()
@@ -495,41 +1337,92 @@ func (h *httpHandler) handleNotesStream(c *gin.Context) {
 	})
 }
 
+func (h *httpHandler) handleLogout(c *gin.Context) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     h.sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+	token := h.extractToken(c)
+	if token != "" && h.sessionRevoker != nil {
+		if err := h.sessionRevoker.RevokeSession(token); err != nil {
+			h.loggerFor(c).Error("failed to revoke session", zap.Error(err))
+			respondError(c, http.StatusInternalServerError, "logout_failed", nil)
+			return
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
+
 func (h *httpHandler) authorizeRequest(c *gin.Context) {
 	token := h.extractToken(c)
 	if token == "" {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": errInvalidAuthorization.Error()})
+		h.metrics.observeAuth(authResultFailure, authReasonMissingToken)
+		abortWithError(c, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 	claims, err := h.sessions.ValidateToken(token)
 	if err != nil {
 		if errors.Is(err, auth.ErrExpiredSessionToken) {
-			h.logger.Info("session token validation failed", zap.Error(err))
+			h.metrics.observeAuth(authResultFailure, authReasonExpiredToken)
+			h.loggerFor(c).Info("session token validation failed", zap.Error(err))
 		} else {
-			h.logger.Warn("session token validation failed", zap.Error(err))
+			h.metrics.observeAuth(authResultFailure, authReasonInvalidToken)
+			h.loggerFor(c).Warn("session token validation failed", zap.Error(err))
 		}
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		abortWithError(c, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 	userID := strings.TrimSpace(claims.UserID)
 	if h.userIdentities != nil {
 		resolved, resolveErr := h.userIdentities.ResolveCanonicalUserID(claims)
 		if resolveErr != nil {
-			h.logger.Warn("user identity resolution failed", zap.Error(resolveErr))
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			h.metrics.observeAuth(authResultFailure, authReasonIdentityResolution)
+			h.loggerFor(c).Warn("user identity resolution failed", zap.Error(resolveErr))
+			abortWithError(c, http.StatusUnauthorized, "unauthorized")
 			return
 		}
 		userID = resolved
 	}
 	if userID == "" {
-		h.logger.Warn("resolved user id empty")
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		h.metrics.observeAuth(authResultFailure, authReasonEmptyUserID)
+		h.loggerFor(c).Warn("resolved user id empty")
+		abortWithError(c, http.StatusUnauthorized, "unauthorized")
 		return
 	}
+	h.metrics.observeAuth(authResultSuccess, authReasonNone)
 	c.Set(userIDContextKey, userID)
+	c.Set(sessionExpiryContextKey, claims.ExpiryTime())
+	c.Set(userRolesContextKey, claims.UserRoles)
 	c.Next()
 }
 
+// requireRole rejects requests whose authorized session does not carry role.
+// It must run after authorizeRequest, which stores the session roles.
+func requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasRole(c, role) {
+			abortWithError(c, http.StatusForbidden, "forbidden")
+			return
+		}
+		c.Next()
+	}
+}
+
+// hasRole reports whether the authorized session carries role.
+func hasRole(c *gin.Context, role string) bool {
+	roles, _ := c.Get(userRolesContextKey)
+	userRoles, _ := roles.([]string)
+	for _, userRole := range userRoles {
+		if strings.TrimSpace(userRole) == role {
+			return true
+		}
+	}
+	return false
+}
+
 func (h *httpHandler) extractToken(c *gin.Context) string {
 	if c.Request != nil {
 		if cookie, err := c.Request.Cookie(h.sessionCookie); err == nil && cookie != nil {
@@ -570,6 +1463,24 @@ func (adapter crdtOutcomeAdapter) Duplicate() bool {
 	return adapter.outcome.Duplicate()
 }
 
+func collectRealtimeCrdtUpdates(updates []notes.CrdtUpdateEnvelope, outcomes []notes.CrdtUpdateOutcome) []RealtimeCrdtUpdate {
+	if len(updates) != len(outcomes) {
+		return nil
+	}
+	realtimeUpdates := make([]RealtimeCrdtUpdate, 0, len(outcomes))
+	for index, outcome := range outcomes {
+		if outcome.Duplicate() {
+			continue
+		}
+		realtimeUpdates = append(realtimeUpdates, RealtimeCrdtUpdate{
+			NoteID:    outcome.NoteID().String(),
+			UpdateID:  outcome.UpdateID().Int64(),
+			UpdateB64: updates[index].UpdateB64().String(),
+		})
+	}
+	return realtimeUpdates
+}
+
 func collectAcceptedNoteIDs(outcomes []noteChangeOutcome) []string {
 	if len(outcomes) == 0 {
 		return nil
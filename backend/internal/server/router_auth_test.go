@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/MarcoPoloResearchLab/gravity/backend/internal/auth"
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/notes"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -124,6 +125,115 @@ func TestAuthorizeRequestPrefersCookieToken(t *testing.T) {
 	}
 }
 
+func TestRequireRoleGuardsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testCases := []struct {
+		name       string
+		roles      []string
+		wantStatus int
+	}{
+		{name: "with role", roles: []string{"viewer", "admin"}, wantStatus: http.StatusNoContent},
+		{name: "without role", roles: []string{"viewer"}, wantStatus: http.StatusForbidden},
+		{name: "no roles", roles: nil, wantStatus: http.StatusForbidden},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			engine := gin.New()
+			engine.GET("/guarded", func(c *gin.Context) {
+				c.Set(userIDContextKey, "user-roles")
+				c.Set(userRolesContextKey, testCase.roles)
+				c.Next()
+			}, requireRole("admin"), func(c *gin.Context) {
+				c.Status(http.StatusNoContent)
+			})
+
+			recorder := httptest.NewRecorder()
+			engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/guarded", http.NoBody))
+			if recorder.Code != testCase.wantStatus {
+				t.Fatalf("unexpected status code: got %d, want %d", recorder.Code, testCase.wantStatus)
+			}
+		})
+	}
+}
+
+func TestLogoutClearsSessionCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testCases := []struct {
+		name         string
+		sessionToken string
+		revokeErr    error
+		wantStatus   int
+		wantRevoked  []string
+	}{
+		{
+			name:       "without-session",
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:         "with-session",
+			sessionToken: "session-token",
+			wantStatus:   http.StatusNoContent,
+			wantRevoked:  []string{"session-token"},
+		},
+		{
+			name:         "revocation-failure",
+			sessionToken: "session-token",
+			revokeErr:    errors.New("revocation store unavailable"),
+			wantStatus:   http.StatusInternalServerError,
+			wantRevoked:  []string{"session-token"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			revoker := &recordingSessionRevoker{err: testCase.revokeErr}
+			handler, err := NewHTTPHandler(Dependencies{
+				SessionValidator: stubSessionValidator{},
+				SessionCookie:    "app_session",
+				SessionRevoker:   revoker,
+				NotesService:     &notes.Service{},
+				Logger:           zap.NewNop(),
+			})
+			if err != nil {
+				t.Fatalf("failed to construct handler: %v", err)
+			}
+
+			request := httptest.NewRequest(http.MethodPost, "/auth/logout", http.NoBody)
+			if testCase.sessionToken != "" {
+				request.AddCookie(&http.Cookie{Name: "app_session", Value: testCase.sessionToken})
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			if recorder.Code != testCase.wantStatus {
+				t.Fatalf("unexpected status code: got %d, want %d", recorder.Code, testCase.wantStatus)
+			}
+			cookies := recorder.Result().Cookies()
+			if len(cookies) != 1 || cookies[0].Name != "app_session" || cookies[0].MaxAge >= 0 || cookies[0].Value != "" {
+				t.Fatalf("expected session cookie to be cleared, got %v", cookies)
+			}
+			if len(revoker.tokens) != len(testCase.wantRevoked) {
+				t.Fatalf("unexpected revoked tokens: %v", revoker.tokens)
+			}
+			for index, token := range testCase.wantRevoked {
+				if revoker.tokens[index] != token {
+					t.Fatalf("unexpected revoked token at %d: %s", index, revoker.tokens[index])
+				}
+			}
+		})
+	}
+}
+
+type recordingSessionRevoker struct {
+	tokens []string
+	err    error
+}
+
+func (r *recordingSessionRevoker) RevokeSession(token string) error {
+	r.tokens = append(r.tokens, token)
+	return r.err
+}
+
 type stubSessionValidator struct {
 	expectedToken string
 	claims        auth.SessionClaims
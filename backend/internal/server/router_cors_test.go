@@ -1,11 +1,14 @@
 package server
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/notes"
 	"github.com/gin-gonic/gin"
 )
 
@@ -13,7 +16,7 @@ func TestCORSMiddlewareAllowsTenantHeader(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	router := gin.New()
-	router.Use(corsMiddleware())
+	router.Use(corsMiddleware(CORSConfig{}))
 	router.OPTIONS("/notes", func(c *gin.Context) {
 		c.Status(http.StatusOK)
 	})
@@ -38,3 +41,51 @@ func TestCORSMiddlewareAllowsTenantHeader(t *testing.T) {
 		t.Fatalf("expected credentials to be enabled")
 	}
 }
+
+func TestCORSMiddlewareAppliesConfiguredMethodsAndMaxAge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	preflight := func(cfg CORSConfig) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.Use(corsMiddleware(cfg))
+		router.DELETE("/account", func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+		request := httptest.NewRequest(http.MethodOptions, "/account", http.NoBody)
+		request.Header.Set("Origin", "https://app.example.com")
+		request.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	defaults := preflight(CORSConfig{})
+	if allowMethods := defaults.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(allowMethods, http.MethodDelete) {
+		t.Fatalf("expected default methods to allow DELETE, got %q", allowMethods)
+	}
+	if maxAge := defaults.Header().Get("Access-Control-Max-Age"); maxAge != "" {
+		t.Fatalf("expected no max age without configuration, got %q", maxAge)
+	}
+
+	configured := preflight(CORSConfig{AllowedMethods: []string{"get", "delete", "put"}, MaxAge: 10 * time.Minute})
+	if configured.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, configured.Code)
+	}
+	if allowMethods := configured.Header().Get("Access-Control-Allow-Methods"); allowMethods != "GET,DELETE,PUT" {
+		t.Fatalf("unexpected allowed methods %q", allowMethods)
+	}
+	if maxAge := configured.Header().Get("Access-Control-Max-Age"); maxAge != "600" {
+		t.Fatalf("expected max age of 600 seconds, got %q", maxAge)
+	}
+}
+
+func TestNewHTTPHandlerRejectsNegativeCORSMaxAge(t *testing.T) {
+	_, err := NewHTTPHandler(Dependencies{
+		SessionValidator: stubSessionValidator{},
+		NotesService:     &notes.Service{},
+		CORS:             CORSConfig{MaxAge: -time.Second},
+	})
+	if !errors.Is(err, errInvalidCORSMaxAge) {
+		t.Fatalf("expected errInvalidCORSMaxAge, got %v", err)
+	}
+}
@@ -0,0 +1,947 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/auth"
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/notes"
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/users"
+	githubsqlite "github.com/glebarez/sqlite"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	crdtPushUpdateB64   = "AQID"
+	crdtPushSnapshotB64 = "AQID"
+)
+
+func TestCrdtPushThenPullRoundTrip(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	pushBody := map[string]any{
+		"protocol": crdtProtocolVersion,
+		"updates": []map[string]any{
+			{
+				"note_id":            sessionNoteID,
+				"update_b64":         crdtPushUpdateB64,
+				"snapshot_b64":       crdtPushSnapshotB64,
+				"snapshot_update_id": 0,
+			},
+		},
+	}
+	var pushPayload struct {
+		Protocol string `json:"protocol"`
+		Results  []struct {
+			NoteID   string `json:"note_id"`
+			Accepted bool   `json:"accepted"`
+			UpdateID int64  `json:"update_id"`
+		} `json:"results"`
+	}
+	mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/push", sessionToken, pushBody, &pushPayload)
+	if pushPayload.Protocol != crdtProtocolVersion || len(pushPayload.Results) != 1 {
+		testContext.Fatalf("unexpected push response: %#v", pushPayload)
+	}
+	pushed := pushPayload.Results[0]
+	if !pushed.Accepted || pushed.NoteID != sessionNoteID || pushed.UpdateID == 0 {
+		testContext.Fatalf("unexpected push result: %#v", pushed)
+	}
+
+	pullBody := map[string]any{
+		"protocol": crdtProtocolVersion,
+		"cursors": []map[string]any{
+			{"note_id": sessionNoteID, "last_update_id": 0},
+		},
+	}
+	var pullPayload struct {
+		Protocol string `json:"protocol"`
+		Updates  []struct {
+			NoteID    string `json:"note_id"`
+			UpdateID  int64  `json:"update_id"`
+			UpdateB64 string `json:"update_b64"`
+		} `json:"updates"`
+	}
+	mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/pull", sessionToken, pullBody, &pullPayload)
+	if len(pullPayload.Updates) != 1 {
+		testContext.Fatalf("expected one pulled update, got %#v", pullPayload)
+	}
+	pulled := pullPayload.Updates[0]
+	if pulled.NoteID != sessionNoteID || pulled.UpdateID != pushed.UpdateID || pulled.UpdateB64 != crdtPushUpdateB64 {
+		testContext.Fatalf("pulled update does not match push: %#v", pulled)
+	}
+
+	snapshotRequest, err := http.NewRequest(http.MethodGet, server.URL+"/notes/crdt/snapshots", http.NoBody)
+	if err != nil {
+		testContext.Fatalf("failed to construct snapshots request: %v", err)
+	}
+	snapshotRequest.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+	snapshotResp, err := http.DefaultClient.Do(snapshotRequest)
+	if err != nil {
+		testContext.Fatalf("snapshots request failed: %v", err)
+	}
+	defer snapshotResp.Body.Close()
+	if snapshotResp.StatusCode != http.StatusOK {
+		testContext.Fatalf("unexpected snapshots status: %d", snapshotResp.StatusCode)
+	}
+	var snapshotPayload crdtSnapshotResponsePayload
+	if err := json.NewDecoder(snapshotResp.Body).Decode(&snapshotPayload); err != nil {
+		testContext.Fatalf("failed to decode snapshots response: %v", err)
+	}
+	if len(snapshotPayload.Notes) != 1 || snapshotPayload.Notes[0].NoteID != sessionNoteID {
+		testContext.Fatalf("unexpected snapshots response: %#v", snapshotPayload)
+	}
+}
+
+func TestCrdtPullPagesThroughUpdates(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	noteIDs := []string{"note-page-a", "note-page-b", "note-page-c"}
+	pushUpdates := make([]map[string]any, 0, len(noteIDs))
+	cursors := make([]crdtSyncCursorPayload, 0, len(noteIDs))
+	for _, noteID := range noteIDs {
+		pushUpdates = append(pushUpdates, map[string]any{"note_id": noteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0})
+		cursors = append(cursors, crdtSyncCursorPayload{NoteID: noteID})
+	}
+	var pushPayload crdtPushResponsePayload
+	mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/push", sessionToken, map[string]any{"protocol": crdtProtocolVersion, "updates": pushUpdates}, &pushPayload)
+
+	var pulledUpdateIDs []int64
+	for pageIndex := 0; ; pageIndex++ {
+		if pageIndex > len(noteIDs) {
+			testContext.Fatalf("expected paging to finish, pulled %v", pulledUpdateIDs)
+		}
+		var pullPayload crdtPullResponsePayload
+		mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/pull", sessionToken, map[string]any{"protocol": crdtProtocolVersion, "cursors": cursors, "limit": 2}, &pullPayload)
+		if len(pullPayload.Updates) > 2 {
+			testContext.Fatalf("expected at most two updates per page, got %d", len(pullPayload.Updates))
+		}
+		for _, update := range pullPayload.Updates {
+			pulledUpdateIDs = append(pulledUpdateIDs, update.UpdateID)
+		}
+		if len(pullPayload.NextCursors) != len(noteIDs) {
+			testContext.Fatalf("expected a next cursor per note, got %#v", pullPayload.NextCursors)
+		}
+		cursors = pullPayload.NextCursors
+		if !pullPayload.HasMore {
+			break
+		}
+	}
+
+	if len(pulledUpdateIDs) != len(pushPayload.Results) {
+		testContext.Fatalf("expected %d pulled updates, got %v", len(pushPayload.Results), pulledUpdateIDs)
+	}
+	for index, result := range pushPayload.Results {
+		if pulledUpdateIDs[index] != result.UpdateID {
+			testContext.Fatalf("expected pages in update order %v, got %v", pushPayload.Results, pulledUpdateIDs)
+		}
+	}
+}
+
+func TestCrdtPushPullRejectInvalidRequests(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	oversizedBatch := make([]map[string]any, notes.DefaultMaxUpdatesPerSync+1)
+	for index := range oversizedBatch {
+		oversizedBatch[index] = map[string]any{"note_id": sessionNoteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0}
+	}
+
+	testCases := []struct {
+		name string
+		path string
+		body map[string]any
+	}{
+		{name: "push-too-many-updates", path: "/notes/crdt/push", body: map[string]any{"protocol": crdtProtocolVersion, "updates": oversizedBatch}},
+		{name: "push-without-updates", path: "/notes/crdt/push", body: map[string]any{"protocol": crdtProtocolVersion}},
+		{name: "pull-without-cursors", path: "/notes/crdt/pull", body: map[string]any{"protocol": crdtProtocolVersion}},
+		{name: "pull-negative-limit", path: "/notes/crdt/pull", body: map[string]any{"protocol": crdtProtocolVersion, "cursors": []map[string]any{{"note_id": sessionNoteID, "last_update_id": 0}}, "limit": -1}},
+		{name: "pull-limit-above-maximum", path: "/notes/crdt/pull", body: map[string]any{"protocol": crdtProtocolVersion, "cursors": []map[string]any{{"note_id": sessionNoteID, "last_update_id": 0}}, "limit": notes.MaxListLimit + 1}},
+		{name: "push-wrong-protocol", path: "/notes/crdt/push", body: map[string]any{"protocol": "crdt-v0"}},
+	}
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(testContext *testing.T) {
+			encoded, err := json.Marshal(testCase.body)
+			if err != nil {
+				testContext.Fatalf("failed to encode request: %v", err)
+			}
+			request, err := http.NewRequest(http.MethodPost, server.URL+testCase.path, bytes.NewReader(encoded))
+			if err != nil {
+				testContext.Fatalf("failed to construct request: %v", err)
+			}
+			request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+			request.Header.Set("Content-Type", jsonContentType)
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				testContext.Fatalf("request failed: %v", err)
+			}
+			_ = response.Body.Close()
+			if response.StatusCode != http.StatusBadRequest {
+				testContext.Fatalf("expected bad request, got %d", response.StatusCode)
+			}
+		})
+	}
+}
+
+func mustPostCrdtJSON(testContext *testing.T, url, sessionToken string, body any, target any) {
+	testContext.Helper()
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		testContext.Fatalf("failed to encode request: %v", err)
+	}
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(encoded))
+	if err != nil {
+		testContext.Fatalf("failed to construct request: %v", err)
+	}
+	request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+	request.Header.Set("Content-Type", jsonContentType)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		testContext.Fatalf("request to %s failed: %v", url, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		testContext.Fatalf("unexpected status from %s: %d", url, response.StatusCode)
+	}
+	if err := json.NewDecoder(response.Body).Decode(target); err != nil {
+		testContext.Fatalf("failed to decode response from %s: %v", url, err)
+	}
+}
+
+func TestListNotesHonorsIfNoneMatch(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	push := func(updateB64 string) {
+		var pushPayload crdtPushResponsePayload
+		mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/push", sessionToken, map[string]any{
+			"protocol": crdtProtocolVersion,
+			"updates": []map[string]any{
+				{"note_id": sessionNoteID, "update_b64": updateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0},
+			},
+		}, &pushPayload)
+	}
+	list := func(ifNoneMatch string) (*http.Response, []byte) {
+		request, err := http.NewRequest(http.MethodGet, server.URL+"/notes", http.NoBody)
+		if err != nil {
+			testContext.Fatalf("failed to construct request: %v", err)
+		}
+		request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+		if ifNoneMatch != "" {
+			request.Header.Set(ifNoneMatchHeader, ifNoneMatch)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			testContext.Fatalf("list request failed: %v", err)
+		}
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		if err != nil {
+			testContext.Fatalf("failed to read list body: %v", err)
+		}
+		return response, body
+	}
+
+	push(crdtPushUpdateB64)
+	initial, _ := list("")
+	etag := initial.Header.Get(etagHeader)
+	if initial.StatusCode != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+		testContext.Fatalf("expected 200 with weak etag, got %d %q", initial.StatusCode, etag)
+	}
+
+	conditional, body := list(etag)
+	if conditional.StatusCode != http.StatusNotModified {
+		testContext.Fatalf("expected 304 for matching etag, got %d", conditional.StatusCode)
+	}
+	if len(body) != 0 {
+		testContext.Fatalf("expected empty 304 body, got %q", body)
+	}
+
+	push("AQIE")
+	changed, _ := list(etag)
+	if changed.StatusCode != http.StatusOK {
+		testContext.Fatalf("expected 200 after new update, got %d", changed.StatusCode)
+	}
+	if changed.Header.Get(etagHeader) == etag {
+		testContext.Fatal("expected etag to change after new update")
+	}
+}
+
+func TestBatchGetNotesSkipsForeignAndMissingNotes(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+	foreignToken := mustMintSessionToken(testContext, sessionSigningSecret, "user-batch-foreign", time.Now())
+
+	push := func(sessionToken, noteID string) {
+		var pushPayload crdtPushResponsePayload
+		mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/push", sessionToken, map[string]any{
+			"protocol": crdtProtocolVersion,
+			"updates": []map[string]any{
+				{"note_id": noteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0},
+			},
+		}, &pushPayload)
+	}
+	push(sessionToken, sessionNoteID)
+	push(foreignToken, "note-foreign")
+
+	var batchPayload crdtSnapshotResponsePayload
+	mustPostCrdtJSON(testContext, server.URL+"/notes/batch-get", sessionToken, []string{sessionNoteID, "note-foreign", "note-missing"}, &batchPayload)
+	if len(batchPayload.Notes) != 1 || batchPayload.Notes[0].NoteID != sessionNoteID {
+		testContext.Fatalf("expected only the requester's note, got %#v", batchPayload.Notes)
+	}
+	if batchPayload.Notes[0].SnapshotB64 == nil || *batchPayload.Notes[0].SnapshotB64 != crdtPushSnapshotB64 {
+		testContext.Fatalf("unexpected batch snapshot payload: %#v", batchPayload.Notes[0])
+	}
+
+	tooMany := make([]string, notes.MaxBatchNoteIDs+1)
+	for index := range tooMany {
+		tooMany[index] = sessionNoteID
+	}
+	encoded, err := json.Marshal(tooMany)
+	if err != nil {
+		testContext.Fatalf("failed to encode request: %v", err)
+	}
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/notes/batch-get", bytes.NewReader(encoded))
+	if err != nil {
+		testContext.Fatalf("failed to construct request: %v", err)
+	}
+	request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+	request.Header.Set("Content-Type", jsonContentType)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		testContext.Fatalf("batch-get request failed: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusBadRequest {
+		testContext.Fatalf("expected 400 for oversized batch, got %d", response.StatusCode)
+	}
+}
+
+func TestNoteTagsFilterListing(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+	foreignToken := mustMintSessionToken(testContext, sessionSigningSecret, "user-tags-foreign", time.Now())
+
+	for _, noteID := range []string{sessionNoteID, "note-untagged"} {
+		var pushPayload crdtPushResponsePayload
+		mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/push", sessionToken, map[string]any{
+			"protocol": crdtProtocolVersion,
+			"updates": []map[string]any{
+				{"note_id": noteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0},
+			},
+		}, &pushPayload)
+	}
+
+	var tagsPayload noteTagsResponsePayload
+	mustPostCrdtJSON(testContext, server.URL+"/v1/notes/"+sessionNoteID+"/tags", sessionToken, map[string]any{"tags": []string{" Work ", "work", "Ideas"}}, &tagsPayload)
+	if tagsPayload.NoteID != sessionNoteID || len(tagsPayload.Tags) != 2 || tagsPayload.Tags[0] != "ideas" || tagsPayload.Tags[1] != "work" {
+		testContext.Fatalf("unexpected tags response: %#v", tagsPayload)
+	}
+
+	get := func(sessionToken, path string) *http.Response {
+		request, err := http.NewRequest(http.MethodGet, server.URL+path, http.NoBody)
+		if err != nil {
+			testContext.Fatalf("failed to construct request: %v", err)
+		}
+		request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			testContext.Fatalf("request to %s failed: %v", path, err)
+		}
+		return response
+	}
+
+	response := get(sessionToken, "/v1/notes?tag=WORK")
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		testContext.Fatalf("expected tagged listing to succeed, got %d", response.StatusCode)
+	}
+	var listPayload crdtSnapshotResponsePayload
+	if err := json.NewDecoder(response.Body).Decode(&listPayload); err != nil {
+		testContext.Fatalf("failed to decode listing: %v", err)
+	}
+	if len(listPayload.Notes) != 1 || listPayload.Notes[0].NoteID != sessionNoteID {
+		testContext.Fatalf("expected only the tagged note, got %#v", listPayload.Notes)
+	}
+
+	foreignResponse := get(foreignToken, "/v1/notes?tag=work")
+	defer foreignResponse.Body.Close()
+	var foreignPayload crdtSnapshotResponsePayload
+	if err := json.NewDecoder(foreignResponse.Body).Decode(&foreignPayload); err != nil {
+		testContext.Fatalf("failed to decode foreign listing: %v", err)
+	}
+	if len(foreignPayload.Notes) != 0 {
+		testContext.Fatalf("expected no notes for another user's tag, got %#v", foreignPayload.Notes)
+	}
+
+	encoded, err := json.Marshal(map[string]any{"tags": []string{"mine"}})
+	if err != nil {
+		testContext.Fatalf("failed to encode request: %v", err)
+	}
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/v1/notes/"+sessionNoteID+"/tags", bytes.NewReader(encoded))
+	if err != nil {
+		testContext.Fatalf("failed to construct request: %v", err)
+	}
+	request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: foreignToken})
+	request.Header.Set("Content-Type", jsonContentType)
+	tagResponse, err := http.DefaultClient.Do(request)
+	if err != nil {
+		testContext.Fatalf("tag request failed: %v", err)
+	}
+	defer tagResponse.Body.Close()
+	if tagResponse.StatusCode != http.StatusNotFound {
+		testContext.Fatalf("expected 404 when tagging another user's note, got %d", tagResponse.StatusCode)
+	}
+}
+
+func TestListNotesStreamsNDJSON(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	noteIDs := []string{"note-ndjson-b", "note-ndjson-a", "note-ndjson-c"}
+	updates := make([]map[string]any, 0, len(noteIDs))
+	for _, noteID := range noteIDs {
+		updates = append(updates, map[string]any{"note_id": noteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0})
+	}
+	var pushPayload crdtPushResponsePayload
+	mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/push", sessionToken, map[string]any{
+		"protocol": crdtProtocolVersion,
+		"updates":  updates,
+	}, &pushPayload)
+
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/v1/notes?format=ndjson", http.NoBody)
+	if err != nil {
+		testContext.Fatalf("failed to construct request: %v", err)
+	}
+	request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+	request.Header.Set("Accept-Encoding", "gzip")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		testContext.Fatalf("ndjson request failed: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		testContext.Fatalf("expected 200, got %d", response.StatusCode)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != ndjsonContentType {
+		testContext.Fatalf("expected %s, got %q", ndjsonContentType, contentType)
+	}
+	if encoding := response.Header.Get("Content-Encoding"); encoding != "" {
+		testContext.Fatalf("expected streamed listing to bypass compression, got %q", encoding)
+	}
+
+	scanner := bufio.NewScanner(response.Body)
+	received := make([]string, 0, len(noteIDs))
+	for scanner.Scan() {
+		var note crdtSnapshotNotePayload
+		if err := json.Unmarshal(scanner.Bytes(), &note); err != nil {
+			testContext.Fatalf("line %d is not a note object: %v (%q)", len(received)+1, err, scanner.Text())
+		}
+		if note.SnapshotB64 == nil || *note.SnapshotB64 != crdtPushSnapshotB64 || note.SnapshotUpdateID == nil {
+			testContext.Fatalf("unexpected note line: %q", scanner.Text())
+		}
+		received = append(received, note.NoteID)
+	}
+	if err := scanner.Err(); err != nil {
+		testContext.Fatalf("failed to read ndjson: %v", err)
+	}
+	if expected := []string{"note-ndjson-a", "note-ndjson-b", "note-ndjson-c"}; strings.Join(received, ",") != strings.Join(expected, ",") {
+		testContext.Fatalf("expected notes %v in order, got %v", expected, received)
+	}
+}
+
+func TestGetCrdtSnapshotReturnsSingleNote(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+	foreignToken := mustMintSessionToken(testContext, sessionSigningSecret, "user-snapshot-foreign", time.Now())
+
+	var pushPayload crdtPushResponsePayload
+	mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/push", sessionToken, map[string]any{
+		"protocol": crdtProtocolVersion,
+		"updates": []map[string]any{
+			{"note_id": sessionNoteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0},
+		},
+	}, &pushPayload)
+
+	get := func(sessionToken, noteID string) *http.Response {
+		request, err := http.NewRequest(http.MethodGet, server.URL+"/v1/notes/"+noteID+"/crdt/snapshot", http.NoBody)
+		if err != nil {
+			testContext.Fatalf("failed to construct request: %v", err)
+		}
+		request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			testContext.Fatalf("snapshot request failed: %v", err)
+		}
+		return response
+	}
+
+	response := get(sessionToken, sessionNoteID)
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		testContext.Fatalf("expected 200, got %d", response.StatusCode)
+	}
+	var snapshotPayload crdtSnapshotDocumentResponsePayload
+	if err := json.NewDecoder(response.Body).Decode(&snapshotPayload); err != nil {
+		testContext.Fatalf("failed to decode snapshot: %v", err)
+	}
+	if snapshotPayload.NoteID != sessionNoteID || snapshotPayload.SnapshotB64 != crdtPushSnapshotB64 {
+		testContext.Fatalf("unexpected snapshot payload: %#v", snapshotPayload)
+	}
+
+	for _, probe := range []struct {
+		token  string
+		noteID string
+	}{
+		{token: sessionToken, noteID: "note-missing"},
+		{token: foreignToken, noteID: sessionNoteID},
+	} {
+		notFound := get(probe.token, probe.noteID)
+		var errorPayload errorResponse
+		if err := json.NewDecoder(notFound.Body).Decode(&errorPayload); err != nil {
+			testContext.Fatalf("failed to decode not-found response: %v", err)
+		}
+		_ = notFound.Body.Close()
+		if notFound.StatusCode != http.StatusNotFound || errorPayload.Error != "note_not_found" {
+			testContext.Fatalf("expected 404 note_not_found for %s, got %d %+v", probe.noteID, notFound.StatusCode, errorPayload)
+		}
+	}
+}
+
+func TestVersionedAndLegacyRoutesServeTheSameAPI(testContext *testing.T) {
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+	get := func(serverURL, path string) *http.Response {
+		request, err := http.NewRequest(http.MethodGet, serverURL+path, http.NoBody)
+		if err != nil {
+			testContext.Fatalf("failed to construct request: %v", err)
+		}
+		request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			testContext.Fatalf("request to %s failed: %v", path, err)
+		}
+		_ = response.Body.Close()
+		return response
+	}
+
+	transition := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	var pushPayload crdtPushResponsePayload
+	mustPostCrdtJSON(testContext, transition.URL+"/v1/notes/crdt/push", sessionToken, map[string]any{
+		"protocol": crdtProtocolVersion,
+		"updates": []map[string]any{
+			{"note_id": sessionNoteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0},
+		},
+	}, &pushPayload)
+
+	versioned := get(transition.URL, "/v1/notes")
+	if versioned.StatusCode != http.StatusOK || versioned.Header.Get(deprecationHeader) != "" {
+		testContext.Fatalf("unexpected /v1/notes response: %d deprecation=%q", versioned.StatusCode, versioned.Header.Get(deprecationHeader))
+	}
+	legacy := get(transition.URL, "/notes")
+	if legacy.StatusCode != http.StatusOK || legacy.Header.Get(deprecationHeader) != "true" {
+		testContext.Fatalf("unexpected /notes response: %d deprecation=%q", legacy.StatusCode, legacy.Header.Get(deprecationHeader))
+	}
+	if versioned.Header.Get(etagHeader) == "" || versioned.Header.Get(etagHeader) != legacy.Header.Get(etagHeader) {
+		testContext.Fatalf("expected both paths to list the same notes, got etags %q and %q", versioned.Header.Get(etagHeader), legacy.Header.Get(etagHeader))
+	}
+
+	versionedOnly := newIntegrationTestServerWithDependencies(testContext, Dependencies{DisableUnversionedRoutes: true})
+	if response := get(versionedOnly.URL, "/v1/notes"); response.StatusCode != http.StatusOK {
+		testContext.Fatalf("expected /v1/notes to stay available, got %d", response.StatusCode)
+	}
+	if response := get(versionedOnly.URL, "/notes"); response.StatusCode != http.StatusNotFound {
+		testContext.Fatalf("expected legacy /notes to be removed, got %d", response.StatusCode)
+	}
+}
+
+func TestAdminListUserNotesRequiresAdminRole(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	ownerToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+	adminToken := mustMintSessionTokenWithRoles(testContext, sessionSigningSecret, "user-support", []string{adminRole})
+
+	var pushPayload crdtPushResponsePayload
+	mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/push", ownerToken, map[string]any{
+		"protocol": crdtProtocolVersion,
+		"updates": []map[string]any{
+			{"note_id": sessionNoteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0},
+		},
+	}, &pushPayload)
+
+	get := func(sessionToken string) *http.Response {
+		request, err := http.NewRequest(http.MethodGet, server.URL+"/v1/admin/users/"+sessionUserID+"/notes", http.NoBody)
+		if err != nil {
+			testContext.Fatalf("failed to construct admin request: %v", err)
+		}
+		request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			testContext.Fatalf("admin request failed: %v", err)
+		}
+		return response
+	}
+
+	adminResponse := get(adminToken)
+	defer adminResponse.Body.Close()
+	if adminResponse.StatusCode != http.StatusOK {
+		testContext.Fatalf("expected admin to be allowed, got %d", adminResponse.StatusCode)
+	}
+	var listPayload crdtSnapshotResponsePayload
+	if err := json.NewDecoder(adminResponse.Body).Decode(&listPayload); err != nil {
+		testContext.Fatalf("failed to decode admin response: %v", err)
+	}
+	if len(listPayload.Notes) != 1 || listPayload.Notes[0].NoteID != sessionNoteID {
+		testContext.Fatalf("expected the target user's note, got %#v", listPayload.Notes)
+	}
+
+	regularResponse := get(ownerToken)
+	defer regularResponse.Body.Close()
+	if regularResponse.StatusCode != http.StatusForbidden {
+		testContext.Fatalf("expected regular user to be forbidden, got %d", regularResponse.StatusCode)
+	}
+	var errorPayload errorResponse
+	if err := json.NewDecoder(regularResponse.Body).Decode(&errorPayload); err != nil {
+		testContext.Fatalf("failed to decode forbidden response: %v", err)
+	}
+	if errorPayload.Error != "forbidden" {
+		testContext.Fatalf("unexpected forbidden response: %+v", errorPayload)
+	}
+}
+
+func TestAdminVerifyIntegrityRequiresAdminRole(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	ownerToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+	adminToken := mustMintSessionTokenWithRoles(testContext, sessionSigningSecret, "user-support", []string{adminRole})
+
+	var pushPayload crdtPushResponsePayload
+	mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/push", ownerToken, map[string]any{
+		"protocol": crdtProtocolVersion,
+		"updates": []map[string]any{
+			{"note_id": sessionNoteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0},
+		},
+	}, &pushPayload)
+
+	get := func(sessionToken string) *http.Response {
+		request, err := http.NewRequest(http.MethodGet, server.URL+"/v1/admin/users/"+sessionUserID+"/integrity", http.NoBody)
+		if err != nil {
+			testContext.Fatalf("failed to construct integrity request: %v", err)
+		}
+		request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			testContext.Fatalf("integrity request failed: %v", err)
+		}
+		return response
+	}
+
+	adminResponse := get(adminToken)
+	defer adminResponse.Body.Close()
+	if adminResponse.StatusCode != http.StatusOK {
+		testContext.Fatalf("expected admin to be allowed, got %d", adminResponse.StatusCode)
+	}
+	var report integrityReportResponsePayload
+	if err := json.NewDecoder(adminResponse.Body).Decode(&report); err != nil {
+		testContext.Fatalf("failed to decode integrity response: %v", err)
+	}
+	if report.UserID != sessionUserID || report.Issues == nil || len(report.Issues) != 0 {
+		testContext.Fatalf("expected a clean report for %s, got %+v", sessionUserID, report)
+	}
+
+	regularResponse := get(ownerToken)
+	defer regularResponse.Body.Close()
+	if regularResponse.StatusCode != http.StatusForbidden {
+		testContext.Fatalf("expected regular user to be forbidden, got %d", regularResponse.StatusCode)
+	}
+}
+
+func TestPutNoteMirrorsSingleUpdatePush(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	send := func(method, path string, body any) (int, []byte) {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			testContext.Fatalf("failed to encode request: %v", err)
+		}
+		request, err := http.NewRequest(method, server.URL+path, bytes.NewReader(encoded))
+		if err != nil {
+			testContext.Fatalf("failed to construct request: %v", err)
+		}
+		request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+		request.Header.Set("Content-Type", jsonContentType)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			testContext.Fatalf("request to %s failed: %v", path, err)
+		}
+		defer response.Body.Close()
+		payload, err := io.ReadAll(response.Body)
+		if err != nil {
+			testContext.Fatalf("failed to read response from %s: %v", path, err)
+		}
+		return response.StatusCode, payload
+	}
+	putNote := func(noteID, updateB64 string) (int, []byte) {
+		return send(http.MethodPut, "/v1/notes/"+noteID, map[string]any{
+			"protocol":           crdtProtocolVersion,
+			"update_b64":         updateB64,
+			"snapshot_b64":       crdtPushSnapshotB64,
+			"snapshot_update_id": 0,
+		})
+	}
+	pushNote := func(noteID, updateB64 string) (int, []byte) {
+		return send(http.MethodPost, "/v1/notes/crdt/push", map[string]any{
+			"protocol": crdtProtocolVersion,
+			"updates": []map[string]any{
+				{"note_id": noteID, "update_b64": updateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0},
+			},
+		})
+	}
+
+	status, body := putNote(sessionNoteID, crdtPushUpdateB64)
+	if status != http.StatusOK {
+		testContext.Fatalf("expected new note to be accepted, got %d: %s", status, body)
+	}
+	var created crdtPutNoteResponsePayload
+	if err := json.Unmarshal(body, &created); err != nil {
+		testContext.Fatalf("failed to decode put response: %v", err)
+	}
+	if created.Protocol != crdtProtocolVersion || created.Result.NoteID != sessionNoteID || !created.Result.Accepted || created.Result.Duplicate {
+		testContext.Fatalf("unexpected put result: %+v", created)
+	}
+
+	status, body = putNote(sessionNoteID, crdtPushUpdateB64)
+	var repeated crdtPutNoteResponsePayload
+	if err := json.Unmarshal(body, &repeated); err != nil || status != http.StatusOK {
+		testContext.Fatalf("unexpected repeated put: %d %s", status, body)
+	}
+	var pushed crdtPushResponsePayload
+	status, body = pushNote(sessionNoteID, crdtPushUpdateB64)
+	if err := json.Unmarshal(body, &pushed); err != nil || status != http.StatusOK || len(pushed.Results) != 1 {
+		testContext.Fatalf("unexpected repeated push: %d %s", status, body)
+	}
+	if repeated.Result != pushed.Results[0] || !repeated.Result.Duplicate || repeated.Result.UpdateID != created.Result.UpdateID {
+		testContext.Fatalf("expected put and push to report the same duplicate, got %+v and %+v", repeated.Result, pushed.Results[0])
+	}
+
+	putStatus, putBody := putNote(sessionNoteID, "not base64!")
+	pushStatus, pushBody := pushNote(sessionNoteID, "not base64!")
+	var putError, pushError errorResponse
+	if err := json.Unmarshal(putBody, &putError); err != nil {
+		testContext.Fatalf("failed to decode put error: %v", err)
+	}
+	if err := json.Unmarshal(pushBody, &pushError); err != nil {
+		testContext.Fatalf("failed to decode push error: %v", err)
+	}
+	if putStatus != http.StatusBadRequest || putStatus != pushStatus || putError.Error != "invalid_update" || putError.Error != pushError.Error {
+		testContext.Fatalf("expected matching invalid_update rejections, got %d %+v and %d %+v", putStatus, putError, pushStatus, pushError)
+	}
+}
+
+func mustMintSessionTokenWithRoles(testContext *testing.T, signingSecret, userID string, roles []string) string {
+	testContext.Helper()
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.SessionClaims{
+		UserID:    userID,
+		UserRoles: roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    sessionIssuer,
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+			NotBefore: jwt.NewNumericDate(now.Add(-time.Minute)),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	})
+	signed, err := token.SignedString([]byte(signingSecret))
+	if err != nil {
+		testContext.Fatalf("failed to sign session token: %v", err)
+	}
+	return signed
+}
+
+func TestAccountExportReturnsRequesterData(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	var pushPayload crdtPushResponsePayload
+	mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/push", sessionToken, map[string]any{
+		"protocol": crdtProtocolVersion,
+		"updates": []map[string]any{
+			{"note_id": sessionNoteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0},
+		},
+	}, &pushPayload)
+	var tagsPayload noteTagsResponsePayload
+	mustPostCrdtJSON(testContext, server.URL+"/notes/"+sessionNoteID+"/tags", sessionToken, map[string]any{"tags": []string{"work"}}, &tagsPayload)
+
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/account/export", http.NoBody)
+	if err != nil {
+		testContext.Fatalf("failed to construct export request: %v", err)
+	}
+	request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		testContext.Fatalf("export request failed: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		testContext.Fatalf("unexpected export status: %d", response.StatusCode)
+	}
+	var exportPayload accountExportResponsePayload
+	if err := json.NewDecoder(response.Body).Decode(&exportPayload); err != nil {
+		testContext.Fatalf("failed to decode export response: %v", err)
+	}
+	if exportPayload.UserID != sessionUserID || len(exportPayload.Notes) != 1 || len(exportPayload.Updates) != 1 {
+		testContext.Fatalf("unexpected export response: %#v", exportPayload)
+	}
+	if exportPayload.Updates[0].UpdateB64 != crdtPushUpdateB64 {
+		testContext.Fatalf("unexpected exported update: %#v", exportPayload.Updates[0])
+	}
+	if tags := exportPayload.Tags[sessionNoteID]; len(tags) != 1 || tags[0] != "work" {
+		testContext.Fatalf("expected the export to include note tags, got %#v", exportPayload.Tags)
+	}
+}
+
+func TestAccountStatsReportsRequesterUsage(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	var pushPayload crdtPushResponsePayload
+	mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/push", sessionToken, map[string]any{
+		"protocol": crdtProtocolVersion,
+		"updates": []map[string]any{
+			{"note_id": sessionNoteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0},
+		},
+	}, &pushPayload)
+
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/account/stats", http.NoBody)
+	if err != nil {
+		testContext.Fatalf("failed to construct stats request: %v", err)
+	}
+	request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		testContext.Fatalf("stats request failed: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		testContext.Fatalf("unexpected stats status: %d", response.StatusCode)
+	}
+	var statsPayload accountStatsResponsePayload
+	if err := json.NewDecoder(response.Body).Decode(&statsPayload); err != nil {
+		testContext.Fatalf("failed to decode stats response: %v", err)
+	}
+	expected := accountStatsResponsePayload{
+		NoteCount:     1,
+		UpdateCount:   1,
+		SnapshotBytes: int64(len(crdtPushSnapshotB64)),
+		UpdateBytes:   int64(len(crdtPushUpdateB64)),
+	}
+	if statsPayload != expected {
+		testContext.Fatalf("unexpected stats response: got %+v want %+v", statsPayload, expected)
+	}
+}
+
+func TestAccountDeleteRemovesOnlyRequesterData(testContext *testing.T) {
+	server := newIntegrationTestServer(testContext, NewRealtimeDispatcher())
+	deletedToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+	retainedToken := mustMintSessionToken(testContext, sessionSigningSecret, "user-retained", time.Now())
+
+	for _, sessionToken := range []string{deletedToken, retainedToken} {
+		var pushPayload crdtPushResponsePayload
+		mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/push", sessionToken, map[string]any{
+			"protocol": crdtProtocolVersion,
+			"updates": []map[string]any{
+				{"note_id": sessionNoteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0},
+			},
+		}, &pushPayload)
+	}
+
+	send := func(method, path, sessionToken string) *http.Response {
+		request, err := http.NewRequest(method, server.URL+path, http.NoBody)
+		if err != nil {
+			testContext.Fatalf("failed to construct request: %v", err)
+		}
+		request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			testContext.Fatalf("%s request failed: %v", method, err)
+		}
+		return response
+	}
+	export := func(sessionToken string) accountExportResponsePayload {
+		response := send(http.MethodGet, "/account/export", sessionToken)
+		defer response.Body.Close()
+		var payload accountExportResponsePayload
+		if err := json.NewDecoder(response.Body).Decode(&payload); err != nil {
+			testContext.Fatalf("failed to decode export response: %v", err)
+		}
+		return payload
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		response := send(http.MethodDelete, "/account", deletedToken)
+		_ = response.Body.Close()
+		if response.StatusCode != http.StatusNoContent {
+			testContext.Fatalf("delete attempt %d: expected 204, got %d", attempt, response.StatusCode)
+		}
+	}
+
+	if deleted := export(deletedToken); len(deleted.Notes) != 0 || len(deleted.Updates) != 0 {
+		testContext.Fatalf("expected deleted account to be empty, got %#v", deleted)
+	}
+	if retained := export(retainedToken); len(retained.Notes) != 1 || len(retained.Updates) != 1 {
+		testContext.Fatalf("expected other account to be untouched, got %#v", retained)
+	}
+}
+
+func TestSyncUsesCanonicalUserIDForProviderPrefixedClaims(testContext *testing.T) {
+	identityDatabase, err := gorm.Open(githubsqlite.Open(filepath.Join(testContext.TempDir(), "identities.db")), &gorm.Config{})
+	if err != nil {
+		testContext.Fatalf("failed to open identity database: %v", err)
+	}
+	if err := identityDatabase.AutoMigrate(&users.Identity{}); err != nil {
+		testContext.Fatalf("failed to migrate identity schema: %v", err)
+	}
+	identityService, err := users.NewService(users.ServiceConfig{Database: identityDatabase})
+	if err != nil {
+		testContext.Fatalf("failed to construct identity service: %v", err)
+	}
+	server := newIntegrationTestServerWithDependencies(testContext, Dependencies{
+		Realtime:       NewRealtimeDispatcher(),
+		UserIdentities: identityService,
+	})
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, "google:12345", time.Now())
+
+	var pushPayload crdtPushResponsePayload
+	mustPostCrdtJSON(testContext, server.URL+"/notes/crdt/push", sessionToken, map[string]any{
+		"protocol": crdtProtocolVersion,
+		"updates": []map[string]any{
+			{"note_id": sessionNoteID, "update_b64": crdtPushUpdateB64, "snapshot_b64": crdtPushSnapshotB64, "snapshot_update_id": 0},
+		},
+	}, &pushPayload)
+
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/account/export", http.NoBody)
+	if err != nil {
+		testContext.Fatalf("failed to construct export request: %v", err)
+	}
+	request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		testContext.Fatalf("export request failed: %v", err)
+	}
+	defer response.Body.Close()
+	var exportPayload accountExportResponsePayload
+	if err := json.NewDecoder(response.Body).Decode(&exportPayload); err != nil {
+		testContext.Fatalf("failed to decode export response: %v", err)
+	}
+	if exportPayload.UserID != "12345" || len(exportPayload.Updates) != 1 {
+		testContext.Fatalf("expected sync stored under canonical id 12345, got %#v", exportPayload)
+	}
+}
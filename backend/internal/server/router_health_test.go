@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/notes"
+	"github.com/gin-gonic/gin"
+	githubsqlite "github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestHealthProbes(testContext *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testCases := []struct {
+		name           string
+		path           string
+		closeDatabase  bool
+		expectedStatus int
+		expectedBody   string
+		expectedError  string
+	}{
+		{name: "liveness", path: "/healthz", expectedStatus: http.StatusOK, expectedBody: "ok"},
+		{name: "readiness", path: "/readyz", expectedStatus: http.StatusOK, expectedBody: "ready"},
+		{name: "liveness-with-closed-database", path: "/healthz", closeDatabase: true, expectedStatus: http.StatusOK, expectedBody: "ok"},
+		{name: "readiness-with-closed-database", path: "/readyz", closeDatabase: true, expectedStatus: http.StatusServiceUnavailable, expectedError: "database_unreachable"},
+	}
+
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(testContext *testing.T) {
+			db, err := gorm.Open(githubsqlite.Open(filepath.Join(testContext.TempDir(), "health.db")), &gorm.Config{})
+			if err != nil {
+				testContext.Fatalf("failed to open database: %v", err)
+			}
+			sqlDB, err := db.DB()
+			if err != nil {
+				testContext.Fatalf("failed to access sql database: %v", err)
+			}
+			if testCase.closeDatabase {
+				if err := sqlDB.Close(); err != nil {
+					testContext.Fatalf("failed to close database: %v", err)
+				}
+			} else {
+				testContext.Cleanup(func() { _ = sqlDB.Close() })
+			}
+
+			handler, err := NewHTTPHandler(Dependencies{
+				SessionValidator: stubSessionValidator{},
+				NotesService:     &notes.Service{},
+				Logger:           zap.NewNop(),
+				Pinger:           sqlDB,
+			})
+			if err != nil {
+				testContext.Fatalf("failed to build handler: %v", err)
+			}
+
+			request := httptest.NewRequest(http.MethodGet, testCase.path, http.NoBody)
+			request.Header.Set("Origin", "https://app.example.com")
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			if recorder.Code != testCase.expectedStatus {
+				testContext.Fatalf("expected status %d, got %d", testCase.expectedStatus, recorder.Code)
+			}
+			var payload map[string]any
+			if err := json.Unmarshal(recorder.Body.Bytes(), &payload); err != nil {
+				testContext.Fatalf("failed to decode payload: %v", err)
+			}
+			if testCase.expectedError != "" {
+				if payload["error"] != testCase.expectedError || payload["code"] != testCase.expectedError {
+					testContext.Fatalf("expected error %q, got %v", testCase.expectedError, payload)
+				}
+			} else if payload["status"] != testCase.expectedBody {
+				testContext.Fatalf("expected status %q, got %v", testCase.expectedBody, payload["status"])
+			}
+			if origin := recorder.Header().Get("Access-Control-Allow-Origin"); origin != "" {
+				testContext.Fatalf("expected probes to bypass CORS handling, got %q", origin)
+			}
+		})
+	}
+}
@@ -1,9 +1,13 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -38,7 +42,7 @@ func TestHandleNotesSyncRejectsEmptyNoteID(testContext *testing.T) {
 	if recorder.Code != http.StatusBadRequest {
 		testContext.Fatalf("expected bad request status, got %d", recorder.Code)
 	}
-	expected := `{"error":"invalid_note_id"}`
+	expected := `{"error":"invalid_note_id","code":"invalid_note_id","message":"note id is missing or invalid","operation_index":0}`
 	if recorder.Body.String() != expected {
 		testContext.Fatalf("unexpected response body: %s", recorder.Body.String())
 	}
@@ -65,7 +69,7 @@ func TestHandleNotesSyncRejectsInvalidProtocol(testContext *testing.T) {
 	if recorder.Code != http.StatusBadRequest {
 		testContext.Fatalf("expected bad request status, got %d", recorder.Code)
 	}
-	expected := `{"error":"invalid_protocol"}`
+	expected := `{"error":"invalid_protocol","code":"invalid_protocol","message":"unsupported sync protocol version"}`
 	if recorder.Body.String() != expected {
 		testContext.Fatalf("unexpected response body: %s", recorder.Body.String())
 	}
@@ -101,6 +105,93 @@ func TestHandleNotesSyncIncludesServiceErrorCode(testContext *testing.T) {
 	}
 }
 
+func TestErrorResponsesShareUnifiedShape(testContext *testing.T) {
+	testCases := []struct {
+		name         string
+		body         string
+		serve        gin.HandlerFunc
+		wantStatus   int
+		wantResponse errorResponse
+	}{
+		{
+			name:       "validation-error",
+			body:       `{"protocol":"crdt-v1","updates":[{"note_id":"note-1","update_b64":"` + validUpdateB64 + `","snapshot_b64":"` + validSnapshotB64 + `","snapshot_update_id":0}]}`,
+			wantStatus: http.StatusBadRequest,
+			wantResponse: errorResponse{
+				Error:          "missing_cursor",
+				Code:           "missing_cursor",
+				Message:        errorMessages["missing_cursor"],
+				RequestID:      "request-unified",
+				OperationIndex: new(int),
+				NoteID:         "note-1",
+			},
+		},
+		{
+			name:       "service-error",
+			body:       `{"protocol":"crdt-v1","updates":[{"note_id":"note-1","update_b64":"` + validUpdateB64 + `","snapshot_b64":"` + validSnapshotB64 + `","snapshot_update_id":0}],"cursors":[{"note_id":"note-1","last_update_id":0}]}`,
+			wantStatus: http.StatusInternalServerError,
+			wantResponse: errorResponse{
+				Error:     "sync_failed",
+				Code:      "notes.apply_crdt_updates.missing_database",
+				Message:   errorMessages["sync_failed"],
+				RequestID: "request-unified",
+			},
+		},
+		{
+			name:       "readiness-error",
+			serve:      newReadyzHandler(failingPinger{}, zap.NewNop()),
+			wantStatus: http.StatusServiceUnavailable,
+			wantResponse: errorResponse{
+				Error:     "database_unreachable",
+				Code:      "database_unreachable",
+				Message:   errorMessages["database_unreachable"],
+				RequestID: "request-unified",
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(testContext *testing.T) {
+			gin.SetMode(gin.TestMode)
+			recorder := httptest.NewRecorder()
+			context, _ := gin.CreateTestContext(recorder)
+			context.Set(userIDContextKey, "user-1")
+			context.Set(requestIDContextKey, "request-unified")
+
+			request := httptest.NewRequest(http.MethodPost, "/notes/sync", strings.NewReader(testCase.body))
+			request.Header.Set("Content-Type", "application/json")
+			context.Request = request
+
+			serve := testCase.serve
+			if serve == nil {
+				handler := &httpHandler{
+					notesService: &notes.Service{},
+					logger:       zap.NewNop(),
+				}
+				serve = handler.handleNotesSync
+			}
+			serve(context)
+
+			if recorder.Code != testCase.wantStatus {
+				testContext.Fatalf("expected status %d, got %d", testCase.wantStatus, recorder.Code)
+			}
+			var payload errorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &payload); err != nil {
+				testContext.Fatalf("failed to decode response: %v", err)
+			}
+			if !reflect.DeepEqual(payload, testCase.wantResponse) {
+				testContext.Fatalf("unexpected error body: got %+v want %+v", payload, testCase.wantResponse)
+			}
+		})
+	}
+}
+
+// failingPinger reports an unreachable database to the readiness probe.
+type failingPinger struct{}
+
+func (failingPinger) PingContext(context.Context) error {
+	return errors.New("database unreachable")
+}
+
 func TestHandleListNotesIncludesServiceErrorCode(testContext *testing.T) {
 	gin.SetMode(gin.TestMode)
 	recorder := httptest.NewRecorder()
@@ -129,6 +220,135 @@ func TestHandleListNotesIncludesServiceErrorCode(testContext *testing.T) {
 	}
 }
 
+func TestServiceErrorSitesShareUnifiedShape(testContext *testing.T) {
+	testCases := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		accept   string
+		params   gin.Params
+		handle   func(*httpHandler, *gin.Context)
+		wantCode string
+	}{
+		{name: "batch-get", method: http.MethodPost, target: "/notes/batch-get", body: `["note-1"]`, handle: (*httpHandler).handleBatchGetNotes, wantCode: "list_failed"},
+		{name: "list-since", method: http.MethodGet, target: "/notes?since=0", handle: (*httpHandler).handleListNotes, wantCode: "list_failed"},
+		{name: "list-by-tag", method: http.MethodGet, target: "/notes?tag=work", handle: (*httpHandler).handleListNotes, wantCode: "list_failed"},
+		{name: "list-ndjson", method: http.MethodGet, target: "/notes", accept: ndjsonContentType, handle: (*httpHandler).handleListNotes, wantCode: "list_failed"},
+		{name: "get-snapshot", method: http.MethodGet, target: "/notes/note-1/crdt/snapshot", params: gin.Params{{Key: "noteId", Value: "note-1"}}, handle: (*httpHandler).handleGetCrdtSnapshot, wantCode: "list_failed"},
+		{name: "set-tags", method: http.MethodPost, target: "/notes/note-1/tags", body: `{"tags":["work"]}`, params: gin.Params{{Key: "noteId", Value: "note-1"}}, handle: (*httpHandler).handleSetNoteTags, wantCode: "tags_failed"},
+		{name: "account-export", method: http.MethodGet, target: "/account/export", handle: (*httpHandler).handleAccountExport, wantCode: "export_failed"},
+		{name: "account-stats", method: http.MethodGet, target: "/account/stats", handle: (*httpHandler).handleAccountStats, wantCode: "stats_failed"},
+		{name: "account-delete", method: http.MethodDelete, target: "/account", handle: (*httpHandler).handleAccountDelete, wantCode: "delete_failed"},
+		{name: "admin-list", method: http.MethodGet, target: "/admin/users/user-2/notes", params: gin.Params{{Key: "userId", Value: "user-2"}}, handle: (*httpHandler).handleAdminListUserNotes, wantCode: "list_failed"},
+		{name: "admin-integrity", method: http.MethodGet, target: "/admin/users/user-2/integrity", params: gin.Params{{Key: "userId", Value: "user-2"}}, handle: (*httpHandler).handleAdminVerifyIntegrity, wantCode: "integrity_check_failed"},
+	}
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(testContext *testing.T) {
+			gin.SetMode(gin.TestMode)
+			recorder := httptest.NewRecorder()
+			context, _ := gin.CreateTestContext(recorder)
+			context.Set(userIDContextKey, "user-1")
+			context.Set(requestIDContextKey, "request-service")
+			context.Params = testCase.params
+
+			request := httptest.NewRequest(testCase.method, testCase.target, strings.NewReader(testCase.body))
+			request.Header.Set("Content-Type", "application/json")
+			if testCase.accept != "" {
+				request.Header.Set("Accept", testCase.accept)
+			}
+			context.Request = request
+
+			handler := &httpHandler{
+				notesService: &notes.Service{},
+				logger:       zap.NewNop(),
+			}
+			testCase.handle(handler, context)
+
+			if recorder.Code != http.StatusInternalServerError {
+				testContext.Fatalf("expected internal server error status, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+			var payload errorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &payload); err != nil {
+				testContext.Fatalf("failed to decode response: %v", err)
+			}
+			if payload.Error != testCase.wantCode || payload.RequestID != "request-service" || !strings.HasSuffix(payload.Code, ".missing_database") {
+				testContext.Fatalf("unexpected error body: %+v", payload)
+			}
+		})
+	}
+}
+
+func TestParseCrdtSyncUpdatesClampsSnapshotCoverage(testContext *testing.T) {
+	payload := crdtSyncUpdatePayload{NoteID: "note-1", UpdateB64: validUpdateB64, SnapshotB64: validSnapshotB64, SnapshotUpdateID: 1 << 40}
+	testCases := []struct {
+		name             string
+		cursorByNoteID   map[string]int64
+		requireCursors   bool
+		expectedID       int64
+		expectedRejected string
+	}{
+		{name: "push-without-cursor", cursorByNoteID: nil, expectedID: 0},
+		{name: "push-with-cursor", cursorByNoteID: map[string]int64{"note-1": 7}, expectedID: 7},
+		{name: "sync-with-cursor", cursorByNoteID: map[string]int64{"note-1": 7}, requireCursors: true, expectedID: 7},
+		{name: "sync-without-cursor", cursorByNoteID: map[string]int64{}, requireCursors: true, expectedRejected: "missing_cursor"},
+	}
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(t *testing.T) {
+			updates, failure := parseCrdtSyncUpdates(notes.UserID("user-1"), []crdtSyncUpdatePayload{payload}, testCase.cursorByNoteID, testCase.requireCursors)
+			if testCase.expectedRejected != "" {
+				if failure == nil || failure.code != testCase.expectedRejected {
+					t.Fatalf("expected %s rejection, got %+v", testCase.expectedRejected, failure)
+				}
+				return
+			}
+			if failure != nil {
+				t.Fatalf("unexpected rejection: %+v", failure)
+			}
+			if got := updates[0].SnapshotUpdateID().Int64(); got != testCase.expectedID {
+				t.Fatalf("expected snapshot update id %d, got %d", testCase.expectedID, got)
+			}
+		})
+	}
+}
+
+func TestStatusForServiceErrorMapsKnownCodes(testContext *testing.T) {
+	_, missingDatabaseErr := (&notes.Service{}).ListCrdtUpdates(context.Background(), notes.UserID("user-1"), nil)
+	if missingDatabaseErr == nil {
+		testContext.Fatal("expected a missing database error from an unconfigured service")
+	}
+
+	testCases := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedError  string
+		expectedCode   string
+	}{
+		{name: "missing-database", err: missingDatabaseErr, expectedStatus: http.StatusInternalServerError, expectedError: "sync_failed", expectedCode: "notes.list_crdt_updates.missing_database"},
+		{name: "payload-too-large", err: fmt.Errorf("apply: %w", notes.ErrPayloadTooLarge), expectedStatus: http.StatusRequestEntityTooLarge, expectedError: "payload_too_large", expectedCode: "payload_too_large"},
+		{name: "note-quota-exceeded", err: fmt.Errorf("apply: %w", notes.ErrNoteQuotaExceeded), expectedStatus: http.StatusForbidden, expectedError: "note_quota_exceeded", expectedCode: "note_quota_exceeded"},
+		{name: "too-many-updates", err: fmt.Errorf("apply: %w", notes.ErrTooManyUpdates), expectedStatus: http.StatusBadRequest, expectedError: "too_many_operations", expectedCode: "too_many_operations"},
+		{name: "sync-timeout", err: fmt.Errorf("apply: %w", notes.ErrSyncTimeout), expectedStatus: http.StatusGatewayTimeout, expectedError: "sync_timeout", expectedCode: "sync_timeout"},
+		{name: "note-not-found", err: fmt.Errorf("get: %w", notes.ErrNoteNotFound), expectedStatus: http.StatusNotFound, expectedError: "note_not_found", expectedCode: "note_not_found"},
+		{name: "unknown", err: errors.New("disk on fire"), expectedStatus: http.StatusInternalServerError, expectedError: "sync_failed", expectedCode: "sync_failed"},
+	}
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(t *testing.T) {
+			status, response := statusForServiceError(testCase.err, "sync_failed")
+			if status != testCase.expectedStatus {
+				t.Fatalf("expected status %d, got %d", testCase.expectedStatus, status)
+			}
+			if response.Error != testCase.expectedError || response.Code != testCase.expectedCode {
+				t.Fatalf("unexpected response %+v", response)
+			}
+			if response.Message != errorMessages[testCase.expectedError] {
+				t.Fatalf("expected message for %s, got %q", testCase.expectedError, response.Message)
+			}
+		})
+	}
+}
+
 func TestHandleNotesSyncValidationFailures(testContext *testing.T) {
 	gin.SetMode(gin.TestMode)
 	testCases := []struct {
@@ -145,13 +365,13 @@ func TestHandleNotesSyncValidationFailures(testContext *testing.T) {
 		},
 		{
 			name:       "invalid-update-b64",
-			body:       `{"protocol":"crdt-v1","updates":[{"note_id":"note-1","update_b64":"not-base64","snapshot_b64":"` + validSnapshotB64 + `","snapshot_update_id":0}],"cursors":[{"note_id":"note-1","last_update_id":0}]}`,
+			body:       `{"protocol":"crdt-v1","updates":[{"note_id":"note-1","update_b64":"not base64!","snapshot_b64":"` + validSnapshotB64 + `","snapshot_update_id":0}],"cursors":[{"note_id":"note-1","last_update_id":0}]}`,
 			wantError:  "invalid_update",
 			wantStatus: http.StatusBadRequest,
 		},
 		{
 			name:       "invalid-snapshot-b64",
-			body:       `{"protocol":"crdt-v1","updates":[{"note_id":"note-1","update_b64":"` + validUpdateB64 + `","snapshot_b64":"not-base64","snapshot_update_id":0}],"cursors":[{"note_id":"note-1","last_update_id":0}]}`,
+			body:       `{"protocol":"crdt-v1","updates":[{"note_id":"note-1","update_b64":"` + validUpdateB64 + `","snapshot_b64":"not base64!","snapshot_update_id":0}],"cursors":[{"note_id":"note-1","last_update_id":0}]}`,
 			wantError:  "invalid_snapshot",
 			wantStatus: http.StatusBadRequest,
 		},
@@ -206,3 +426,107 @@ func TestHandleNotesSyncValidationFailures(testContext *testing.T) {
 		})
 	}
 }
+
+func TestHandleNotesSyncReportsFailingOperationIndex(testContext *testing.T) {
+	gin.SetMode(gin.TestMode)
+	validUpdate := func(noteID string) string {
+		return `{"note_id":"` + noteID + `","update_b64":"` + validUpdateB64 + `","snapshot_b64":"` + validSnapshotB64 + `","snapshot_update_id":0}`
+	}
+	cursors := `"cursors":[{"note_id":"note-1","last_update_id":0},{"note_id":"note-2","last_update_id":0},{"note_id":"note-3","last_update_id":0}]`
+	testCases := []struct {
+		name       string
+		failing    string
+		wantError  string
+		wantNoteID any
+	}{
+		{
+			name:      "invalid-note-id",
+			failing:   `{"note_id":"  ","update_b64":"` + validUpdateB64 + `","snapshot_b64":"` + validSnapshotB64 + `","snapshot_update_id":0}`,
+			wantError: "invalid_note_id",
+		},
+		{
+			name:       "invalid-update-b64",
+			failing:    `{"note_id":"note-3","update_b64":"not base64!","snapshot_b64":"` + validSnapshotB64 + `","snapshot_update_id":0}`,
+			wantError:  "invalid_update",
+			wantNoteID: "note-3",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(testContext *testing.T) {
+			body := `{"protocol":"crdt-v1","updates":[` + validUpdate("note-1") + `,` + validUpdate("note-2") + `,` + testCase.failing + `],` + cursors + `}`
+			recorder := httptest.NewRecorder()
+			context, _ := gin.CreateTestContext(recorder)
+			context.Set(userIDContextKey, "user-1")
+			request := httptest.NewRequest(http.MethodPost, "/notes/sync", strings.NewReader(body))
+			request.Header.Set("Content-Type", "application/json")
+			context.Request = request
+
+			handler := &httpHandler{
+				notesService: &notes.Service{},
+				logger:       zap.NewNop(),
+			}
+			handler.handleNotesSync(context)
+
+			if recorder.Code != http.StatusBadRequest {
+				testContext.Fatalf("unexpected status: got %d want %d", recorder.Code, http.StatusBadRequest)
+			}
+			var payload map[string]any
+			if err := json.Unmarshal(recorder.Body.Bytes(), &payload); err != nil {
+				testContext.Fatalf("failed to decode payload: %v", err)
+			}
+			if payload["error"] != testCase.wantError || payload["code"] != testCase.wantError {
+				testContext.Fatalf("expected error %s, got %v", testCase.wantError, payload)
+			}
+			if payload["operation_index"] != float64(2) {
+				testContext.Fatalf("expected operation_index 2, got %v", payload["operation_index"])
+			}
+			if payload["note_id"] != testCase.wantNoteID {
+				testContext.Fatalf("expected note_id %v, got %v", testCase.wantNoteID, payload["note_id"])
+			}
+		})
+	}
+}
+
+func TestHandleListNotesRejectsInvalidPagination(testContext *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testCases := []struct {
+		name      string
+		query     string
+		wantError string
+	}{
+		{name: "non-numeric-limit", query: "?limit=abc", wantError: "invalid_limit"},
+		{name: "negative-limit", query: "?limit=-1", wantError: "invalid_limit"},
+		{name: "malformed-cursor", query: "?limit=10&cursor=%25%25", wantError: "invalid_cursor"},
+		{name: "non-numeric-since", query: "?since=yesterday", wantError: "invalid_since"},
+		{name: "negative-since", query: "?since=-5", wantError: "invalid_since"},
+		{name: "since-with-limit", query: "?since=10&limit=5", wantError: "invalid_request"},
+	}
+
+	for _, testCase := range testCases {
+		testContext.Run(testCase.name, func(testContext *testing.T) {
+			recorder := httptest.NewRecorder()
+			context, _ := gin.CreateTestContext(recorder)
+			context.Set(userIDContextKey, "user-1")
+			context.Request = httptest.NewRequest(http.MethodGet, "/notes"+testCase.query, http.NoBody)
+
+			handler := &httpHandler{
+				notesService: &notes.Service{},
+				logger:       zap.NewNop(),
+			}
+
+			handler.handleListNotes(context)
+
+			if recorder.Code != http.StatusBadRequest {
+				testContext.Fatalf("expected bad request status, got %d", recorder.Code)
+			}
+			var payload map[string]any
+			if err := json.Unmarshal(recorder.Body.Bytes(), &payload); err != nil {
+				testContext.Fatalf("failed to decode payload: %v", err)
+			}
+			if payload["error"] != testCase.wantError {
+				testContext.Fatalf("expected error %s, got %v", testCase.wantError, payload["error"])
+			}
+		})
+	}
+}
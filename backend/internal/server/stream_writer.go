@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultStreamIdleTimeout bounds how long one realtime event may take to flush when no timeout is configured.
+const DefaultStreamIdleTimeout = time.Minute
+
+// streamWriteController flushes realtime events under a write deadline so a client that stops reading
+// fails its own write instead of pinning the subscriber. The deadline covers one event at a time, so
+// idle but healthy streams, which flush a heartbeat every 25s, are never cut off.
+type streamWriteController struct {
+	controller  *http.ResponseController
+	idleTimeout time.Duration
+	clock       func() time.Time
+	lastFlushed time.Time
+}
+
+// newStreamWriteController reaches past gin's writer, whose Flush swallows errors, to the connection's writer.
+// clock, the handler's clock, only stamps lastFlushed for logging. Write deadlines are absolute times the
+// connection compares with the wall clock, so they always come from time.Now.
+func newStreamWriteController(writer gin.ResponseWriter, idleTimeout time.Duration, clock func() time.Time) *streamWriteController {
+	var target http.ResponseWriter = writer
+	if unwrapper, ok := writer.(interface{ Unwrap() http.ResponseWriter }); ok {
+		target = unwrapper.Unwrap()
+	}
+	return &streamWriteController{
+		controller:  http.NewResponseController(target),
+		idleTimeout: idleTimeout,
+		clock:       clock,
+		lastFlushed: clock(),
+	}
+}
+
+// beginWrite arms the deadline for the next event. Writers without deadline support are left unbounded.
+func (stream *streamWriteController) beginWrite() {
+	if stream.idleTimeout > 0 {
+		_ = stream.controller.SetWriteDeadline(time.Now().Add(stream.idleTimeout))
+	}
+}
+
+// flush pushes the buffered event to the client and records when it last succeeded. The deadline is
+// cleared afterwards so it never outlives the event, including on a connection reused after the stream.
+func (stream *streamWriteController) flush() error {
+	if err := stream.controller.Flush(); err != nil {
+		return err
+	}
+	stream.lastFlushed = stream.clock()
+	if stream.idleTimeout > 0 {
+		_ = stream.controller.SetWriteDeadline(time.Time{})
+	}
+	return nil
+}
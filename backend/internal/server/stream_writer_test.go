@@ -0,0 +1,103 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// stalledStreamWriter accepts writes into its buffer but never delivers a flush, like a client that
+// stopped reading: FlushError blocks until the write deadline and then fails.
+type stalledStreamWriter struct {
+	header   http.Header
+	mutex    sync.Mutex
+	deadline time.Time
+}
+
+func (writer *stalledStreamWriter) Header() http.Header {
+	return writer.header
+}
+
+func (writer *stalledStreamWriter) Write(payload []byte) (int, error) {
+	return len(payload), nil
+}
+
+func (writer *stalledStreamWriter) WriteHeader(int) {}
+
+func (writer *stalledStreamWriter) Flush() {}
+
+func (writer *stalledStreamWriter) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+func (writer *stalledStreamWriter) SetWriteDeadline(deadline time.Time) error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	writer.deadline = deadline
+	return nil
+}
+
+func (writer *stalledStreamWriter) FlushError() error {
+	writer.mutex.Lock()
+	deadline := writer.deadline
+	writer.mutex.Unlock()
+	if deadline.IsZero() {
+		select {}
+	}
+	time.Sleep(time.Until(deadline))
+	return os.ErrDeadlineExceeded
+}
+
+func TestNotesStreamClosesWhenWritesStall(testContext *testing.T) {
+	gin.SetMode(gin.TestMode)
+	writer := &stalledStreamWriter{header: http.Header{}}
+	context, _ := gin.CreateTestContext(writer)
+	context.Set(userIDContextKey, sessionUserID)
+	context.Request = httptest.NewRequest(http.MethodGet, "/notes/stream?ping=true", http.NoBody)
+
+	handler := &httpHandler{
+		logger:            zap.NewNop(),
+		realtime:          NewRealtimeDispatcher(),
+		streamIdleTimeout: 50 * time.Millisecond,
+		clock:             time.Now,
+	}
+	done := make(chan struct{})
+	go func() {
+		handler.handleNotesStream(context)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		testContext.Fatal("expected the stream to close once a write stalled past the idle timeout")
+	}
+}
+
+func TestStreamWriteControllerArmsWallClockDeadlines(testContext *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const idleTimeout = time.Minute
+	injectedNow := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	writer := &stalledStreamWriter{header: http.Header{}}
+	context, _ := gin.CreateTestContext(writer)
+
+	stream := newStreamWriteController(context.Writer, idleTimeout, func() time.Time { return injectedNow })
+	if !stream.lastFlushed.Equal(injectedNow) {
+		testContext.Fatalf("expected the flush bookkeeping to use the handler clock, got %s", stream.lastFlushed)
+	}
+	before := time.Now()
+	stream.beginWrite()
+	after := time.Now()
+	writer.mutex.Lock()
+	deadline := writer.deadline
+	writer.mutex.Unlock()
+	if deadline.Before(before.Add(idleTimeout)) || deadline.After(after.Add(idleTimeout)) {
+		testContext.Fatalf("expected a wall-clock deadline one idle timeout ahead, got %s", deadline)
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/notes"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	tracerName = "github.com/MarcoPoloResearchLab/gravity/backend/internal/server"
+
+	spanNotesSync = "notes.sync"
+	spanNotesList = "notes.list"
+)
+
+func newTracer(provider trace.TracerProvider) trace.Tracer {
+	if provider == nil {
+		provider = noop.NewTracerProvider()
+	}
+	return provider.Tracer(tracerName)
+}
+
+// tracePropagationMiddleware attaches the caller's W3C traceparent to the request context so handler spans join the incoming trace.
+func tracePropagationMiddleware() gin.HandlerFunc {
+	propagator := propagation.TraceContext{}
+	return func(c *gin.Context) {
+		ctx := propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// startSpan opens a span for the handler and makes it the parent of downstream service spans.
+func (h *httpHandler) startSpan(c *gin.Context, name string) trace.Span {
+	tracer := h.tracer
+	if tracer == nil {
+		tracer = newTracer(nil)
+	}
+	ctx, span := tracer.Start(c.Request.Context(), name, trace.WithSpanKind(trace.SpanKindServer))
+	c.Request = c.Request.WithContext(ctx)
+	return span
+}
+
+// recordSyncOutcomes tags the sync span with the same outcome summary as the notes service span.
+func recordSyncOutcomes(span trace.Span, outcomes []notes.CrdtUpdateOutcome) {
+	span.SetAttributes(notes.CrdtOutcomeAttributes(outcomes)...)
+}
+
+func recordSyncRejected(span trace.Span, count int, err error) {
+	span.SetAttributes(
+		attribute.Int(notes.AttributeAcceptedCount, 0),
+		attribute.Int(notes.AttributeRejectedCount, count),
+	)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
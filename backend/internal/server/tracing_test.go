@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/notes"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const incomingTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+func TestNotesSyncProducesTracedSpans(testContext *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	testContext.Cleanup(func() {
+		_ = provider.Shutdown(testContext.Context())
+	})
+	server := newIntegrationTestServerWithDependencies(testContext, Dependencies{
+		Realtime:       NewRealtimeDispatcher(),
+		TracerProvider: provider,
+	})
+	sessionToken := mustMintSessionToken(testContext, sessionSigningSecret, sessionUserID, time.Now())
+
+	payload := `{"protocol":"crdt-v1","updates":[{"note_id":"` + sessionNoteID + `","update_b64":"AQID","snapshot_b64":"AQID","snapshot_update_id":0}],"cursors":[{"note_id":"` + sessionNoteID + `","last_update_id":0}]}`
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/notes/sync", strings.NewReader(payload))
+	if err != nil {
+		testContext.Fatalf("failed to construct sync request: %v", err)
+	}
+	request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionToken})
+	request.Header.Set("Content-Type", jsonContentType)
+	request.Header.Set("traceparent", "00-"+incomingTraceID+"-00f067aa0ba902b7-01")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		testContext.Fatalf("sync request failed: %v", err)
+	}
+	_ = response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		testContext.Fatalf("unexpected sync status: %d", response.StatusCode)
+	}
+
+	spansByName := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spansByName[span.Name] = span
+	}
+	syncSpan, ok := spansByName[spanNotesSync]
+	if !ok {
+		testContext.Fatalf("expected %s span, got %v", spanNotesSync, exporter.GetSpans())
+	}
+	if syncSpan.SpanContext.TraceID().String() != incomingTraceID {
+		testContext.Fatalf("expected span to join incoming trace, got %s", syncSpan.SpanContext.TraceID())
+	}
+	expected := map[attribute.Key]attribute.Value{
+		notes.AttributeUserID:         attribute.StringValue(sessionUserID),
+		notes.AttributeOperationCount: attribute.IntValue(1),
+		notes.AttributeAcceptedCount:  attribute.IntValue(1),
+		notes.AttributeRejectedCount:  attribute.IntValue(0),
+	}
+	actual := map[attribute.Key]attribute.Value{}
+	for _, kv := range syncSpan.Attributes {
+		actual[kv.Key] = kv.Value
+	}
+	for key, value := range expected {
+		if actual[key] != value {
+			testContext.Fatalf("attribute %s: expected %v, got %v", key, value.Emit(), actual[key].Emit())
+		}
+	}
+
+	applySpan, ok := spansByName["notes.apply_crdt_updates"]
+	if !ok {
+		testContext.Fatal("expected notes service span")
+	}
+	if applySpan.Parent.SpanID() != syncSpan.SpanContext.SpanID() {
+		testContext.Fatal("expected service span to be a child of the sync span")
+	}
+}
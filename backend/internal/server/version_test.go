@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/buildinfo"
+	"github.com/MarcoPoloResearchLab/gravity/backend/internal/notes"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestVersionRouteReturnsEmbeddedBuildInfo(testContext *testing.T) {
+	gin.SetMode(gin.TestMode)
+	originalVersion, originalCommit, originalDate := buildinfo.Version, buildinfo.Commit, buildinfo.Date
+	buildinfo.Version, buildinfo.Commit, buildinfo.Date = "v1.2.3", "abc1234", "2026-10-01T00:00:00Z"
+	testContext.Cleanup(func() {
+		buildinfo.Version, buildinfo.Commit, buildinfo.Date = originalVersion, originalCommit, originalDate
+	})
+
+	handler, err := NewHTTPHandler(Dependencies{
+		SessionValidator: stubSessionValidator{},
+		NotesService:     &notes.Service{},
+		Logger:           zap.NewNop(),
+	})
+	if err != nil {
+		testContext.Fatalf("failed to build handler: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/version", http.NoBody))
+	if recorder.Code != http.StatusOK {
+		testContext.Fatalf("expected 200 without a session, got %d", recorder.Code)
+	}
+	var payload buildinfo.Info
+	if err := json.Unmarshal(recorder.Body.Bytes(), &payload); err != nil {
+		testContext.Fatalf("failed to decode version payload: %v", err)
+	}
+	expected := buildinfo.Info{Version: "v1.2.3", Commit: "abc1234", Date: "2026-10-01T00:00:00Z"}
+	if payload != expected {
+		testContext.Fatalf("unexpected version payload: got %#v want %#v", payload, expected)
+	}
+}
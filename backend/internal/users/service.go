@@ -14,17 +14,28 @@ import (
 // ErrInvalidIdentity indicates the claims did not contain a usable identifier.
 var ErrInvalidIdentity = errors.New("users: invalid identity")
 
+// DefaultCacheTTL bounds how long a resolved identity is served from memory before profile claims are re-applied.
+const DefaultCacheTTL = 5 * time.Minute
+
 // ServiceConfig describes the dependencies required for user identity resolution.
+// A zero CacheTTL selects DefaultCacheTTL.
 type ServiceConfig struct {
 	Database *gorm.DB
 	Clock    func() time.Time
+	CacheTTL time.Duration
 }
 
 // Service manages canonical user identifiers and provider-specific identities.
 type Service struct {
-	db    *gorm.DB
-	now   func() time.Time
-	cache sync.Map
+	db       *gorm.DB
+	now      func() time.Time
+	cacheTTL time.Duration
+	cache    sync.Map
+}
+
+type cacheEntry struct {
+	identity Identity
+	storedAt time.Time
 }
 
 // NewService constructs the identity service and ensures the schema is present.
@@ -32,30 +43,48 @@ func NewService(cfg ServiceConfig) (*Service, error) {
 	if cfg.Database == nil {
 		return nil, fmt.Errorf("users: database connection required")
 	}
+	if cfg.CacheTTL < 0 {
+		return nil, fmt.Errorf("users: cache ttl must not be negative")
+	}
 	clock := cfg.Clock
 	if clock == nil {
 		clock = time.Now
 	}
+	cacheTTL := cfg.CacheTTL
+	if cacheTTL == 0 {
+		cacheTTL = DefaultCacheTTL
+	}
 	return &Service{
-		db:    cfg.Database,
-		now:   clock,
-		cache: sync.Map{},
+		db:       cfg.Database,
+		now:      clock,
+		cacheTTL: cacheTTL,
+		cache:    sync.Map{},
 	}, nil
 }
 
 // ResolveCanonicalUserID returns the canonical Gravity user id for the provided session claims.
-// It creates a new identity mapping when the provider+subject pair has not been seen before.
 func (s *Service) ResolveCanonicalUserID(claims auth.SessionClaims) (string, error) {
+	identity, err := s.ResolveIdentity(claims)
+	if err != nil {
+		return "", err
+	}
+	return identity.UserID, nil
+}
+
+// ResolveIdentity returns the persisted identity for the provided session claims.
+// It creates a new identity mapping when the provider+subject pair has not been seen before,
+// and re-applies profile claims once the cached entry is older than the cache TTL.
+func (s *Service) ResolveIdentity(claims auth.SessionClaims) (Identity, error) {
 	provider, subject := deriveProviderSubject(claims)
 	if subject == "" {
-		return "", ErrInvalidIdentity
+		return Identity{}, ErrInvalidIdentity
 	}
 
-	cacheKey := provider + ":" + subject
-	if cachedIdentifier, ok := s.cache.Load(cacheKey); ok {
-		canonicalIdentifier, ok := cachedIdentifier.(string)
-		if ok {
-			return canonicalIdentifier, nil
+	cacheKey := identityCacheKey(provider, subject)
+	if cached, ok := s.cache.Load(cacheKey); ok {
+		entry, ok := cached.(cacheEntry)
+		if ok && s.now().Sub(entry.storedAt) < s.cacheTTL {
+			return entry.identity, nil
 		}
 	}
 
@@ -75,25 +104,29 @@ func (s *Service) ResolveCanonicalUserID(claims auth.SessionClaims) (string, err
 			LastSeenAt:  s.now(),
 		}
 		if identity.UserID == "" {
-			return "", ErrInvalidIdentity
+			return Identity{}, ErrInvalidIdentity
 		}
 		if err := s.db.Create(&identity).Error; err != nil {
-			return "", err
+			return Identity{}, err
 		}
 	} else if err != nil {
-		return "", err
+		return Identity{}, err
 	} else {
 		updates := map[string]interface{}{}
 		if email := normalize(claims.UserEmail); email != "" && email != identity.Email {
 			updates["user_email"] = email
+			identity.Email = email
 		}
 		if display := normalize(claims.UserDisplayName); display != "" && display != identity.DisplayName {
 			updates["user_display_name"] = display
+			identity.DisplayName = display
 		}
 		if avatar := normalize(claims.UserAvatarURL); avatar != "" && avatar != identity.AvatarURL {
 			updates["user_avatar_url"] = avatar
+			identity.AvatarURL = avatar
 		}
 		updates["last_seen_at"] = s.now()
+		identity.LastSeenAt = s.now()
 		if len(updates) > 0 {
 			_ = s.db.Model(&Identity{}).
 				Where("provider = ? AND subject = ?", provider, subject).
@@ -102,8 +135,36 @@ func (s *Service) ResolveCanonicalUserID(claims auth.SessionClaims) (string, err
 		}
 	}
 
-	s.cache.Store(cacheKey, identity.UserID)
-	return identity.UserID, nil
+	s.cache.Store(cacheKey, cacheEntry{identity: identity, storedAt: s.now()})
+	return identity, nil
+}
+
+// InvalidateUser evicts the cached identity for the provider and subject so the next resolve reads the database.
+func (s *Service) InvalidateUser(provider, subject string) {
+	s.cache.Delete(identityCacheKey(normalize(provider), normalize(subject)))
+}
+
+// DeleteUser removes every provider identity mapped to the canonical user id and evicts them from the cache.
+// Deleting an unknown user succeeds.
+func (s *Service) DeleteUser(userID string) error {
+	canonicalIdentifier := normalize(userID)
+	if canonicalIdentifier == "" {
+		return ErrInvalidIdentity
+	}
+	if err := s.db.Where("user_id = ?", canonicalIdentifier).Delete(&Identity{}).Error; err != nil {
+		return err
+	}
+	s.cache.Range(func(key, value any) bool {
+		if entry, ok := value.(cacheEntry); ok && entry.identity.UserID == canonicalIdentifier {
+			s.cache.Delete(key)
+		}
+		return true
+	})
+	return nil
+}
+
+func identityCacheKey(provider, subject string) string {
+	return provider + ":" + subject
 }
 
 func deriveProviderSubject(claims auth.SessionClaims) (string, string) {
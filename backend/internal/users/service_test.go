@@ -50,3 +50,157 @@ func TestResolveCanonicalUserIDStripsProviderPrefix(t *testing.T) {
 		t.Fatalf("expected canonical user id to remain stable, got %q", userID)
 	}
 }
+
+func TestDeleteUserRemovesIdentitiesAndEvictsCache(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.TempDir()+"/users.db"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&Identity{}); err != nil {
+		t.Fatalf("failed to migrate identity schema: %v", err)
+	}
+	service, err := NewService(ServiceConfig{Database: db})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+
+	deletedClaims := auth.SessionClaims{UserID: "google:deleted"}
+	retainedClaims := auth.SessionClaims{UserID: "google:retained"}
+	for _, claims := range []auth.SessionClaims{deletedClaims, retainedClaims} {
+		if _, err := service.ResolveCanonicalUserID(claims); err != nil {
+			t.Fatalf("resolve failed: %v", err)
+		}
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if err := service.DeleteUser("deleted"); err != nil {
+			t.Fatalf("delete attempt %d failed: %v", attempt, err)
+		}
+	}
+
+	var remaining []Identity
+	if err := db.Order("subject").Find(&remaining).Error; err != nil {
+		t.Fatalf("failed to list identities: %v", err)
+	}
+	if len(remaining) != 1 || remaining[0].UserID != "retained" {
+		t.Fatalf("expected only the retained identity, got %#v", remaining)
+	}
+	if _, cached := service.cache.Load("google:deleted"); cached {
+		t.Fatal("expected deleted identity to be evicted from the cache")
+	}
+	if _, cached := service.cache.Load("google:retained"); !cached {
+		t.Fatal("expected retained identity to stay cached")
+	}
+}
+
+func TestResolveCanonicalUserIDRefreshesProfileAfterCacheTTL(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.TempDir()+"/users.db"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&Identity{}); err != nil {
+		t.Fatalf("failed to migrate identity schema: %v", err)
+	}
+	currentTime := time.Unix(1700000000, 0)
+	service, err := NewService(ServiceConfig{
+		Database: db,
+		Clock: func() time.Time {
+			return currentTime
+		},
+		CacheTTL: time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+
+	if _, err := service.ResolveCanonicalUserID(auth.SessionClaims{UserID: "google:ttl", UserDisplayName: "Original"}); err != nil {
+		t.Fatalf("initial resolve failed: %v", err)
+	}
+	renamedClaims := auth.SessionClaims{UserID: "google:ttl", UserDisplayName: "Renamed"}
+
+	testCases := []struct {
+		name                string
+		advance             time.Duration
+		expectedDisplayName string
+	}{
+		{name: "within-ttl-serves-cache", advance: 30 * time.Second, expectedDisplayName: "Original"},
+		{name: "after-ttl-applies-profile", advance: time.Minute, expectedDisplayName: "Renamed"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			currentTime = currentTime.Add(testCase.advance)
+			if _, err := service.ResolveCanonicalUserID(renamedClaims); err != nil {
+				t.Fatalf("resolve failed: %v", err)
+			}
+			var stored Identity
+			if err := db.Where("provider = ? AND subject = ?", "google", "ttl").First(&stored).Error; err != nil {
+				t.Fatalf("failed to load identity: %v", err)
+			}
+			if stored.DisplayName != testCase.expectedDisplayName {
+				t.Fatalf("expected display name %q, got %q", testCase.expectedDisplayName, stored.DisplayName)
+			}
+		})
+	}
+
+	service.InvalidateUser("google", "ttl")
+	if _, cached := service.cache.Load("google:ttl"); cached {
+		t.Fatal("expected InvalidateUser to evict the cache entry")
+	}
+	if _, err := service.ResolveCanonicalUserID(auth.SessionClaims{UserID: "google:ttl", UserDisplayName: "Invalidated"}); err != nil {
+		t.Fatalf("resolve after invalidation failed: %v", err)
+	}
+	var stored Identity
+	if err := db.Where("provider = ? AND subject = ?", "google", "ttl").First(&stored).Error; err != nil {
+		t.Fatalf("failed to load identity: %v", err)
+	}
+	if stored.DisplayName != "Invalidated" {
+		t.Fatalf("expected profile refresh after invalidation, got %q", stored.DisplayName)
+	}
+}
+
+func TestResolveIdentityReturnsUpdatedProfile(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.TempDir()+"/users.db"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open sqlite: %v", err)
+	}
+	if err := db.AutoMigrate(&Identity{}); err != nil {
+		t.Fatalf("failed to migrate identity schema: %v", err)
+	}
+	service, err := NewService(ServiceConfig{Database: db})
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+
+	initial, err := service.ResolveIdentity(auth.SessionClaims{
+		UserID:          "google:profile",
+		UserEmail:       "old@example.com",
+		UserDisplayName: "Old Name",
+	})
+	if err != nil {
+		t.Fatalf("initial resolve failed: %v", err)
+	}
+	if initial.UserID != "profile" || initial.DisplayName != "Old Name" {
+		t.Fatalf("unexpected initial identity: %#v", initial)
+	}
+
+	service.InvalidateUser("google", "profile")
+	updated, err := service.ResolveIdentity(auth.SessionClaims{
+		UserID:          "google:profile",
+		UserDisplayName: "New Name",
+		UserAvatarURL:   "https://example.com/new.png",
+	})
+	if err != nil {
+		t.Fatalf("updated resolve failed: %v", err)
+	}
+	if updated.DisplayName != "New Name" || updated.AvatarURL != "https://example.com/new.png" || updated.Email != "old@example.com" {
+		t.Fatalf("expected updated claims in returned identity, got %#v", updated)
+	}
+
+	cached, err := service.ResolveIdentity(auth.SessionClaims{UserID: "google:profile"})
+	if err != nil {
+		t.Fatalf("cached resolve failed: %v", err)
+	}
+	if cached.DisplayName != "New Name" {
+		t.Fatalf("expected cache to hold the full updated identity, got %#v", cached)
+	}
+}
@@ -41,7 +41,7 @@ func setupIntegrationServer(testContext *testing.T) (*httptest.Server, *http.Coo
 		testContext.Fatalf("failed to open sqlite: %v", err)
 	}
 
-	if err := database.AutoMigrate(&notes.CrdtUpdate{}, &notes.CrdtSnapshot{}); err != nil {
+	if err := database.AutoMigrate(&notes.CrdtUpdate{}, &notes.CrdtSnapshot{}, &notes.NoteTag{}); err != nil {
 		testContext.Fatalf("failed to migrate: %v", err)
 	}
 